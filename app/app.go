@@ -2,17 +2,23 @@ package app
 
 import (
 	"VoAr/internal/app"
+	"VoAr/internal/chat"
+	"VoAr/internal/markdown"
+	"VoAr/internal/render"
+	"VoAr/pkg/blob"
+	"VoAr/pkg/mailer"
+	"VoAr/pkg/oauth"
+	"VoAr/pkg/pgsession"
+	"VoAr/web"
 	"database/sql"
 	"fmt"
-	"html/template"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
-	"strings"
+	"time"
 
-	"github.com/gorilla/mux"           // Package for HTTP request multiplexer (router)
-	"github.com/markbates/goth"        // Package for multi-provider authentication
-	"github.com/markbates/goth/gothic" // Package for handling OAuth state and session
+	"github.com/gorilla/mux" // Package for HTTP request multiplexer (router)
 )
 
 // initDB initializes the database connection and performs the necessary checks.
@@ -44,139 +50,351 @@ func InitDB() (*sql.DB, error) {
 	return db, nil
 }
 
+// NewSessionStore creates the PostgreSQL backed session store
+// It reads the signing key, the timeouts and whether cookies require HTTPS from environment variables
+func NewSessionStore(db *sql.DB) *pgsession.Store {
+	store := pgsession.New(db, []byte(os.Getenv("SESSION_KEY")))
+	store.Options.Secure = os.Getenv("SESSION_SECURE") == "true"
+
+	//Overriding the default timeouts when they are configured, e.g. SESSION_IDLE_TIMEOUT=2h
+	if d, err := time.ParseDuration(os.Getenv("SESSION_IDLE_TIMEOUT")); err == nil {
+		store.IdleTimeout = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("SESSION_ABSOLUTE_TIMEOUT")); err == nil {
+		store.SetMaxAge(d)
+	}
+	return store
+}
+
+// Assets returns the file system holding the "templates" and "css" directories
+// The files are embedded into the binary unless TEMPLATE_DEV=true, in which case they are read
+// From the web directory on disk, so that edits show up without rebuilding
+func Assets() (assets fs.FS, dev bool) {
+	if os.Getenv("TEMPLATE_DEV") == "true" {
+		return os.DirFS("web"), true
+	}
+	return web.Files, false
+}
+
+// NewRenderer parses the templates from the assets once, with the template functions of the app
+// It fails when one of the pages executed by the handlers is missing
+func NewRenderer(assets fs.FS, dev bool) (*render.Renderer, error) {
+	return render.New(assets, dev, app.TemplateFuncs, app.Pages...)
+}
+
 // HandleFunc creates a new HTTP server instance with the specified database connection
 // And sets up the routing for various endpoints using the Gorilla Mux router
-// It includes middleware to inject the database into the request context
+// It includes middleware to inject the store built on top of the database and the template renderer into the request context
+// The sign-in page lists the providers of the registry
 // The function returns the configured HTTP server
-func HandleFunc(db *sql.DB) *http.Server {
+func HandleFunc(db *sql.DB, assets fs.FS, renderer *render.Renderer, providers *oauth.Registry) *http.Server {
 	//Creating 	a new Gorilla Mux router
 	router := mux.NewRouter()
 
-	//Using the dbMiddleware to inject the database into the request context
-	router.Use(app.DbMiddleware(db, router))
+	//Using the csrfMiddleware to reject state-changing requests without the CSRF token of the browser
+	//The fake issuer and the fake S3 server are called by the server itself and have no token
+	router.Use(app.CSRFMiddleware([]byte(os.Getenv("SESSION_KEY")), os.Getenv("SESSION_SECURE") == "true", oauth.FakePath, blob.FakePath))
+
+	//Using the storeMiddleware to inject the PostgreSQL backed store into the request context
+	store := app.NewPostgresStore(db)
+	router.Use(app.StoreMiddleware(store))
+
+	//Using the authMiddleware to load the signed-in user into the request context
+	router.Use(app.AuthMiddleware(store))
+
+	//Using the rendererMiddleware to inject the pre-parsed templates into the request context
+	router.Use(app.RendererMiddleware(renderer))
+
+	//Starting the chat hub that fans out messages to every connected client
+	hub := chat.NewHub(chat.NewStore(db))
+	go hub.Run()
+
+	//Publishing scheduled articles once their publication time has come, checked every 15 seconds
+	go app.PublishScheduled(store, 15*time.Second)
+
+	//Caching the HTML rendered from the Markdown of recently shown article revisions
+	articleHTML := markdown.NewCache(1000)
+
+	//Creating the mailer that sends verification and password reset links
+	mail, err := mailer.FromEnv()
+	if err != nil {
+		log.Fatal("Error configuring the mailer:", err)
+	}
+
+	//Creating the blob storage that keeps the files uploaded to articles
+	storage, fakeS3, err := blob.FromEnv()
+	if err != nil {
+		log.Fatal("Error configuring the blob storage:", err)
+	}
 
 	//Handling different routes with corresponding HTTP methods
 	router.HandleFunc("/", app.MainPage).Methods("GET")
-	router.HandleFunc("/create", app.Create).Methods("GET")
+	router.HandleFunc("/create", app.RequirePermission(app.PermCreateArticle, func(w http.ResponseWriter, r *http.Request) {
+		app.Create(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	})).Methods("GET")
 	router.HandleFunc("/examples", app.Examples).Methods("GET")
 	router.HandleFunc("/chat", app.Chat).Methods("GET")
-	router.HandleFunc("/complete", app.Complete).Methods("GET")
-	router.HandleFunc("/userSavedSuccesfull", app.UserSavedSuccesfull).Methods("GET")
-	router.HandleFunc("/userExists", app.UserExists).Methods("GET")
+
+	//Handling the "/chat/ws" WebSocket endpoint with the chat hub
+	router.HandleFunc("/chat/ws", func(w http.ResponseWriter, r *http.Request) {
+		app.ChatSocket(w, r, hub)
+	}).Methods("GET")
 
 	//Handling the "/post" endpoint with the post function
 	router.HandleFunc("/post", func(w http.ResponseWriter, r *http.Request) {
-		//Retrieving the database connection from the request context
-		db = r.Context().Value(app.DbKey).(*sql.DB)
-		app.Post(w, r, db)
+		//Retrieving the store from the request context
+		store := r.Context().Value(app.StoreKey).(*app.Store)
+		app.Post(w, r, store)
+	}).Methods("GET")
+
+	//Handling the "/tag/{slug}" and "/category/{slug}" endpoints listing the articles of a tag or a category
+	router.HandleFunc("/tag/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		app.TagPage(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("GET")
+	router.HandleFunc("/category/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		app.CategoryPage(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("GET")
+
+	//Handling the "/author/{id}" endpoint listing the articles of a user
+	router.HandleFunc("/author/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		app.AuthorPage(w, r, r.Context().Value(app.StoreKey).(*app.Store))
 	}).Methods("GET")
 
-	//Handling the "/show/{id:{0-9}+}" endpoint with the showPost function
+	//Handling the RSS, Atom and JSON feeds of the article lists, e.g. "/feed.atom" and "/tag/{slug}/feed.rss"
+	router.HandleFunc("/feed.{format:rss|atom|json}", func(w http.ResponseWriter, r *http.Request) {
+		app.Feed(w, r, r.Context().Value(app.StoreKey).(*app.Store), articleHTML)
+	}).Methods("GET")
+	router.HandleFunc("/tag/{slug}/feed.{format:rss|atom|json}", func(w http.ResponseWriter, r *http.Request) {
+		app.TagFeed(w, r, r.Context().Value(app.StoreKey).(*app.Store), articleHTML)
+	}).Methods("GET")
+	router.HandleFunc("/category/{slug}/feed.{format:rss|atom|json}", func(w http.ResponseWriter, r *http.Request) {
+		app.CategoryFeed(w, r, r.Context().Value(app.StoreKey).(*app.Store), articleHTML)
+	}).Methods("GET")
+	router.HandleFunc("/author/{id:[0-9]+}/feed.{format:rss|atom|json}", func(w http.ResponseWriter, r *http.Request) {
+		app.AuthorFeed(w, r, r.Context().Value(app.StoreKey).(*app.Store), articleHTML)
+	}).Methods("GET")
+
+	//Handling the "/search" endpoint with the full-text search page
+	router.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
+		app.Search(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("GET")
+
+	//Handling the "/articles/{slug}" endpoint with the showPost function, the old "/show/{id:{0-9}+}" addresses redirect there
+	router.HandleFunc("/articles/{slug}", func(w http.ResponseWriter, r *http.Request) {
+		//Retrieving the store from the request context
+		store := r.Context().Value(app.StoreKey).(*app.Store)
+		app.ShowPost(w, r, store, articleHTML)
+	}).Methods("GET")
 	router.HandleFunc("/show/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
-		//Retrieving the database connection from the request context
-		db = r.Context().Value(app.DbKey).(*sql.DB)
-		app.ShowPost(w, r, db)
+		//Retrieving the store from the request context
+		store := r.Context().Value(app.StoreKey).(*app.Store)
+		app.ShowPost(w, r, store, articleHTML)
 	}).Methods("GET")
 
+	//Handling robots.txt and the sitemap read by search engines
+	router.HandleFunc("/robots.txt", app.Robots).Methods("GET")
+	router.HandleFunc("/sitemap.xml", func(w http.ResponseWriter, r *http.Request) {
+		app.Sitemap(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("GET")
+
+	//Handling the "/preview" endpoint rendering the Markdown typed into the article forms
+	router.HandleFunc("/preview", app.RequirePermission(app.PermCreateArticle, app.Preview)).Methods("POST")
+
 	//Handling the "/save_article" endpoint with the save_article function
-	router.HandleFunc("/save_article", func(w http.ResponseWriter, r *http.Request) {
-		//Retrieving the database connection from the request context
-		db = r.Context().Value(app.DbKey).(*sql.DB)
-		app.Save_article(w, r)
+	router.HandleFunc("/save_article", app.RequirePermission(app.PermCreateArticle, func(w http.ResponseWriter, r *http.Request) {
+		//Retrieving the store from the request context
+		store := r.Context().Value(app.StoreKey).(*app.Store)
+		app.Save_article(w, r, store, storage)
+	})).Methods("POST")
+
+	//Handling the "/edit/{id:[0-9]+}" endpoint with the editPost function for the edit form
+	router.HandleFunc("/edit/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		//Retrieving the store from the request context
+		store := r.Context().Value(app.StoreKey).(*app.Store)
+		app.EditPost(w, r, store)
+	}).Methods("GET")
+
+	//Handling the "/edit/{id:[0-9]+}" endpoint with the updatePost function for the submitted form
+	router.HandleFunc("/edit/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		//Retrieving the store from the request context
+		store := r.Context().Value(app.StoreKey).(*app.Store)
+		app.UpdatePost(w, r, store, storage)
 	}).Methods("POST")
 
+	//Handling the "/delete/{id:[0-9]+}" endpoint with the deletePost function
+	router.HandleFunc("/delete/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		//Retrieving the store from the request context
+		store := r.Context().Value(app.StoreKey).(*app.Store)
+		app.DeletePost(w, r, store)
+	}).Methods("POST")
+
+	//Handling the revision history of the articles, restoring an older revision needs the permission to edit the article
+	router.HandleFunc("/show/{id:[0-9]+}/history", func(w http.ResponseWriter, r *http.Request) {
+		app.History(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("GET")
+	router.HandleFunc("/show/{id:[0-9]+}/diff", func(w http.ResponseWriter, r *http.Request) {
+		app.Diff(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("GET")
+	router.HandleFunc("/show/{id:[0-9]+}/history/{revision:[0-9]+}/restore", app.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		app.RestoreRevision(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	})).Methods("POST")
+
+	//Handling the files uploaded to the articles, removing a file needs the permission to edit its article
+	router.HandleFunc("/media/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		app.ServeMedia(w, r, r.Context().Value(app.StoreKey).(*app.Store), storage, false)
+	}).Methods("GET")
+	router.HandleFunc("/media/{id:[0-9]+}/thumbnail", func(w http.ResponseWriter, r *http.Request) {
+		app.ServeMedia(w, r, r.Context().Value(app.StoreKey).(*app.Store), storage, true)
+	}).Methods("GET")
+	router.HandleFunc("/media/{id:[0-9]+}/delete", app.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		app.DeleteMedia(w, r, r.Context().Value(app.StoreKey).(*app.Store), storage)
+	})).Methods("POST")
+
+	//Handling the comments below the articles, authors change their comments for a while and moderators hide them
+	router.HandleFunc("/show/{id:[0-9]+}/comments", app.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		app.CreateComment(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	})).Methods("POST")
+	router.HandleFunc("/comments/{id:[0-9]+}/edit", app.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		app.UpdateComment(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	})).Methods("POST")
+	router.HandleFunc("/comments/{id:[0-9]+}/delete", app.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		app.DeleteComment(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	})).Methods("POST")
+	router.HandleFunc("/comments/{id:[0-9]+}/hide", app.RequirePermission(app.PermModerate, func(w http.ResponseWriter, r *http.Request) {
+		app.ModerateComment(w, r, r.Context().Value(app.StoreKey).(*app.Store), true)
+	})).Methods("POST")
+	router.HandleFunc("/comments/{id:[0-9]+}/restore", app.RequirePermission(app.PermModerate, func(w http.ResponseWriter, r *http.Request) {
+		app.ModerateComment(w, r, r.Context().Value(app.StoreKey).(*app.Store), false)
+	})).Methods("POST")
+
+	//Handling the admin area, which is open to admins only
+	router.HandleFunc("/admin", app.RequirePermission(app.PermAdmin, func(w http.ResponseWriter, r *http.Request) {
+		app.AdminDashboard(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	})).Methods("GET")
+	router.HandleFunc("/admin/categories", app.RequirePermission(app.PermAdmin, func(w http.ResponseWriter, r *http.Request) {
+		app.AdminCreateCategory(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	})).Methods("POST")
+	router.HandleFunc("/admin/users", app.RequirePermission(app.PermAdmin, func(w http.ResponseWriter, r *http.Request) {
+		app.AdminUsers(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	})).Methods("GET")
+	router.HandleFunc("/admin/users/{id:[0-9]+}/ban", app.RequirePermission(app.PermAdmin, func(w http.ResponseWriter, r *http.Request) {
+		app.AdminSetBanned(w, r, r.Context().Value(app.StoreKey).(*app.Store), true)
+	})).Methods("POST")
+	router.HandleFunc("/admin/users/{id:[0-9]+}/unban", app.RequirePermission(app.PermAdmin, func(w http.ResponseWriter, r *http.Request) {
+		app.AdminSetBanned(w, r, r.Context().Value(app.StoreKey).(*app.Store), false)
+	})).Methods("POST")
+	router.HandleFunc("/admin/users/{id:[0-9]+}/role", app.RequirePermission(app.PermAdmin, func(w http.ResponseWriter, r *http.Request) {
+		app.AdminSetRole(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	})).Methods("POST")
+	router.HandleFunc("/admin/articles", app.RequirePermission(app.PermAdmin, func(w http.ResponseWriter, r *http.Request) {
+		app.AdminArticles(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	})).Methods("GET")
+	router.HandleFunc("/admin/articles/{id:[0-9]+}/hide", app.RequirePermission(app.PermAdmin, func(w http.ResponseWriter, r *http.Request) {
+		articles := r.Context().Value(app.StoreKey).(*app.Store).Articles
+		app.AdminModerateArticle(w, r, func(id int) error { return articles.SetHidden(id, true) })
+	})).Methods("POST")
+	router.HandleFunc("/admin/articles/{id:[0-9]+}/restore", app.RequirePermission(app.PermAdmin, func(w http.ResponseWriter, r *http.Request) {
+		app.AdminModerateArticle(w, r, r.Context().Value(app.StoreKey).(*app.Store).Articles.Restore)
+	})).Methods("POST")
+	router.HandleFunc("/admin/articles/{id:[0-9]+}/delete", app.RequirePermission(app.PermAdmin, func(w http.ResponseWriter, r *http.Request) {
+		app.AdminModerateArticle(w, r, r.Context().Value(app.StoreKey).(*app.Store).Articles.Delete)
+	})).Methods("POST")
+
+	//Handling the versioned JSON API for articles, clients authenticate with an API token or with the session cookie and a CSRF token
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.HandleFunc("/articles", func(w http.ResponseWriter, r *http.Request) {
+		app.APIListArticles(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("GET")
+	api.HandleFunc("/articles", func(w http.ResponseWriter, r *http.Request) {
+		app.APICreateArticle(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("POST")
+	api.HandleFunc("/articles/search", func(w http.ResponseWriter, r *http.Request) {
+		app.APISearchArticles(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("GET")
+	api.HandleFunc("/articles/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		app.APIGetArticle(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("GET")
+	api.HandleFunc("/articles/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		app.APIUpdateArticle(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("PUT")
+	api.HandleFunc("/articles/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		app.APIDeleteArticle(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("DELETE")
+	api.HandleFunc("/articles/{id:[0-9]+}/comments", func(w http.ResponseWriter, r *http.Request) {
+		app.APIListComments(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("GET")
+	api.HandleFunc("/csrf-token", app.APICSRFToken).Methods("GET")
+
 	//Handling 	authentication using third-party providers (0Auth)
-	router.HandleFunc("/auth/{provider}", func(w http.ResponseWriter, r *http.Request) {
-		gothic.BeginAuthHandler(w, r)
-	})
+	router.HandleFunc("/auth/{provider}", app.BeginAuth)
 
 	//Handling the callback from the third-party authentication provider
 	router.HandleFunc("/auth/{provider}/callback", func(w http.ResponseWriter, r *http.Request) {
-		user, err := gothic.CompleteUserAuth(w, r)
-		if err != nil {
-			// Handling authentication error by returning an internal server error response
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			log.Printf("Error completing user authentication: %v", err)
-			return
-		}
-		//Redirecting to the completion page with user information
-		http.Redirect(w, r, "/complete?name="+user.Name+"&email="+user.Email, http.StatusSeeOther)
+		app.AuthCallback(w, r, r.Context().Value(app.StoreKey).(*app.Store))
 	})
 
-	//Handling the "/googleSignIn" endpoit for Google-Sing-In
+	//Handling the account settings page where identities of other providers are linked and unlinked and API tokens are managed
+	router.HandleFunc("/settings", app.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		app.Settings(w, r, r.Context().Value(app.StoreKey).(*app.Store), providers.Providers)
+	})).Methods("GET")
+	router.HandleFunc("/settings/link/{provider}", app.RequireAuth(app.LinkIdentity)).Methods("POST")
+	router.HandleFunc("/settings/identities/{id:[0-9]+}/unlink", app.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		app.UnlinkIdentity(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	})).Methods("POST")
+	router.HandleFunc("/settings/tokens", app.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		app.CreateAPIToken(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	})).Methods("POST")
+	router.HandleFunc("/settings/tokens/{id:[0-9]+}/revoke", app.RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		app.RevokeAPIToken(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	})).Methods("POST")
+
+	//Handling the "/logout" endpoint that signs the user out of the current browser
+	router.HandleFunc("/logout", app.Logout).Methods("POST")
+
+	//Handling the "/logout/all" endpoint that ends the sessions of the user on every device
+	router.HandleFunc("/logout/all", app.LogoutEverywhere).Methods("POST")
+
+	//Handling the "/googleSignIn" endpoit for the sign-in page listing every enabled provider
 	router.HandleFunc("/googleSignIn", func(w http.ResponseWriter, r *http.Request) {
-		// Parsing HTML template files for the Google Sign-In page, header, and footer
-		t, err := template.ParseFiles("web/templates/googleSignIn.html", "web/templates/header.html", "web/templates/footer.html")
-		if err != nil {
-			// Handling template parsing error by returning an internal server error response
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			log.Printf("Error parsing template files: %v", err)
-			return
-		}
-
-		//Handling the completion page after Google-Sign-In
-		router.HandleFunc("/complete", func(w http.ResponseWriter, r *http.Request) {
-			//Retrieving the database connection from the request context
-			db = r.Context().Value("db").(*sql.DB)
-
-			//Retrieving user information from the session
-			session, _ := gothic.Store.Get(r, "session-name")
-			user, ok := session.Values["user"].(goth.User)
-			if !ok {
-				// Handling the case where the user is not found in the session
-				http.Error(w, "User not found", http.StatusNotFound)
-				log.Printf("User not found in session")
-				return
-			}
-
-			// Parsing HTML template files for the completion page, header, and footer
-			t, err := template.ParseFiles("VoAr/web/templates/complete.html", "VoAr/web/templates/header.html", "VoAr/web/templates/footer.html")
-			if err != nil {
-				// Handling template parsing error by returning an internal server error response
-				http.Error(w, "Internal server error", http.StatusInternalServerError)
-				log.Printf("Error parsing template files: %v", err)
-				return
-			}
-
-			// Executing the completion template with user information and writing the output to the response writer
-			t.ExecuteTemplate(w, "complete", user)
-		}).Methods("GET")
-		//Executing  the Google-Sign-In template and writing the output to the response writer
-		t.ExecuteTemplate(w, "googleSignIn", nil)
-	}).Methods("GET")
-
-	// Handle the "save_user" endpoint for saving user data to the database
-	router.HandleFunc("/save_user", func(w http.ResponseWriter, r *http.Request) {
-		// Retrieve the database connection from the request context
-		db := r.Context().Value(app.DbKey).(*sql.DB)
-
-		// Retrieve user data
-		name := r.FormValue("name")
-		email := r.FormValue("email")
-
-		// Save user data to the database
-		err := app.SaveUsersToDB(w, r, db, goth.User{Name: name, Email: email})
-		if err != nil {
-			// Check for a custom error indicating duplicate user
-			if strings.Contains(err.Error(), "user with the same name or email already exists") {
-				// Redirect the user to the "userExists" page
-				http.Redirect(w, r, "/userExists", http.StatusSeeOther)
-				return
-			}
-
-			// Handling other errors by returning an internal server error response
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-			log.Printf("Error saving user data to the database: %v", err)
-			return
-		}
-
-		// Redirect the user to the "complete" page
-		http.Redirect(w, r, "/", http.StatusSeeOther)
+		app.GoogleSignIn(w, r, providers.Providers)
+	}).Methods("GET")
+
+	//Serving the fake OpenID Connect issuer for local development
+	if providers.Fake != nil {
+		router.PathPrefix(oauth.FakePath + "/").Handler(http.StripPrefix(oauth.FakePath, providers.Fake))
+	}
+
+	//Serving the fake S3 storage for local development, it checks the signature of the whole path so the prefix is kept
+	if fakeS3 != nil {
+		router.PathPrefix(blob.FakePath + "/").Handler(fakeS3)
+	}
+
+	//Handling the local accounts that sign in with an email and a password
+	router.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		app.Login(w, r, r.Context().Value(app.StoreKey).(*app.Store), providers.Providers)
+	}).Methods("POST")
+	router.HandleFunc("/register", app.RegisterForm).Methods("GET")
+	router.HandleFunc("/register", func(w http.ResponseWriter, r *http.Request) {
+		app.Register(w, r, r.Context().Value(app.StoreKey).(*app.Store), mail)
+	}).Methods("POST")
+	router.HandleFunc("/verify", func(w http.ResponseWriter, r *http.Request) {
+		app.VerifyEmail(w, r, r.Context().Value(app.StoreKey).(*app.Store))
+	}).Methods("GET")
+	router.HandleFunc("/forgot", app.ForgotForm).Methods("GET")
+	router.HandleFunc("/forgot", func(w http.ResponseWriter, r *http.Request) {
+		app.ForgotPassword(w, r, r.Context().Value(app.StoreKey).(*app.Store), mail)
+	}).Methods("POST")
+	router.HandleFunc("/reset", app.ResetForm).Methods("GET")
+	router.HandleFunc("/reset", func(w http.ResponseWriter, r *http.Request) {
+		app.ResetPassword(w, r, r.Context().Value(app.StoreKey).(*app.Store))
 	}).Methods("POST")
 
-	// Serving static files from the "/css/" directory
-	staticFileDirectory := http.Dir("web/css")
-	router.PathPrefix("/css/").Handler(http.StripPrefix("/css/", http.FileServer(staticFileDirectory)))
+	// Serving static files from the "css" directory of the assets
+	cssFiles, err := fs.Sub(assets, "css")
+	if err != nil {
+		log.Fatal("Error opening the css directory:", err)
+	}
+	router.PathPrefix("/css/").Handler(http.StripPrefix("/css/", http.FileServer(http.FS(cssFiles))))
 
 	//Creating an HTTP server instance with the configured router
 	server := &http.Server{
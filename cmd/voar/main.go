@@ -1,17 +1,25 @@
 package main
 
 import (
-	"VoAr/app"               //Importing the app package (assuming it handles application-specific logic)
-	google "VoAr/pkg/google" //Importing the Google package for authentication
-	"log"                    //Package for logging
+	"VoAr/app"                //Importing the app package (assuming it handles application-specific logic)
+	"VoAr/db/migrations"      //Importing the embedded database migrations
+	store "VoAr/internal/app" //Importing the stores used by the "role" subcommand
+	"VoAr/pkg/oauth"          //Importing the OAuth package that configures the sign-in providers
+	"database/sql"            //Package for the database connection handed to the migrations
+	"fmt"                     //Package for printing the migration status
+	"log"                     //Package for logging
+	"os"                      //Package for reading the command line arguments
+	"time"                    //Package for the session cleanup interval
 
 	"github.com/joho/godotenv" //Package for loading environment variables from a .env file.
 	_ "github.com/lib/pq"      //PostgreSQL driver for the database/sql package
 )
 
 // main function is an the entery point of the application
-// It initializes the database connection, sets up third-party authentication providers
+// It initializes the database connection, applies pending migrations, sets up third-party authentication providers
 // And starts the HTTP server to handle incoming requests
+// Running "voar migrate up|down|status" manages the database schema instead of starting the server
+// And "voar role <email> <role>" changes the role of a user, e.g. to appoint the first admin
 func main() {
 	// Load environment variables from the specified file
 	err := godotenv.Load("st.env")
@@ -26,14 +34,124 @@ func main() {
 	}
 	defer db.Close() //Closing the database connection then main function exits
 
-	//Setting up the Google authentication provider
-	google.Google()
+	//Handling the "migrate" subcommand
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(db, os.Args[2:]); err != nil {
+			log.Fatal("Migration error: ", err)
+		}
+		return
+	}
+
+	//Bringing the database schema up to date before serving requests
+	applied, err := migrations.Up(db)
+	if err != nil {
+		log.Fatal("Error applying migrations: ", err)
+	}
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s", m.Version, m.Name)
+	}
+
+	//Handling the "role" subcommand once the schema has the roles
+	if len(os.Args) > 1 && os.Args[1] == "role" {
+		if err := setRole(db, os.Args[2:]); err != nil {
+			log.Fatal("Role error: ", err)
+		}
+		return
+	}
+
+	//Creating the server-side session store and removing expired sessions every hour
+	sessionStore := app.NewSessionStore(db)
+	go sessionStore.Cleanup(time.Hour)
+
+	//Setting up the authentication providers enabled in the environment
+	providers, err := oauth.Setup(sessionStore)
+	if err != nil {
+		log.Fatal("Error configuring authentication providers: ", err)
+	}
+
+	//Parsing the templates once, a missing template stops the server right away
+	assets, dev := app.Assets()
+	renderer, err := app.NewRenderer(assets, dev)
+	if err != nil {
+		log.Fatal("Error parsing templates: ", err)
+	}
 
-	//Creating the HTTP server with the configured database connection
-	server := app.HandleFunc(db)
+	//Creating the HTTP server with the configured database connection, templates and sign-in providers
+	server := app.HandleFunc(db, assets, renderer, providers)
 
 	//Starting the HTTP server and handling any potential errors
 	if err := server.ListenAndServe(); err != nil {
 		log.Fatal("Server error:", err)
 	}
 }
+
+// migrate runs the "migrate up|down|status" subcommand
+func migrate(db *sql.DB, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: voar migrate up|down|status")
+	}
+
+	switch args[0] {
+	case "up":
+		//Applying every pending migration
+		applied, err := migrations.Up(db)
+		if err != nil {
+			return err
+		}
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+	case "down":
+		//Reverting the latest applied migration
+		reverted, err := migrations.Down(db)
+		if err != nil {
+			return err
+		}
+		if reverted == nil {
+			fmt.Println("no migrations to revert")
+			return nil
+		}
+		fmt.Printf("reverted %04d_%s\n", reverted.Version, reverted.Name)
+	case "status":
+		//Printing every migration with the time it was applied
+		statuses, err := migrations.List(db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+	return nil
+}
+
+// setRole runs the "role <email> <role>" subcommand
+func setRole(db *sql.DB, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: voar role <email> admin|editor|author|reader")
+	}
+	role := store.Role(args[1])
+	if !role.Valid() {
+		return fmt.Errorf("unknown role %q, expected admin, editor, author or reader", args[1])
+	}
+
+	users := store.NewPostgresStore(db).Users
+	user, err := users.FindByEmail(args[0])
+	if err != nil {
+		return fmt.Errorf("finding user %s: %w", args[0], err)
+	}
+	if err := users.SetRole(user.ID, role); err != nil {
+		return err
+	}
+	fmt.Printf("%s is now %s\n", user.Email, role)
+	return nil
+}
@@ -0,0 +1,7 @@
+-- Baseline articles table, identical to the one in db/mydb.sql so that existing databases are left untouched
+CREATE TABLE IF NOT EXISTS articles (
+    id serial PRIMARY KEY,
+    title character varying(100) NOT NULL,
+    anons character varying(250) NOT NULL,
+    full_text text NOT NULL
+);
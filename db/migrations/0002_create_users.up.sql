@@ -0,0 +1,7 @@
+-- Users of the site, an email address belongs to a single user
+CREATE TABLE IF NOT EXISTS users (
+    id serial PRIMARY KEY,
+    name character varying(100) NOT NULL,
+    email character varying(255) NOT NULL UNIQUE,
+    is_admin boolean NOT NULL DEFAULT false
+);
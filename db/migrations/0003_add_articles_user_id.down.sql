@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS articles_user_id_idx;
+
+ALTER TABLE articles DROP COLUMN IF EXISTS user_id;
@@ -0,0 +1,4 @@
+-- Articles are owned by the user who wrote them, older articles keep a NULL author
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS user_id integer REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS articles_user_id_idx ON articles (user_id);
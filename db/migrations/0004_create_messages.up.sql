@@ -0,0 +1,9 @@
+-- Chat history loaded by the chat hub when a client connects
+-- Messages refer to their author by users.id, the email of the author is not kept in the history sent to every client
+CREATE TABLE IF NOT EXISTS messages (
+    id serial PRIMARY KEY,
+    user_name character varying(100) NOT NULL,
+    user_id integer REFERENCES users(id) ON DELETE SET NULL,
+    body text NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
@@ -0,0 +1,16 @@
+-- Local email/password accounts next to the OAuth ones
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at timestamp with time zone;
+
+-- One-time tokens for email verification and password resets, only their SHA-256 hashes are stored
+CREATE TABLE IF NOT EXISTS user_tokens (
+    id serial PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose character varying(20) NOT NULL,
+    token_hash character(64) NOT NULL UNIQUE,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    expires_at timestamp with time zone NOT NULL,
+    used_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS user_tokens_user_id_idx ON user_tokens (user_id);
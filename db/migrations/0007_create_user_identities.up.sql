@@ -0,0 +1,13 @@
+-- Identities of the third-party providers, several identities can sign in to the same user
+CREATE TABLE IF NOT EXISTS user_identities (
+    id serial PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider character varying(50) NOT NULL,
+    provider_user_id character varying(255) NOT NULL,
+    email character varying(255) NOT NULL DEFAULT '',
+    name character varying(255) NOT NULL DEFAULT '',
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    UNIQUE (provider, provider_user_id)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON user_identities (user_id);
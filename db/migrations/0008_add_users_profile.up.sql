@@ -0,0 +1,3 @@
+-- Profile fields copied from the provider on every OAuth sign-in
+ALTER TABLE users ADD COLUMN IF NOT EXISTS avatar_url text;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locale character varying(35);
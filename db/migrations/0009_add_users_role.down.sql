@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin boolean NOT NULL DEFAULT false;
+UPDATE users SET is_admin = (role = 'admin');
+ALTER TABLE users DROP COLUMN IF EXISTS role;
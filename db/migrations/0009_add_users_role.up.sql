@@ -0,0 +1,7 @@
+-- Roles replace the is_admin flag, new users are authors
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role character varying(20) NOT NULL DEFAULT 'author';
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('admin', 'editor', 'author', 'reader'));
+-- Users tables created before 0002 have no is_admin flag, their users all become authors
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_admin boolean NOT NULL DEFAULT false;
+UPDATE users SET role = 'admin' WHERE is_admin;
+ALTER TABLE users DROP COLUMN IF EXISTS is_admin;
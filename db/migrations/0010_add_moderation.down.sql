@@ -0,0 +1,4 @@
+DELETE FROM articles WHERE deleted_at IS NOT NULL;
+ALTER TABLE articles DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE articles DROP COLUMN IF EXISTS hidden_at;
+ALTER TABLE users DROP COLUMN IF EXISTS banned_at;
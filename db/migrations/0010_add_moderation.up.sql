@@ -0,0 +1,4 @@
+-- Banned users cannot sign in, hidden and deleted articles are not listed and can be restored by moderators
+ALTER TABLE users ADD COLUMN IF NOT EXISTS banned_at timestamp with time zone;
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS hidden_at timestamp with time zone;
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS deleted_at timestamp with time zone;
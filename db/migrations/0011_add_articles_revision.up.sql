@@ -0,0 +1,2 @@
+-- Incremented on every edit of an article, the rendered Markdown of the full text is cached per revision
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS revision integer NOT NULL DEFAULT 1;
@@ -0,0 +1,8 @@
+-- Full-text search document of every article, titles weigh most and the full text least
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(anons, '')), 'B') ||
+    setweight(to_tsvector('english', coalesce(full_text, '')), 'C')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS articles_search_idx ON articles USING GIN (search);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS article_tags;
+DROP TABLE IF EXISTS tags;
+ALTER TABLE articles DROP COLUMN IF EXISTS category_id;
+DROP TABLE IF EXISTS categories;
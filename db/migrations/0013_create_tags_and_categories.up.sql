@@ -0,0 +1,28 @@
+-- Every article is filed under at most one category, categories are added by moderators
+CREATE TABLE IF NOT EXISTS categories (
+    id serial PRIMARY KEY,
+    name character varying(50) NOT NULL,
+    slug character varying(60) NOT NULL UNIQUE
+);
+
+INSERT INTO categories (name, slug) VALUES ('General', 'general'), ('News', 'news'), ('Tutorials', 'tutorials')
+ON CONFLICT (slug) DO NOTHING;
+
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS category_id integer REFERENCES categories(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS articles_category_id_idx ON articles (category_id);
+
+-- Tags are created by the authors while writing, an article has any number of tags
+CREATE TABLE IF NOT EXISTS tags (
+    id serial PRIMARY KEY,
+    name character varying(50) NOT NULL,
+    slug character varying(60) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS article_tags (
+    article_id integer NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    tag_id integer NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    PRIMARY KEY (article_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS article_tags_tag_id_idx ON article_tags (tag_id);
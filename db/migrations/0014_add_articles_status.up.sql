@@ -0,0 +1,12 @@
+-- Articles go through the draft, scheduled, published and archived statuses, only published articles are listed
+-- Existing articles were public, so they start out published. New articles are drafts until they are published
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS status character varying(16) NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'scheduled', 'published', 'archived'));
+ALTER TABLE articles ALTER COLUMN status SET DEFAULT 'draft';
+
+-- When the article was or, for scheduled articles, will be published
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS published_at timestamp with time zone;
+UPDATE articles SET published_at = now() WHERE status = 'published' AND published_at IS NULL;
+
+-- The scheduler looks up the scheduled articles that are due
+CREATE INDEX IF NOT EXISTS articles_scheduled_idx ON articles (published_at) WHERE status = 'scheduled';
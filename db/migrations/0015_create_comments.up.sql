@@ -0,0 +1,15 @@
+-- Comments of signed-in users on articles, replies point to the comment they answer
+-- Deleted and hidden comments are kept so that the replies below them stay in place
+CREATE TABLE IF NOT EXISTS comments (
+    id serial PRIMARY KEY,
+    article_id integer NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    parent_id integer REFERENCES comments(id) ON DELETE CASCADE,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body text NOT NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    edited_at timestamp with time zone,
+    hidden_at timestamp with time zone,
+    deleted_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS comments_article_id_idx ON comments (article_id, id);
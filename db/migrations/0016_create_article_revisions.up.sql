@@ -0,0 +1,17 @@
+-- Every create and update of an article stores the title, anons and full text as a new revision
+-- The user is the one who made the change, which is not always the author of the article
+CREATE TABLE IF NOT EXISTS article_revisions (
+    article_id integer NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    revision integer NOT NULL,
+    title character varying(100) NOT NULL,
+    anons character varying(250) NOT NULL,
+    full_text text NOT NULL,
+    user_id integer REFERENCES users(id) ON DELETE SET NULL,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    PRIMARY KEY (article_id, revision)
+);
+
+-- The current state of the existing articles becomes their first recorded revision
+INSERT INTO article_revisions (article_id, revision, title, anons, full_text, user_id)
+SELECT id, revision, title, anons, full_text, user_id FROM articles
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,15 @@
+-- Files uploaded to articles, the content is kept in the blob storage under storage_key
+-- Images get a thumbnail stored under thumbnail_key
+CREATE TABLE IF NOT EXISTS media (
+    id serial PRIMARY KEY,
+    article_id integer NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    user_id integer REFERENCES users(id) ON DELETE SET NULL,
+    filename varchar(255) NOT NULL,
+    content_type varchar(100) NOT NULL,
+    size bigint NOT NULL,
+    storage_key varchar(255) NOT NULL UNIQUE,
+    thumbnail_key varchar(255),
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS media_article_id_idx ON media (article_id, id);
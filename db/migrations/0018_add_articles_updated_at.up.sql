@@ -0,0 +1,6 @@
+-- Last change of the content or the status of an article, the feeds report it as the updated time
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS updated_at timestamp with time zone NOT NULL DEFAULT now();
+
+-- Existing articles were last changed when their current revision was saved, or when they were published after that
+UPDATE articles a SET updated_at = GREATEST(r.created_at, CASE WHEN a.status = 'published' THEN a.published_at END)
+FROM article_revisions r WHERE r.article_id = a.id AND r.revision = a.revision;
@@ -0,0 +1,40 @@
+-- Articles are addressed by a slug derived from their title, e.g. "/articles/hello-world"
+ALTER TABLE articles ADD COLUMN IF NOT EXISTS slug varchar(100);
+
+-- Every slug an article has had, so that links with an earlier slug redirect to the current one
+-- The current slug is recorded too, which keeps slugs unique over all articles and their earlier titles
+CREATE TABLE IF NOT EXISTS article_slugs (
+    slug varchar(100) PRIMARY KEY,
+    article_id integer NOT NULL REFERENCES articles(id) ON DELETE CASCADE,
+    created_at timestamp with time zone NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS article_slugs_article_id_idx ON article_slugs (article_id);
+
+-- Existing articles get the slug of their title like new ones, oldest first: the first free one of "base", "base-2", "base-3" and so on
+-- Every candidate is checked against the slugs claimed so far, so a numbered slug never collides with the title of another article
+DO $$
+DECLARE
+    a record;
+    base text;
+    candidate text;
+    n integer;
+BEGIN
+    FOR a IN SELECT id, title FROM articles WHERE slug IS NULL ORDER BY id LOOP
+        base := trim(BOTH '-' FROM left(trim(BOTH '-' FROM lower(regexp_replace(a.title, '[^[:alnum:]]+', '-', 'g'))), 80));
+        IF base = '' THEN
+            base := 'article';
+        END IF;
+        n := 1;
+        LOOP
+            candidate := CASE WHEN n = 1 THEN base ELSE base || '-' || n END;
+            INSERT INTO article_slugs (slug, article_id) VALUES (candidate, a.id) ON CONFLICT (slug) DO NOTHING;
+            EXIT WHEN FOUND;
+            n := n + 1;
+        END LOOP;
+        UPDATE articles SET slug = candidate WHERE id = a.id;
+    END LOOP;
+END
+$$;
+
+ALTER TABLE articles ALTER COLUMN slug SET NOT NULL;
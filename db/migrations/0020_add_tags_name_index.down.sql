@@ -0,0 +1 @@
+DROP INDEX IF EXISTS tags_lower_name_idx;
@@ -0,0 +1,2 @@
+-- Tags are found by their name ignoring case, different names with the same slug get numbered slugs, e.g. "c" and "c-2"
+CREATE UNIQUE INDEX IF NOT EXISTS tags_lower_name_idx ON tags (lower(name));
@@ -0,0 +1,11 @@
+-- Personal API tokens that scripts and apps send in the Authorization header, only their SHA-256 is stored
+CREATE TABLE IF NOT EXISTS api_tokens (
+    id serial PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name character varying(100) NOT NULL,
+    token_hash char(64) NOT NULL UNIQUE,
+    created_at timestamp with time zone NOT NULL DEFAULT now(),
+    last_used_at timestamp with time zone
+);
+
+CREATE INDEX IF NOT EXISTS api_tokens_user_id_idx ON api_tokens (user_id);
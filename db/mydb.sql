@@ -1,96 +0,0 @@
---
--- PostgreSQL database dump
---
-
--- Dumped from database version 16.0
--- Dumped by pg_dump version 16.0
-
-SET statement_timeout = 0;
-SET lock_timeout = 0;
-SET idle_in_transaction_session_timeout = 0;
-SET client_encoding = 'UTF8';
-SET standard_conforming_strings = on;
-SELECT pg_catalog.set_config('search_path', '', false);
-SET check_function_bodies = false;
-SET xmloption = content;
-SET client_min_messages = warning;
-SET row_security = off;
-
-SET default_tablespace = '';
-
-SET default_table_access_method = heap;
-
---
--- Name: articles; Type: TABLE; Schema: public; Owner: postgres
---
-
-CREATE TABLE public.articles (
-    id integer NOT NULL,
-    title character varying(100) NOT NULL,
-    anons character varying(250) NOT NULL,
-    full_text text NOT NULL
-);
-
-
-ALTER TABLE public.articles OWNER TO postgres;
-
---
--- Name: articles_id_seq; Type: SEQUENCE; Schema: public; Owner: postgres
---
-
-CREATE SEQUENCE public.articles_id_seq
-    AS integer
-    START WITH 1
-    INCREMENT BY 1
-    NO MINVALUE
-    NO MAXVALUE
-    CACHE 1;
-
-
-ALTER SEQUENCE public.articles_id_seq OWNER TO postgres;
-
---
--- Name: articles_id_seq; Type: SEQUENCE OWNED BY; Schema: public; Owner: postgres
---
-
-ALTER SEQUENCE public.articles_id_seq OWNED BY public.articles.id;
-
-
---
--- Name: articles id; Type: DEFAULT; Schema: public; Owner: postgres
---
-
-ALTER TABLE ONLY public.articles ALTER COLUMN id SET DEFAULT nextval('public.articles_id_seq'::regclass);
-
-
---
--- Data for Name: articles; Type: TABLE DATA; Schema: public; Owner: postgres
---
-
-COPY public.articles (id, title, anons, full_text) FROM stdin;
-1	assa	as	as
-2	sa	as	sa
-3	ass	asas	asas
-4	a	a	a
-\.
-
-
---
--- Name: articles_id_seq; Type: SEQUENCE SET; Schema: public; Owner: postgres
---
-
-SELECT pg_catalog.setval('public.articles_id_seq', 4, true);
-
-
---
--- Name: articles articles_pkey; Type: CONSTRAINT; Schema: public; Owner: postgres
---
-
-ALTER TABLE ONLY public.articles
-    ADD CONSTRAINT articles_pkey PRIMARY KEY (id);
-
-
---
--- PostgreSQL database dump complete
---
-
@@ -4,15 +4,17 @@ go 1.19
 
 require (
 	github.com/gorilla/mux v1.8.1
-	github.com/gorilla/pat v1.0.2 // direct
 	github.com/gorilla/sessions v1.2.2
+	github.com/gorilla/websocket v1.5.0
 	github.com/markbates/goth v1.78.0
+	github.com/microcosm-cc/bluemonday v1.0.27
+	github.com/yuin/goldmark v1.7.4
+	golang.org/x/crypto v0.24.0
 )
 
 require (
 	github.com/golang/protobuf v1.5.3 // indirect
-	github.com/gorilla/context v1.1.2 // indirect
-	github.com/gorilla/securecookie v1.1.2 // indirect
+	github.com/gorilla/securecookie v1.1.2
 	github.com/joho/godotenv v1.5.1
 	github.com/lib/pq v1.10.9
 	golang.org/x/oauth2 v0.15.0 // indirect
@@ -20,4 +22,9 @@ require (
 	google.golang.org/protobuf v1.31.0 // indirect
 )
 
-require cloud.google.com/go v0.67.0 // indirect
+require (
+	cloud.google.com/go v0.67.0 // indirect
+	github.com/aymerick/douceur v0.2.0 // indirect
+	github.com/gorilla/css v1.0.1 // indirect
+	golang.org/x/net v0.26.0 // indirect
+)
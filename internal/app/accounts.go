@@ -0,0 +1,326 @@
+package app
+
+import (
+	"VoAr/pkg/mailer"
+	"VoAr/pkg/oauth"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markbates/goth"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	minPasswordLength = 8              //Shortest password accepted for local accounts
+	maxPasswordBytes  = 72             //Longest password bcrypt can hash, longer ones are rejected rather than cut off
+	verifyTokenTTL    = 48 * time.Hour //Lifetime of email verification links
+	resetTokenTTL     = time.Hour      //Lifetime of password reset links
+
+	purposeVerify = "verify" //Token purpose of email verification links
+	purposeReset  = "reset"  //Token purpose of password reset links
+)
+
+// dummyHash is compared against when the email is unknown, so that login takes the same time either way
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("voar-dummy-password"), bcrypt.DefaultCost)
+
+// accountForm is the data of the registration, sign-in and password forms
+type accountForm struct {
+	Error string //Message explaining why the submitted form was rejected
+	Name  string //Submitted name, kept so the user does not have to type it again
+	Email string //Submitted email, kept so the user does not have to type it again
+	Token string //Password reset token carried by the reset form
+}
+
+// signInForm is the data of the sign-in page
+type signInForm struct {
+	accountForm
+	Providers []oauth.Provider //Enabled third-party providers shown as buttons
+	ReturnTo  string           //Page to return to after signing in
+}
+
+// notice is the data of the page that shows a single message to the user
+type notice struct {
+	Title   string
+	Message string
+}
+
+// newToken returns a random token for a link together with the hash that is stored in the database
+func newToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken returns the hex encoded SHA-256 hash of a token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// baseURL returns the public address of the site used in emailed links, feeds and canonical links
+// It only comes from BASE_URL, the Host header of the request is chosen by the client and never trusted.
+// Without BASE_URL the local development address is used, like the OAuth callbacks do
+func baseURL() string {
+	if base := os.Getenv("BASE_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+	return "http://localhost:8080"
+}
+
+// sendTokenLink creates a one-time token for the user and emails a link containing it
+func sendTokenLink(store *Store, m mailer.Mailer, user User, purpose string, ttl time.Duration, path, subject, text string) error {
+	token, hash, err := newToken()
+	if err != nil {
+		return err
+	}
+	if err := store.Tokens.Create(user.ID, purpose, hash, time.Now().Add(ttl)); err != nil {
+		return err
+	}
+
+	link := baseURL() + path + "?token=" + url.QueryEscape(token)
+	return m.Send(mailer.Message{
+		To:      user.Email,
+		Subject: subject,
+		Body:    fmt.Sprintf("Hi %s,\n\n%s\n\n%s\n\nThe link expires in %s. If you did not ask for it, you can ignore this email.\n", user.Name, text, link, ttl),
+	})
+}
+
+// validPassword checks the password and its confirmation, returning a message for the form when they are rejected
+func validPassword(password, confirm string) string {
+	if len(password) < minPasswordLength {
+		return fmt.Sprintf("The password must be at least %d characters long", minPasswordLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Sprintf("The password must not be longer than %d bytes", maxPasswordBytes)
+	}
+	if password != confirm {
+		return "The passwords do not match"
+	}
+	return ""
+}
+
+// RegisterForm is an HTTP handler function for serving the registration page
+func RegisterForm(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "register", accountForm{})
+}
+
+// Register is an HTTP handler function creating a local account with a hashed password
+// The account has to be verified through the emailed link before it can be used to sign in
+func Register(w http.ResponseWriter, r *http.Request, store *Store, m mailer.Mailer) {
+	//Retrieving form values from the request
+	form := accountForm{
+		Name:  strings.TrimSpace(r.FormValue("name")),
+		Email: strings.ToLower(strings.TrimSpace(r.FormValue("email"))),
+	}
+
+	//Validating the submitted values, only the bare address is kept, e.g. "bob@x.com" of "Bob <bob@x.com>"
+	if addr, err := mail.ParseAddress(form.Email); err != nil || form.Name == "" {
+		form.Error = "Please provide your name and a valid email address"
+	} else {
+		form.Email = strings.ToLower(addr.Address)
+		form.Error = validPassword(r.FormValue("password"), r.FormValue("password_confirm"))
+	}
+	if form.Error != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		renderPage(w, r, "register", form)
+		return
+	}
+
+	//Hashing the password, the plain password is never stored
+	hash, err := bcrypt.GenerateFromPassword([]byte(r.FormValue("password")), bcrypt.DefaultCost)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error hashing password: %v", err)
+		return
+	}
+
+	//Saving the new user
+	user := User{Name: form.Name, Email: form.Email, PasswordHash: string(hash)}
+	if err := store.Users.Create(&user); err != nil {
+		if err == ErrUserExists {
+			form.Error = "An account with this email already exists"
+			w.WriteHeader(http.StatusConflict)
+			renderPage(w, r, "register", form)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error saving user: %v", err)
+		return
+	}
+
+	//Sending the verification link
+	err = sendTokenLink(store, m, user, purposeVerify, verifyTokenTTL, "/verify",
+		"Confirm your VoAr account", "Please confirm your email address by opening this link:")
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error sending verification email: %v", err)
+		return
+	}
+
+	renderPage(w, r, "notice", notice{
+		Title:   "Check your email",
+		Message: "We sent a confirmation link to " + user.Email + ". Open it to activate your account.",
+	})
+}
+
+// VerifyEmail is an HTTP handler function confirming the email address with the emailed token
+func VerifyEmail(w http.ResponseWriter, r *http.Request, store *Store) {
+	//Consuming the token, each link works only once
+	userID, err := store.Tokens.Consume(purposeVerify, hashToken(r.FormValue("token")))
+	if err == ErrNotFound {
+		w.WriteHeader(http.StatusBadRequest)
+		renderPage(w, r, "notice", notice{Title: "Invalid link", Message: "The confirmation link is invalid or has expired."})
+		return
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error consuming verification token: %v", err)
+		return
+	}
+
+	if err := store.Users.MarkEmailVerified(userID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error verifying email: %v", err)
+		return
+	}
+	renderPage(w, r, "notice", notice{Title: "Email confirmed", Message: "Your account is active, you can sign in now."})
+}
+
+// Login is an HTTP handler function signing a local account in with its email and password
+// A rejected sign-in shows the sign-in page again, listing the given providers
+func Login(w http.ResponseWriter, r *http.Request, store *Store, providers []oauth.Provider) {
+	form := signInForm{Providers: providers, ReturnTo: safeReturnTo(r.FormValue("return_to"))}
+	form.Email = strings.ToLower(strings.TrimSpace(r.FormValue("email")))
+	password := r.FormValue("password")
+
+	//Looking up the account, unknown emails are compared against a dummy hash to take the same time
+	user, err := store.Users.FindByEmail(form.Email)
+	if err != nil && err != ErrNotFound {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error looking up user: %v", err)
+		return
+	}
+	hasPassword := err == nil && user.PasswordHash != ""
+	hash := dummyHash
+	if hasPassword {
+		hash = []byte(user.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || !hasPassword {
+		form.Error = "Invalid email or password"
+		w.WriteHeader(http.StatusUnauthorized)
+		renderPage(w, r, "googleSignIn", form)
+		return
+	}
+	if user.Banned {
+		w.WriteHeader(http.StatusForbidden)
+		renderPage(w, r, "notice", bannedNotice)
+		return
+	}
+	if !user.EmailVerified {
+		form.Error = "Please confirm your email address first, we sent you a link when you registered"
+		w.WriteHeader(http.StatusForbidden)
+		renderPage(w, r, "googleSignIn", form)
+		return
+	}
+
+	//Signing the user in
+	sessionUser := goth.User{Provider: "local", UserID: strconv.Itoa(user.ID), Name: user.Name, Email: user.Email}
+	if err := SaveSessionUser(w, r, sessionUser, user.ID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error saving session: %v", err)
+		return
+	}
+	http.Redirect(w, r, form.ReturnTo, http.StatusSeeOther)
+}
+
+// ForgotForm is an HTTP handler function for serving the password reset request page
+func ForgotForm(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "forgot", accountForm{})
+}
+
+// ForgotPassword is an HTTP handler function emailing a password reset link
+// It shows the same message whether or not the account exists, so emails cannot be probed
+func ForgotPassword(w http.ResponseWriter, r *http.Request, store *Store, m mailer.Mailer) {
+	email := strings.ToLower(strings.TrimSpace(r.FormValue("email")))
+
+	user, err := store.Users.FindByEmail(email)
+	switch {
+	case err == nil:
+		err = sendTokenLink(store, m, user, purposeReset, resetTokenTTL, "/reset",
+			"Reset your VoAr password", "Somebody asked to reset the password of your account. Choose a new password here:")
+		if err != nil {
+			log.Printf("Error sending password reset email: %v", err)
+		}
+	case err != ErrNotFound:
+		log.Printf("Error looking up user: %v", err)
+	}
+
+	renderPage(w, r, "notice", notice{
+		Title:   "Check your email",
+		Message: "If an account exists for " + email + ", we sent it a link to reset the password.",
+	})
+}
+
+// ResetForm is an HTTP handler function for serving the form that sets a new password
+func ResetForm(w http.ResponseWriter, r *http.Request) {
+	renderPage(w, r, "reset", accountForm{Token: r.FormValue("token")})
+}
+
+// ResetPassword is an HTTP handler function setting a new password with a reset token
+// Every session of the user is ended, so a stolen session does not survive the reset
+func ResetPassword(w http.ResponseWriter, r *http.Request, store *Store) {
+	form := accountForm{Token: r.FormValue("token")}
+	password := r.FormValue("password")
+
+	//Validating the new password before the token is used up
+	if form.Error = validPassword(password, r.FormValue("password_confirm")); form.Error != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		renderPage(w, r, "reset", form)
+		return
+	}
+
+	userID, err := store.Tokens.Consume(purposeReset, hashToken(form.Token))
+	if err == ErrNotFound {
+		w.WriteHeader(http.StatusBadRequest)
+		renderPage(w, r, "notice", notice{Title: "Invalid link", Message: "The password reset link is invalid or has expired."})
+		return
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error consuming reset token: %v", err)
+		return
+	}
+
+	//Saving the new password, reaching the inbox also proves the email address
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err == nil {
+		err = store.Users.SetPassword(userID, string(hash))
+	}
+	if err == nil {
+		err = store.Users.MarkEmailVerified(userID)
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error resetting password: %v", err)
+		return
+	}
+
+	//Signing the user out everywhere
+	revokeSessions(userID)
+
+	renderPage(w, r, "notice", notice{Title: "Password changed", Message: "Your new password is set, you can sign in now."})
+}
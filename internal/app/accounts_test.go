@@ -0,0 +1,156 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"VoAr/pkg/mailer"
+)
+
+// recordingMailer keeps the sent messages instead of sending them
+type recordingMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *recordingMailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// tokenLink matches the token of an emailed link
+var tokenLink = regexp.MustCompile(`token=([A-Za-z0-9_-]+)`)
+
+// lastToken returns the token of the link in the last sent message
+func (m *recordingMailer) lastToken(t *testing.T) string {
+	t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.sent) == 0 {
+		t.Fatal("no email was sent")
+	}
+	match := tokenLink.FindStringSubmatch(m.sent[len(m.sent)-1].Body)
+	if match == nil {
+		t.Fatalf("no link in the email %q", m.sent[len(m.sent)-1].Body)
+	}
+	return match[1]
+}
+
+// form returns a POST request of the user, nil for a guest, submitting the form values
+func (f *fixture) form(target string, user *User, values url.Values) *http.Request {
+	r := httptest.NewRequest("POST", target, strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r.WithContext(f.request("POST", target, user, nil).Context())
+}
+
+func TestRegisterAndVerify(t *testing.T) {
+	useCookieSessions(t)
+	f := newFixture(t)
+	m := &recordingMailer{}
+	account := func(email, password string) url.Values {
+		return url.Values{"name": {"New"}, "email": {email}, "password": {password}, "password_confirm": {password}}
+	}
+
+	tests := []struct {
+		name   string
+		values url.Values
+		want   int
+	}{
+		{"short password", account("new@example.com", "short"), http.StatusBadRequest},
+		{"password longer than bcrypt accepts", account("new@example.com", strings.Repeat("p", maxPasswordBytes+1)), http.StatusBadRequest},
+		{"passwords differ", url.Values{"name": {"New"}, "email": {"new@example.com"}, "password": {"password1"}, "password_confirm": {"password2"}}, http.StatusBadRequest},
+		{"invalid email", account("new", "password1"), http.StatusBadRequest},
+		{"taken email", account("Author@example.com", "password1"), http.StatusConflict},
+		{"new account", account("New <new@example.com>", strings.Repeat("p", maxPasswordBytes)), http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		Register(w, f.form("/register", nil, tt.values), f.store, m)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	//Signing in needs the confirmed email
+	login := url.Values{"email": {"new@example.com"}, "password": {strings.Repeat("p", maxPasswordBytes)}}
+	w := httptest.NewRecorder()
+	Login(w, f.form("/login", nil, login), f.store, nil)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("signing in before confirming: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+
+	//The link confirms the email once
+	token := m.lastToken(t)
+	for i, want := range []int{http.StatusOK, http.StatusBadRequest} {
+		w := httptest.NewRecorder()
+		VerifyEmail(w, f.request("GET", "/verify?token="+token, nil, nil), f.store)
+		if w.Code != want {
+			t.Errorf("confirming %d. time: status %d, want %d", i+1, w.Code, want)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	Login(w, f.form("/login", nil, login), f.store, nil)
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("signing in after confirming: status %d, want %d", w.Code, http.StatusSeeOther)
+	}
+	login.Set("password", "wrong password")
+	w = httptest.NewRecorder()
+	Login(w, f.form("/login", nil, login), f.store, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("signing in with a wrong password: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	useCookieSessions(t)
+	f := newFixture(t)
+	m := &recordingMailer{}
+
+	//Unknown emails get the same answer and no email
+	for _, email := range []string{"nobody@example.com", "author@example.com"} {
+		w := httptest.NewRecorder()
+		ForgotPassword(w, f.form("/forgot", nil, url.Values{"email": {email}}), f.store, m)
+		if w.Code != http.StatusOK {
+			t.Errorf("asking for a reset of %s: status %d, want %d", email, w.Code, http.StatusOK)
+		}
+	}
+	if len(m.sent) != 1 || m.sent[0].To != "author@example.com" {
+		t.Fatalf("sent %+v, want one email to the author", m.sent)
+	}
+	token := m.lastToken(t)
+
+	//A rejected password leaves the token usable
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		want     int
+	}{
+		{"password longer than bcrypt accepts", token, strings.Repeat("p", maxPasswordBytes+1), http.StatusBadRequest},
+		{"unknown token", "unknown", "new password", http.StatusBadRequest},
+		{"new password", token, "new password", http.StatusOK},
+		{"used token", token, "other password", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		values := url.Values{"token": {tt.token}, "password": {tt.password}, "password_confirm": {tt.password}}
+		w := httptest.NewRecorder()
+		ResetPassword(w, f.form("/reset", nil, values), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	Login(w, f.form("/login", nil, url.Values{"email": {"author@example.com"}, "password": {"new password"}}), f.store, nil)
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("signing in with the new password: status %d, want %d", w.Code, http.StatusSeeOther)
+	}
+}
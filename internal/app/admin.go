@@ -0,0 +1,219 @@
+package app
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/markbates/goth/gothic"
+)
+
+// adminPageSize is the number of rows on a page of the admin lists
+const adminPageSize = 20
+
+// adminDashboard is the data of the admin overview page
+type adminDashboard struct {
+	Users      UserStats
+	Articles   ArticleStats
+	Roles      []Role
+	Categories []Category
+}
+
+// adminUsersPage is the data of the user management page
+type adminUsersPage struct {
+	Users []User
+	Roles []Role
+	Pager pager
+}
+
+// adminArticlesPage is the data of the article moderation page
+type adminArticlesPage struct {
+	Articles []Pst
+	Pager    pager
+}
+
+// AdminDashboard is an HTTP handler function for the admin overview with user and article counts
+// The route is wrapped with RequirePermission
+func AdminDashboard(w http.ResponseWriter, r *http.Request, store *Store) {
+	//Counting the users and the articles and listing the categories
+	users, err := store.Users.Stats()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error counting users: %v", err)
+		return
+	}
+	articles, err := store.Articles.Stats()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error counting articles: %v", err)
+		return
+	}
+	categories, err := store.Categories.List()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading categories: %v", err)
+		return
+	}
+	renderPage(w, r, "admin", adminDashboard{Users: users, Articles: articles, Roles: Roles, Categories: categories})
+}
+
+// AdminUsers is an HTTP handler function listing a page of users with their role and ban state
+// The route is wrapped with RequirePermission
+func AdminUsers(w http.ResponseWriter, r *http.Request, store *Store) {
+	page, ok := pageParam(r)
+	if !ok {
+		http.Error(w, "Invalid page parameter", http.StatusBadRequest)
+		return
+	}
+
+	//Querying the store for the counts and the requested page of users
+	stats, err := store.Users.Stats()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error counting users: %v", err)
+		return
+	}
+	users, err := store.Users.List(adminPageSize, (page-1)*adminPageSize)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error listing users: %v", err)
+		return
+	}
+	renderPage(w, r, "adminUsers", adminUsersPage{
+		Users: users,
+		Roles: Roles,
+		Pager: newPager("/admin/users", page, stats.Total, adminPageSize),
+	})
+}
+
+// adminTargetUser loads the user named in the URL for a change made by an admin
+// Admins cannot change their own account, so they cannot lock themselves out
+// It writes the error response itself and reports whether the handler should continue
+func adminTargetUser(w http.ResponseWriter, r *http.Request, store *Store) (User, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return User{}, false
+	}
+	if current := CurrentUser(r); current != nil && current.ID == id {
+		http.Error(w, "You cannot change your own account", http.StatusConflict)
+		return User{}, false
+	}
+	user, err := store.Users.Get(id)
+	if err == ErrNotFound {
+		http.Error(w, "User not found", http.StatusNotFound)
+		return user, false
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading user: %v", err)
+		return user, false
+	}
+	return user, true
+}
+
+// AdminSetBanned is an HTTP handler function banning or unbanning a user
+// A banned user is signed out on every device and cannot sign in again until unbanned
+func AdminSetBanned(w http.ResponseWriter, r *http.Request, store *Store, banned bool) {
+	user, ok := adminTargetUser(w, r, store)
+	if !ok {
+		return
+	}
+	if err := store.Users.SetBanned(user.ID, banned); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error banning user %d: %v", user.ID, err)
+		return
+	}
+	if banned {
+		revokeSessions(user.ID)
+	}
+	redirectToList(w, r, "/admin/users")
+}
+
+// AdminSetRole is an HTTP handler function changing the role of a user to the submitted one
+// The sessions of the user are ended, like on a ban
+func AdminSetRole(w http.ResponseWriter, r *http.Request, store *Store) {
+	role := Role(r.FormValue("role"))
+	if !role.Valid() {
+		http.Error(w, "Unknown role", http.StatusBadRequest)
+		return
+	}
+	user, ok := adminTargetUser(w, r, store)
+	if !ok {
+		return
+	}
+	if err := store.Users.SetRole(user.ID, role); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error changing role of user %d: %v", user.ID, err)
+		return
+	}
+	//Signing the user out everywhere, the next sign-in starts a new session with the new role
+	revokeSessions(user.ID)
+	redirectToList(w, r, "/admin/users")
+}
+
+// AdminArticles is an HTTP handler function listing a page of articles, hidden and deleted ones included
+// The route is wrapped with RequirePermission
+func AdminArticles(w http.ResponseWriter, r *http.Request, store *Store) {
+	page, ok := pageParam(r)
+	if !ok {
+		http.Error(w, "Invalid page parameter", http.StatusBadRequest)
+		return
+	}
+
+	//Querying the store for the counts and the requested page of articles
+	stats, err := store.Articles.Stats()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error counting articles: %v", err)
+		return
+	}
+	articles, err := store.Articles.ListAll(adminPageSize, (page-1)*adminPageSize)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error listing articles: %v", err)
+		return
+	}
+	renderPage(w, r, "adminArticles", adminArticlesPage{
+		Articles: articles,
+		Pager:    newPager("/admin/articles", page, stats.Total, adminPageSize),
+	})
+}
+
+// AdminModerateArticle is an HTTP handler function applying a moderation action to the article named in the URL
+// The action is one of the moderation methods of the article store, e.g. Delete or Restore
+func AdminModerateArticle(w http.ResponseWriter, r *http.Request, action func(id int) error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Article not found", http.StatusNotFound)
+		return
+	}
+	if err := action(id); err != nil {
+		if err == ErrNotFound {
+			http.Error(w, "Article not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error moderating article %d: %v", id, err)
+		return
+	}
+	redirectToList(w, r, "/admin/articles")
+}
+
+// redirectToList redirects back to the admin list at path, keeping the page the form was submitted from
+func redirectToList(w http.ResponseWriter, r *http.Request, path string) {
+	if page, err := strconv.Atoi(r.FormValue("page")); err == nil && page > 1 {
+		path += "?page=" + strconv.Itoa(page)
+	}
+	http.Redirect(w, r, path, http.StatusSeeOther)
+}
+
+// revokeSessions ends every session of the user when the session store supports it
+func revokeSessions(userID int) {
+	if revoker, ok := gothic.Store.(SessionRevoker); ok {
+		if err := revoker.RevokeUser(userID); err != nil {
+			log.Printf("Error revoking sessions of user %d: %v", userID, err)
+		}
+	}
+}
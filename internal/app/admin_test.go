@@ -0,0 +1,140 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestAdminAreaAccess(t *testing.T) {
+	f := newFixture(t)
+	dashboard := RequirePermission(PermAdmin, func(w http.ResponseWriter, r *http.Request) {
+		AdminDashboard(w, r, f.store)
+	})
+
+	//Editors moderate comments but do not reach the admin area
+	tests := []struct {
+		user *User
+		want int
+	}{
+		{nil, http.StatusSeeOther},
+		{f.author, http.StatusForbidden},
+		{f.editor, http.StatusForbidden},
+		{f.admin, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		dashboard(w, f.request("GET", "/admin", tt.user, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", userName(tt.user), w.Code, tt.want)
+		}
+	}
+}
+
+func TestAdminListPages(t *testing.T) {
+	f := newFixture(t)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", http.StatusOK},
+		{"?page=2", http.StatusOK},
+		{"?page=0", http.StatusBadRequest},
+		{"?page=" + strconv.Itoa(maxPage+1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		AdminUsers(w, f.request("GET", "/admin/users"+tt.query, f.admin, nil), f.store)
+		if w.Code != tt.want {
+			t.Errorf("users %q: status %d, want %d", tt.query, w.Code, tt.want)
+		}
+		w = httptest.NewRecorder()
+		AdminArticles(w, f.request("GET", "/admin/articles"+tt.query, f.admin, nil), f.store)
+		if w.Code != tt.want {
+			t.Errorf("articles %q: status %d, want %d", tt.query, w.Code, tt.want)
+		}
+	}
+}
+
+// adminForm returns a form of the admin changing the user or article with the id
+func adminForm(f *fixture, target string, id int, values url.Values) *http.Request {
+	return mux.SetURLVars(f.form(target, f.admin, values), map[string]string{"id": strconv.Itoa(id)})
+}
+
+func TestAdminChangeUsers(t *testing.T) {
+	f := newFixture(t)
+
+	//Admins cannot lock themselves out
+	w := httptest.NewRecorder()
+	AdminSetBanned(w, adminForm(f, "/admin/users/ban", f.admin.ID, nil), f.store, true)
+	if w.Code != http.StatusConflict {
+		t.Errorf("banning oneself: status %d, want %d", w.Code, http.StatusConflict)
+	}
+	w = httptest.NewRecorder()
+	AdminSetBanned(w, adminForm(f, "/admin/users/ban", 999, nil), f.store, true)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("banning a missing user: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	//A banned user is treated as a guest
+	w = httptest.NewRecorder()
+	AdminSetBanned(w, adminForm(f, "/admin/users/ban", f.author.ID, url.Values{"page": {"2"}}), f.store, true)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/users?page=2" {
+		t.Errorf("banning: status %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	if user, err := f.store.Users.Get(f.author.ID); err != nil || !user.Banned || activeUser(user) != nil {
+		t.Errorf("banned user %+v (%v)", user, err)
+	}
+
+	//Roles are changed to known roles only
+	for _, tt := range []struct {
+		role string
+		want int
+	}{
+		{"owner", http.StatusBadRequest},
+		{"editor", http.StatusSeeOther},
+	} {
+		w := httptest.NewRecorder()
+		AdminSetRole(w, adminForm(f, "/admin/users/role", f.reader.ID, url.Values{"role": {tt.role}}), f.store)
+		if w.Code != tt.want {
+			t.Errorf("changing the role to %q: status %d, want %d", tt.role, w.Code, tt.want)
+		}
+	}
+	if user, err := f.store.Users.Get(f.reader.ID); err != nil || user.Role != RoleEditor {
+		t.Errorf("role after the change %q (%v), want %q", user.Role, err, RoleEditor)
+	}
+}
+
+func TestAdminModerateArticle(t *testing.T) {
+	f := newFixture(t)
+
+	for _, tt := range []struct {
+		name   string
+		id     int
+		action func(id int) error
+		want   int
+	}{
+		{"hiding", f.published.Id, func(id int) error { return f.store.Articles.SetHidden(id, true) }, http.StatusSeeOther},
+		{"restoring", f.deleted.Id, f.store.Articles.Restore, http.StatusSeeOther},
+		{"deleting a missing article", 999, f.store.Articles.Delete, http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		AdminModerateArticle(w, adminForm(f, "/admin/articles/moderate", tt.id, nil), tt.action)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	hidden, err := f.store.Articles.Get(f.published.Id)
+	if err != nil || !hidden.Hidden {
+		t.Errorf("hidden article %+v (%v)", hidden, err)
+	}
+	if _, err := f.store.Articles.Get(f.deleted.Id); err != nil {
+		t.Errorf("restored article: %v", err)
+	}
+}
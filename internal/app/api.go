@@ -0,0 +1,320 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPerPage = 10  //Number of articles returned by the API when per_page is not given
+	maxPerPage     = 100 //Largest page size accepted by the API
+
+	maxPage = math.MaxInt32 / maxPerPage //Highest page number accepted, so that the offset of a page never overflows
+)
+
+// apiError is the structured error body returned by every API endpoint
+type apiError struct {
+	Error apiErrorDetail `json:"error"`
+}
+
+// apiErrorDetail describes what went wrong with an API request
+type apiErrorDetail struct {
+	Status  int    `json:"status"`  //HTTP status code of the response
+	Message string `json:"message"` //Human readable description of the error
+}
+
+// articleList is the response body of the article listing endpoint
+type articleList struct {
+	Data       []Pst  `json:"data"`
+	Page       int    `json:"page,omitempty"` //Requested page, left out when the page was selected by a cursor
+	PerPage    int    `json:"per_page"`
+	Total      int    `json:"total"`                 //Number of articles matching the filters over all pages
+	NextCursor string `json:"next_cursor,omitempty"` //Cursor of the following page, left out on the last page
+}
+
+// articleInput is the request body accepted when creating or updating an article
+type articleInput struct {
+	Title       string     `json:"title"`
+	Anons       string     `json:"anons"`
+	Full_Text   string     `json:"full_text"`
+	Category    string     `json:"category"`     //Slug of the category, empty for none
+	Tags        []string   `json:"tags"`         //Tag names, tags that do not exist yet are created
+	Status      string     `json:"status"`       //Status to move the article to, empty keeps it and new articles are drafts
+	PublishedAt *time.Time `json:"published_at"` //Publication time, required when scheduling
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error encoding JSON response: %v", err)
+	}
+}
+
+// writeJSONError writes a structured JSON error instead of the plain text body of http.Error
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiError{Error: apiErrorDetail{Status: status, Message: message}})
+}
+
+// positiveParam parses an optional positive integer query parameter, falling back to def when it is missing
+func positiveParam(r *http.Request, name string, def int) (int, bool) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, false
+	}
+	return n, true
+}
+
+// pageParam parses the optional page query parameter, pages beyond maxPage are rejected
+func pageParam(r *http.Request) (int, bool) {
+	page, ok := positiveParam(r, "page", 1)
+	if !ok || page > maxPage {
+		return 0, false
+	}
+	return page, true
+}
+
+// decodeArticleInput reads and validates the JSON body of a create or update request
+func decodeArticleInput(w http.ResponseWriter, r *http.Request) (articleInput, bool) {
+	var in articleInput
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "Request body must be a JSON object")
+		return in, false
+	}
+	if in.Title == "" || in.Anons == "" || in.Full_Text == "" {
+		writeJSONError(w, http.StatusUnprocessableEntity, "Fields title, anons and full_text are required")
+		return in, false
+	}
+	return in, true
+}
+
+// applyInput copies the fields of the request body to the article and moves it to the requested status
+// It writes a JSON error when they are rejected
+func applyInput(w http.ResponseWriter, store *Store, post *Pst, in articleInput) bool {
+	post.Title, post.Anons, post.Full_Text = in.Title, in.Anons, in.Full_Text
+	message, err := applyTaxonomy(store, post, in.Category, strings.Join(in.Tags, ","))
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		log.Printf("Error resolving article category: %v", err)
+		return false
+	}
+	if message == "" {
+		var publishAt time.Time
+		if in.PublishedAt != nil {
+			publishAt = *in.PublishedAt
+		}
+		message = applyStatus(post, in.Status, publishAt, time.Now())
+	}
+	if message != "" {
+		writeJSONError(w, http.StatusUnprocessableEntity, message)
+		return false
+	}
+	return true
+}
+
+// apiModifiableArticle loads the article named in the URL and checks that the signed-in user may modify it
+// The allowed function is canEdit or canDelete
+// It writes a JSON error response itself and reports whether the handler should continue
+func apiModifiableArticle(w http.ResponseWriter, r *http.Request, store *Store, allowed func(*http.Request, Pst) bool) (Pst, bool) {
+	post, err := findArticle(r, store)
+	if err == ErrNotFound {
+		writeJSONError(w, http.StatusNotFound, "Article not found")
+		return post, false
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		log.Printf("Error loading article: %v", err)
+		return post, false
+	}
+
+	//Guests get 401 so that clients know to authenticate, other users get 403
+	if CurrentUser(r) == nil {
+		writeJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return post, false
+	}
+	if !allowed(r, post) {
+		writeJSONError(w, http.StatusForbidden, "Your role does not allow modifying this article")
+		return post, false
+	}
+	return post, true
+}
+
+// encodeCursor returns the opaque cursor of the page following the article with the given ID
+func encodeCursor(id int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(id)))
+}
+
+// decodeCursor returns the article ID of a cursor returned by encodeCursor
+func decodeCursor(cursor string) (int, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+	id, err := strconv.Atoi(string(raw))
+	return id, err == nil && id > 0
+}
+
+// APIListArticles is an HTTP handler function returning a page of articles as JSON, newest first
+// Published articles are listed together with the unpublished articles of the signed-in user
+// The page and per_page query parameters control the pagination, tag and category filter by slug
+// Instead of a page the cursor parameter can select the page following the one that returned it as next_cursor,
+// Which stays stable while new articles are published
+func APIListArticles(w http.ResponseWriter, r *http.Request, store *Store) {
+	//Validating the pagination parameters
+	page, ok := pageParam(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "Invalid page parameter")
+		return
+	}
+	perPage, ok := positiveParam(r, "per_page", defaultPerPage)
+	if !ok || perPage > maxPerPage {
+		writeJSONError(w, http.StatusBadRequest, "Invalid per_page parameter")
+		return
+	}
+	filter := ArticleFilter{Tag: r.URL.Query().Get("tag"), Category: r.URL.Query().Get("category")}
+	if user := CurrentUser(r); user != nil {
+		filter.Viewer = user.ID
+	}
+	offset := (page - 1) * perPage
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		if r.URL.Query().Get("page") != "" {
+			writeJSONError(w, http.StatusBadRequest, "Use either the page or the cursor parameter")
+			return
+		}
+		if filter.Before, ok = decodeCursor(cursor); !ok {
+			writeJSONError(w, http.StatusBadRequest, "Invalid cursor parameter")
+			return
+		}
+		page, offset = 0, 0
+	}
+
+	//Querying the store for the requested page of articles and one more to know whether another page follows
+	posts, err := store.Articles.List(filter, perPage+1, offset)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		log.Printf("Error executing database query: %v", err)
+		return
+	}
+	total, err := store.Articles.Count(filter)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		log.Printf("Error counting articles: %v", err)
+		return
+	}
+	list := articleList{Data: posts, Page: page, PerPage: perPage, Total: total}
+	if len(posts) > perPage {
+		list.Data = posts[:perPage]
+		list.NextCursor = encodeCursor(posts[perPage-1].Id)
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// csrfTokenBody is the response body of the CSRF token endpoint
+type csrfTokenBody struct {
+	Token string `json:"csrf_token"` //Token to send in the X-CSRF-Token header
+}
+
+// APICSRFToken is an HTTP handler function returning the CSRF token of the browser as JSON
+// Scripts that call the API with the session cookie send it in the X-CSRF-Token header, other sites cannot read it
+func APICSRFToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, csrfTokenBody{Token: csrfToken(r)})
+}
+
+// APIGetArticle is an HTTP handler function returning a single article as JSON
+func APIGetArticle(w http.ResponseWriter, r *http.Request, store *Store) {
+	post, err := findArticle(r, store)
+	if err == ErrNotFound || (err == nil && !canView(r, post)) {
+		writeJSONError(w, http.StatusNotFound, "Article not found")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		log.Printf("Error loading article: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, post)
+}
+
+// APICreateArticle is an HTTP handler function creating an article from a JSON body
+// The signed-in user becomes the author and the created article is returned with status 201
+func APICreateArticle(w http.ResponseWriter, r *http.Request, store *Store) {
+	author := CurrentUser(r)
+	if author == nil {
+		writeJSONError(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+	if !author.Can(PermCreateArticle) {
+		writeJSONError(w, http.StatusForbidden, "Your role does not allow creating articles")
+		return
+	}
+	in, ok := decodeArticleInput(w, r)
+	if !ok {
+		return
+	}
+
+	//Inserting the article and filling in its author fields for the response
+	post := Pst{UserId: author.ID}
+	if !applyInput(w, store, &post, in) {
+		return
+	}
+	if err := store.Articles.Create(&post); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		log.Printf("Error inserting article: %v", err)
+		return
+	}
+	post.AuthorName, post.AuthorEmail = author.Name, author.Email
+
+	w.Header().Set("Location", "/api/v1/articles/"+strconv.Itoa(post.Id))
+	writeJSON(w, http.StatusCreated, post)
+}
+
+// APIUpdateArticle is an HTTP handler function replacing the content of an article from a JSON body
+// Only users allowed to edit the article by their role can update it
+func APIUpdateArticle(w http.ResponseWriter, r *http.Request, store *Store) {
+	post, ok := apiModifiableArticle(w, r, store, canEdit)
+	if !ok {
+		return
+	}
+	in, ok := decodeArticleInput(w, r)
+	if !ok {
+		return
+	}
+
+	//Updating the article and returning its new state
+	if !applyInput(w, store, &post, in) {
+		return
+	}
+	if err := store.Articles.Update(&post, CurrentUser(r).ID); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		log.Printf("Error updating article: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, post)
+}
+
+// APIDeleteArticle is an HTTP handler function deleting an article
+// Only users allowed to delete the article by their role can delete it, a successful call returns 204
+func APIDeleteArticle(w http.ResponseWriter, r *http.Request, store *Store) {
+	post, ok := apiModifiableArticle(w, r, store, canDelete)
+	if !ok {
+		return
+	}
+	if err := store.Articles.Delete(post.Id); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		log.Printf("Error deleting article: %v", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,277 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+// jsonRequest returns a request of the user, nil for a guest, with the JSON body and the route variables set
+func (f *fixture) jsonRequest(method, target string, user *User, vars map[string]string, body string) *http.Request {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	return mux.SetURLVars(r.WithContext(f.request(method, target, user, nil).Context()), vars)
+}
+
+// decodeAPIError decodes the structured error body of an API response
+func decodeAPIError(t *testing.T, w *httptest.ResponseRecorder) apiErrorDetail {
+	t.Helper()
+	var body apiError
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding error body %q: %v", w.Body.String(), err)
+	}
+	return body.Error
+}
+
+func TestAPIListArticlesParams(t *testing.T) {
+	f := newFixture(t)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", http.StatusOK},
+		{"?page=2&per_page=100", http.StatusOK},
+		{"?page=" + strconv.Itoa(maxPage), http.StatusOK},
+		{"?page=0", http.StatusBadRequest},
+		{"?page=-1", http.StatusBadRequest},
+		{"?page=first", http.StatusBadRequest},
+		{"?page=" + strconv.Itoa(maxPage+1), http.StatusBadRequest},
+		{"?page=9223372036854775807&per_page=2", http.StatusBadRequest},
+		{"?per_page=0", http.StatusBadRequest},
+		{"?per_page=101", http.StatusBadRequest},
+		{"?cursor=bad", http.StatusBadRequest},
+		{"?page=1&cursor=" + encodeCursor(5), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		APIListArticles(w, f.request("GET", "/api/v1/articles"+tt.query, nil, nil), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%q: status %d, want %d", tt.query, w.Code, tt.want)
+			continue
+		}
+		if tt.want != http.StatusOK {
+			if detail := decodeAPIError(t, w); detail.Status != tt.want || detail.Message == "" {
+				t.Errorf("%q: error body %+v", tt.query, detail)
+			}
+		}
+	}
+}
+
+func TestAPIArticleAccess(t *testing.T) {
+	f := newFixture(t)
+	body := `{"title":"API article","anons":"Anons","full_text":"Text","status":"published"}`
+
+	//Creating needs a signed-in user whose role may write
+	for _, tt := range []struct {
+		user *User
+		want int
+	}{
+		{nil, http.StatusUnauthorized},
+		{f.reader, http.StatusForbidden},
+		{f.author, http.StatusCreated},
+	} {
+		w := httptest.NewRecorder()
+		APICreateArticle(w, f.jsonRequest("POST", "/api/v1/articles", tt.user, nil, body), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%s creating: status %d, want %d", userName(tt.user), w.Code, tt.want)
+		}
+		if tt.want == http.StatusCreated && !strings.HasPrefix(w.Header().Get("Location"), "/api/v1/articles/") {
+			t.Errorf("%s creating: Location %q", userName(tt.user), w.Header().Get("Location"))
+		}
+	}
+
+	//Unpublished articles are missing for other users
+	id := strconv.Itoa(f.draft.Id)
+	vars := map[string]string{"id": id}
+	for _, tt := range []struct {
+		user *User
+		want int
+	}{
+		{nil, http.StatusNotFound},
+		{f.other, http.StatusNotFound},
+		{f.author, http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		APIGetArticle(w, f.jsonRequest("GET", "/api/v1/articles/"+id, tt.user, vars, ""), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%s reading a draft: status %d, want %d", userName(tt.user), w.Code, tt.want)
+		}
+	}
+
+	//Updating and deleting need the permission for the article
+	id = strconv.Itoa(f.published.Id)
+	vars = map[string]string{"id": id}
+	for _, tt := range []struct {
+		method string
+		user   *User
+		want   int
+	}{
+		{"PUT", nil, http.StatusUnauthorized},
+		{"PUT", f.reader, http.StatusForbidden},
+		{"PUT", f.other, http.StatusForbidden},
+		{"PUT", f.editor, http.StatusOK},
+		{"DELETE", f.other, http.StatusForbidden},
+		{"DELETE", f.author, http.StatusNoContent},
+		{"DELETE", f.author, http.StatusNotFound},
+	} {
+		w := httptest.NewRecorder()
+		r := f.jsonRequest(tt.method, "/api/v1/articles/"+id, tt.user, vars, body)
+		if tt.method == "PUT" {
+			APIUpdateArticle(w, r, f.store)
+		} else {
+			APIDeleteArticle(w, r, f.store)
+		}
+		if w.Code != tt.want {
+			t.Errorf("%s %s: status %d, want %d", userName(tt.user), tt.method, w.Code, tt.want)
+		}
+	}
+}
+
+// apiRouter returns the API routes behind the CSRF and authentication middleware of the site
+func apiRouter(f *fixture) http.Handler {
+	router := mux.NewRouter()
+	router.Use(CSRFMiddleware([]byte("0123456789abcdef0123456789abcdef"), false))
+	router.Use(AuthMiddleware(f.store))
+	router.HandleFunc("/api/v1/articles", func(w http.ResponseWriter, r *http.Request) {
+		APICreateArticle(w, r, f.store)
+	}).Methods("POST")
+	router.HandleFunc("/api/v1/csrf-token", APICSRFToken).Methods("GET")
+	return router
+}
+
+func TestAPIBearerToken(t *testing.T) {
+	useCookieSessions(t)
+	f := newFixture(t)
+	router := apiRouter(f)
+
+	token, hash, err := newToken()
+	if err != nil {
+		t.Fatalf("creating token: %v", err)
+	}
+	saved := APIToken{UserID: f.author.ID, Name: "script", Hash: hash}
+	if err := f.store.APITokens.Create(&saved); err != nil {
+		t.Fatalf("saving token: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"no token and no CSRF token", "", http.StatusForbidden},
+		{"unknown token", "unknown", http.StatusUnauthorized},
+		{"token of the author", token, http.StatusCreated},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/api/v1/articles", strings.NewReader(`{"title":"T","anons":"A","full_text":"F"}`))
+		if tt.token != "" {
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, w.Code, tt.want, w.Body)
+		}
+		if tt.want == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s: no WWW-Authenticate header", tt.name)
+		}
+	}
+
+	used, err := f.store.APITokens.ListByUser(f.author.ID)
+	if err != nil || len(used) != 1 || used[0].LastUsedAt == nil {
+		t.Errorf("tokens after use %+v (%v), want the last use recorded", used, err)
+	}
+
+	//Banned users and revoked tokens are rejected
+	for _, tt := range []struct {
+		name   string
+		change func() error
+	}{
+		{"banned user", func() error { return f.store.Users.SetBanned(f.author.ID, true) }},
+		{"revoked token", func() error {
+			if err := f.store.Users.SetBanned(f.author.ID, false); err != nil {
+				return err
+			}
+			return f.store.APITokens.Revoke(f.author.ID, saved.ID)
+		}},
+	} {
+		if err := tt.change(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		r := httptest.NewRequest("POST", "/api/v1/articles", strings.NewReader(`{}`))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, http.StatusUnauthorized)
+		}
+	}
+}
+
+func TestAPICSRFToken(t *testing.T) {
+	useCookieSessions(t)
+	f := newFixture(t)
+	router := apiRouter(f)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/csrf-token", nil))
+	var body csrfTokenBody
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil || body.Token == "" {
+		t.Fatalf("decoding token: %q (%v)", body.Token, err)
+	}
+
+	//The token passes the CSRF check together with the cookie, the guest is then refused by the handler
+	r := withCookies(httptest.NewRequest("POST", "/api/v1/articles", strings.NewReader(`{}`)), w)
+	r.Header.Set(csrfHeader, body.Token)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("posting with the token: status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestAPITokenSettings(t *testing.T) {
+	f := newFixture(t)
+
+	//The token is shown once on the page answering the form
+	w := httptest.NewRecorder()
+	CreateAPIToken(w, f.form("/settings/tokens", f.author, url.Values{"name": {"script"}}), f.store)
+	if w.Code != http.StatusOK || w.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("creating: status %d, Cache-Control %q", w.Code, w.Header().Get("Cache-Control"))
+	}
+	tokens, err := f.store.APITokens.ListByUser(f.author.ID)
+	if err != nil || len(tokens) != 1 || tokens[0].Name != "script" {
+		t.Fatalf("tokens %+v (%v), want the new one", tokens, err)
+	}
+
+	w = httptest.NewRecorder()
+	CreateAPIToken(w, f.form("/settings/tokens", f.author, url.Values{"name": {" "}}), f.store)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("creating without a name: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+
+	//Only the owner revokes the token
+	id := strconv.Itoa(tokens[0].ID)
+	for _, tt := range []struct {
+		user *User
+		want int
+	}{
+		{f.other, http.StatusNotFound},
+		{f.author, http.StatusSeeOther},
+		{f.author, http.StatusNotFound},
+	} {
+		r := mux.SetURLVars(f.form("/settings/tokens/"+id+"/revoke", tt.user, nil), map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		RevokeAPIToken(w, r, f.store)
+		if w.Code != tt.want {
+			t.Errorf("%s revoking: status %d, want %d", userName(tt.user), w.Code, tt.want)
+		}
+	}
+}
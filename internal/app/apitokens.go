@@ -0,0 +1,117 @@
+package app
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxAPITokens    = 20  //Most API tokens a user can have at the same time
+	maxAPITokenName = 100 //Longest name of an API token, matches the api_tokens.name column
+)
+
+// apiTokenPage is the data of the page showing a newly created API token
+type apiTokenPage struct {
+	Name  string //Name of the token
+	Token string //The token itself, shown only on this page
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header, the second return value reports whether there is one
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+// loadTokenUser resolves the user of an API token, it returns nil when the token is unknown or its user is banned
+func loadTokenUser(store *Store, token string) (*User, error) {
+	saved, err := store.APITokens.Use(hashToken(token))
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	user, err := store.Users.Get(saved.UserID)
+	if err == ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return activeUser(user), nil
+}
+
+// CreateAPIToken is an HTTP handler function creating a personal API token for the signed-in user
+// The token is shown once on the response page, only its hash is stored
+func CreateAPIToken(w http.ResponseWriter, r *http.Request, store *Store) {
+	user, ok := savedUser(w, r)
+	if !ok {
+		return
+	}
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" || utf8.RuneCountInString(name) > maxAPITokenName {
+		http.Error(w, "Please give the token a name of up to 100 characters", http.StatusBadRequest)
+		return
+	}
+
+	//Limiting the number of tokens of the user
+	tokens, err := store.APITokens.ListByUser(user.ID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading API tokens: %v", err)
+		return
+	}
+	if len(tokens) >= maxAPITokens {
+		http.Error(w, "Too many API tokens, please revoke one first", http.StatusConflict)
+		return
+	}
+
+	//Creating the token and storing its hash
+	token, hash, err := newToken()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error creating API token: %v", err)
+		return
+	}
+	if err := store.APITokens.Create(&APIToken{UserID: user.ID, Name: name, Hash: hash}); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error saving API token: %v", err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	renderPage(w, r, "apiToken", apiTokenPage{Name: name, Token: token})
+}
+
+// RevokeAPIToken is an HTTP handler function deleting an API token of the signed-in user
+// Requests sending the token are rejected from then on
+func RevokeAPIToken(w http.ResponseWriter, r *http.Request, store *Store) {
+	user, ok := savedUser(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "API token not found", http.StatusNotFound)
+		return
+	}
+
+	//Deleting the token, tokens of other users are reported as missing
+	if err := store.APITokens.Revoke(user.ID, id); err != nil {
+		if err == ErrNotFound {
+			http.Error(w, "API token not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error revoking API token: %v", err)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
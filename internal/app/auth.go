@@ -0,0 +1,175 @@
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/markbates/goth/gothic"
+)
+
+// UserKey is the context key for passing the signed-in user to handlers
+const UserKey ContextKey = "user"
+
+// AuthMiddleware is middleware that loads the signed-in user from the session into the request context
+// Sessions that do not name an existing users row are cleared and the request is handled as a guest.
+// Requests with an "Authorization: Bearer" header are authenticated by the API token alone, the session is ignored
+// And unknown or revoked tokens are rejected with 401
+func AuthMiddleware(store *Store) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var user *User
+			var err error
+			if token, ok := bearerToken(r); ok {
+				user, err = loadTokenUser(store, token)
+				if err == nil && user == nil {
+					w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+					writeJSONError(w, http.StatusUnauthorized, "Invalid or revoked API token")
+					return
+				}
+			} else {
+				user, err = loadSessionUser(w, r, store)
+			}
+			if err != nil {
+				log.Printf("Error loading signed-in user: %v", err)
+			}
+			if user != nil {
+				r = r.WithContext(context.WithValue(r.Context(), UserKey, user))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// loadSessionUser resolves the user of the session, it returns nil for guests
+// The session has to name the users row of the user, sessions with a missing or unknown user ID are cleared
+// Banned users are treated as guests, so a ban takes effect even on sessions that were not revoked
+func loadSessionUser(w http.ResponseWriter, r *http.Request, store *Store) (*User, error) {
+	_, signedIn := SessionUser(r)
+	id, ok := SessionUserID(r)
+	if !ok {
+		if signedIn {
+			clearSession(w, r)
+		}
+		return nil, nil
+	}
+
+	user, err := store.Users.Get(id)
+	if err == ErrNotFound {
+		clearSession(w, r)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return activeUser(user), nil
+}
+
+// activeUser returns the user, or nil when the user is banned
+func activeUser(user User) *User {
+	if user.Banned {
+		return nil
+	}
+	return &user
+}
+
+// bannedNotice is shown when a banned user tries to sign in
+var bannedNotice = notice{Title: "Account suspended", Message: "This account has been suspended by an administrator."}
+
+// CurrentUser returns the signed-in user loaded by AuthMiddleware, or nil for guests
+func CurrentUser(r *http.Request) *User {
+	user, _ := r.Context().Value(UserKey).(*User)
+	return user
+}
+
+// RequireAuth wraps a handler so that only signed-in users can reach it
+// Guests are redirected to the sign-in page, which sends them back to the page afterwards
+func RequireAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if CurrentUser(r) == nil {
+			http.Redirect(w, r, signInURL(r), http.StatusSeeOther)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// returnToKey is the session value holding the page to return to after an OAuth sign-in
+const returnToKey = "return_to"
+
+// signInURL returns the URL of the sign-in page that returns to the requested page
+// Only GET requests can be repeated after signing in, other requests return to the main page
+func signInURL(r *http.Request) string {
+	if r.Method != http.MethodGet {
+		return "/googleSignIn"
+	}
+	return "/googleSignIn?return_to=" + url.QueryEscape(r.URL.RequestURI())
+}
+
+// safeReturnTo returns the path to redirect to after signing in
+// Only local paths are accepted, so the parameter cannot be used to redirect to other sites
+// Browsers drop control characters and read backslashes as slashes, e.g. "/\t/evil.com" leads to "//evil.com",
+// So paths containing them are rejected as well
+func safeReturnTo(path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.Contains(path, `\`) {
+		return "/"
+	}
+	for _, c := range path {
+		if c < 0x20 || c == 0x7f {
+			return "/"
+		}
+	}
+	u, err := url.Parse(path)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "/"
+	}
+	return path
+}
+
+// BeginAuth is an HTTP handler function starting the sign-in with a third-party provider
+// The return_to parameter is remembered in the session, AuthCallback redirects there when the sign-in completes
+func BeginAuth(w http.ResponseWriter, r *http.Request) {
+	if returnTo := r.FormValue("return_to"); returnTo != "" {
+		session, _ := gothic.Store.Get(r, SessionName)
+		session.Values[returnToKey] = safeReturnTo(returnTo)
+		if err := session.Save(r, w); err != nil {
+			log.Printf("Error saving session: %v", err)
+		}
+	}
+	gothic.BeginAuthHandler(w, r)
+}
+
+// takeReturnTo returns and clears the page stored by BeginAuth, it defaults to the main page
+func takeReturnTo(w http.ResponseWriter, r *http.Request) string {
+	session, err := gothic.Store.Get(r, SessionName)
+	if err != nil {
+		return "/"
+	}
+	returnTo, ok := session.Values[returnToKey].(string)
+	if !ok {
+		return "/"
+	}
+	delete(session.Values, returnToKey)
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Error saving session: %v", err)
+	}
+	return safeReturnTo(returnTo)
+}
+
+// Logout is an HTTP handler function that signs the user out of the current browser
+func Logout(w http.ResponseWriter, r *http.Request) {
+	//Deleting the session together with its cookie
+	session, _ := gothic.Store.Get(r, SessionName)
+	session.Options.MaxAge = -1
+	if err := session.Save(r, w); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error deleting session: %v", err)
+		return
+	}
+
+	//Redirecting the user to the main page
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
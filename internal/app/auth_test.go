@@ -0,0 +1,32 @@
+package app
+
+import "testing"
+
+func TestSafeReturnTo(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/", "/"},
+		{"/articles/hello-world", "/articles/hello-world"},
+		{"/search?q=go&page=2", "/search?q=go&page=2"},
+		{"/show/1#comment-2", "/show/1#comment-2"},
+		{"", "/"},
+		{"articles", "/"},
+		{"https://evil.com", "/"},
+		{"//evil.com", "/"},
+		{"///evil.com", "/"},
+		{`/\evil.com`, "/"},
+		{`/\/evil.com`, "/"},
+		{"/\t/evil.com", "/"},
+		{"/\n/evil.com", "/"},
+		{"/\x7f/evil.com", "/"},
+		{"javascript:alert(1)", "/"},
+		{"/%zz", "/"},
+	}
+	for _, tt := range tests {
+		if got := safeReturnTo(tt.path); got != tt.want {
+			t.Errorf("safeReturnTo(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
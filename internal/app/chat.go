@@ -0,0 +1,38 @@
+package app
+
+import (
+	"VoAr/internal/chat"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+)
+
+// upgrader upgrades chat requests to WebSocket connections
+// The default origin check only accepts connections coming from the same host
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// ChatSocket is an HTTP handler function for the chat WebSocket endpoint
+// It requires a signed-in user, upgrades the connection and hands it over to the hub
+func ChatSocket(w http.ResponseWriter, r *http.Request, hub *chat.Hub) {
+	//Retrieving the signed-in user
+	user := CurrentUser(r)
+	if user == nil {
+		http.Error(w, "Please sign in to use the chat", http.StatusUnauthorized)
+		return
+	}
+
+	//Upgrading the HTTP connection to the WebSocket protocol
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already written an error response to the client
+		log.Printf("Error upgrading chat connection: %v", err)
+		return
+	}
+
+	//Serving the connection until the client disconnects
+	hub.Serve(conn, user.Name, user.ID)
+}
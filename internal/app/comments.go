@@ -0,0 +1,293 @@
+package app
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxCommentLength  = 5000             //Longest comment accepted, in characters
+	commentEditWindow = 15 * time.Minute //How long authors can edit and delete their comments
+)
+
+// Comment is a comment of a user on an article, ParentID is zero for top-level comments
+type Comment struct {
+	ID         int        `json:"id"`
+	ArticleID  int        `json:"article_id"`
+	ParentID   int        `json:"parent_id,omitempty"`
+	UserID     int        `json:"user_id"`
+	AuthorName string     `json:"author_name"`
+	Body       string     `json:"body"`
+	CreatedAt  time.Time  `json:"created_at"`
+	EditedAt   *time.Time `json:"edited_at,omitempty"` //Last edit of the body, nil for unedited comments
+	Hidden     bool       `json:"hidden"`              //Hidden by a moderator, only moderators can read it
+	Deleted    bool       `json:"deleted"`             //Deleted by its author or a moderator
+}
+
+// commentNode is a comment in the comment tree of an article together with what the signed-in user may do with it
+type commentNode struct {
+	Comment
+	Replies   []*commentNode `json:"replies"`
+	CanReply  bool           `json:"-"`
+	CanEdit   bool           `json:"-"`
+	CanDelete bool           `json:"-"`
+	CanHide   bool           `json:"-"`
+	CSRF      string         `json:"-"` //Token of the forms of the actions, the template of a comment cannot reach the page
+}
+
+// withinEditWindow reports whether the comment is recent enough for its author to change it
+func (c Comment) withinEditWindow(now time.Time) bool {
+	return now.Sub(c.CreatedAt) < commentEditWindow
+}
+
+// canEditComment reports whether the user may change the body of the comment: authors can within the edit window
+func canEditComment(user *User, c Comment, now time.Time) bool {
+	return user != nil && user.ID == c.UserID && !c.Deleted && !c.Hidden && c.withinEditWindow(now)
+}
+
+// canDeleteComment reports whether the user may delete the comment
+// Authors can within the edit window, moderators can at any time
+func canDeleteComment(user *User, c Comment, now time.Time) bool {
+	return !c.Deleted && (user.Can(PermModerate) || canEditComment(user, c, now))
+}
+
+// commentTree arranges the comments of an article into threads as seen by the user, oldest first
+// Hidden comments are only readable by moderators, deleted comments and the hidden comments other users see
+// Are reduced to a placeholder without author and body, or left out when nobody replied to them
+func commentTree(comments []Comment, user *User, open bool, now time.Time) []*commentNode {
+	moderator := user.Can(PermModerate)
+	nodes := make(map[int]*commentNode, len(comments))
+	for _, c := range comments {
+		node := &commentNode{Comment: c, Replies: []*commentNode{}}
+		node.CanReply = open && user != nil && !c.Deleted && !c.Hidden
+		node.CanEdit = canEditComment(user, c, now)
+		node.CanDelete = canDeleteComment(user, c, now)
+		node.CanHide = moderator && !c.Deleted
+		if c.Deleted || (c.Hidden && !moderator) {
+			node.UserID, node.AuthorName, node.Body, node.EditedAt = 0, "", "", nil
+		}
+		nodes[c.ID] = node
+	}
+
+	//Attaching the replies to their parents, the comments are ordered by ID so parents come first
+	roots := []*commentNode{}
+	for _, c := range comments {
+		node := nodes[c.ID]
+		if parent, ok := nodes[c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+	return pruneComments(roots, moderator)
+}
+
+// pruneComments leaves out the deleted comments, and the hidden comments when the user is not a moderator,
+// That have no replies left
+func pruneComments(nodes []*commentNode, moderator bool) []*commentNode {
+	kept := []*commentNode{}
+	for _, node := range nodes {
+		node.Replies = pruneComments(node.Replies, moderator)
+		removed := node.Deleted || (node.Hidden && !moderator)
+		if !removed || len(node.Replies) > 0 {
+			kept = append(kept, node)
+		}
+	}
+	return kept
+}
+
+// commentsOpen reports whether the article accepts new comments, only published articles do
+func commentsOpen(post Pst) bool {
+	return post.Public()
+}
+
+// loadComments returns the comment tree of the article as seen by the signed-in user
+func loadComments(r *http.Request, store *Store, post Pst) ([]*commentNode, error) {
+	comments, err := store.Comments.ListByArticle(post.Id)
+	if err != nil {
+		return nil, err
+	}
+	nodes := commentTree(comments, CurrentUser(r), commentsOpen(post), time.Now())
+	setCommentCSRF(nodes, csrfToken(r))
+	return nodes, nil
+}
+
+// setCommentCSRF passes the CSRF token of the page to the comments and their replies
+func setCommentCSRF(nodes []*commentNode, token string) {
+	for _, node := range nodes {
+		node.CSRF = token
+		setCommentCSRF(node.Replies, token)
+	}
+}
+
+// commentBody validates the submitted body of a comment, it returns a message for the client when it is rejected
+func commentBody(r *http.Request) (string, string) {
+	body := strings.TrimSpace(r.FormValue("body"))
+	if body == "" || len([]rune(body)) > maxCommentLength {
+		return "", "Please write a comment of up to 5000 characters"
+	}
+	return body, ""
+}
+
+// commentAnchor returns the URL of the comment on the article page
+// The address by ID redirects to the current address of the article, browsers keep the fragment
+func commentAnchor(c Comment) string {
+	return "/show/" + strconv.Itoa(c.ArticleID) + "#comment-" + strconv.Itoa(c.ID)
+}
+
+// CreateComment is an HTTP handler function adding a comment or, with the parent_id form value, a reply to an article
+// The route is wrapped with RequireAuth, only published articles accept comments
+func CreateComment(w http.ResponseWriter, r *http.Request, store *Store) {
+	//Loading the article, articles the user cannot see are not found
+	post, ok := viewableArticle(w, r, store)
+	if !ok {
+		return
+	}
+	if !commentsOpen(post) {
+		http.Error(w, "Comments are only open on published articles", http.StatusForbidden)
+		return
+	}
+
+	//Validating the body and the comment replied to
+	body, message := commentBody(r)
+	if message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+	comment := Comment{ArticleID: post.Id, Body: body}
+	if parent := r.FormValue("parent_id"); parent != "" {
+		id, err := strconv.Atoi(parent)
+		if err != nil {
+			http.Error(w, "Invalid parent_id parameter", http.StatusBadRequest)
+			return
+		}
+		replied, err := store.Comments.Get(id)
+		if err == ErrNotFound || (err == nil && (replied.ArticleID != post.Id || replied.Deleted || replied.Hidden)) {
+			http.Error(w, "The comment you replied to does not exist", http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			log.Printf("Error loading comment: %v", err)
+			return
+		}
+		comment.ParentID = replied.ID
+	}
+
+	//Saving the comment of the signed-in author
+	comment.UserID = CurrentUser(r).ID
+	if err := store.Comments.Create(&comment); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error creating comment: %v", err)
+		return
+	}
+	http.Redirect(w, r, commentAnchor(comment), http.StatusSeeOther)
+}
+
+// loadComment loads the comment named in the URL and checks that the signed-in user may change it
+// The allowed function is canEditComment or canDeleteComment
+// It writes the error response itself and reports whether the handler should continue
+func loadComment(w http.ResponseWriter, r *http.Request, store *Store, allowed func(*User, Comment, time.Time) bool) (Comment, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return Comment{}, false
+	}
+	comment, err := store.Comments.Get(id)
+	if err == ErrNotFound {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return comment, false
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading comment: %v", err)
+		return comment, false
+	}
+	if !allowed(CurrentUser(r), comment, time.Now()) {
+		http.Error(w, "Comments can only be changed by their author for 15 minutes", http.StatusForbidden)
+		return comment, false
+	}
+	return comment, true
+}
+
+// UpdateComment is an HTTP handler function replacing the body of a comment within the edit window of its author
+func UpdateComment(w http.ResponseWriter, r *http.Request, store *Store) {
+	comment, ok := loadComment(w, r, store, canEditComment)
+	if !ok {
+		return
+	}
+	body, message := commentBody(r)
+	if message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return
+	}
+	if err := store.Comments.Update(comment.ID, body); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error updating comment: %v", err)
+		return
+	}
+	http.Redirect(w, r, commentAnchor(comment), http.StatusSeeOther)
+}
+
+// DeleteComment is an HTTP handler function deleting a comment
+// Authors can delete their comments within the edit window, moderators can delete every comment
+func DeleteComment(w http.ResponseWriter, r *http.Request, store *Store) {
+	comment, ok := loadComment(w, r, store, canDeleteComment)
+	if !ok {
+		return
+	}
+	if err := store.Comments.Delete(comment.ID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error deleting comment: %v", err)
+		return
+	}
+	http.Redirect(w, r, "/show/"+strconv.Itoa(comment.ArticleID)+"#comments", http.StatusSeeOther)
+}
+
+// ModerateComment is an HTTP handler function hiding or showing a comment, the route is wrapped with RequirePermission
+func ModerateComment(w http.ResponseWriter, r *http.Request, store *Store, hidden bool) {
+	comment, ok := loadComment(w, r, store, func(*User, Comment, time.Time) bool { return true })
+	if !ok {
+		return
+	}
+	err := store.Comments.SetHidden(comment.ID, hidden)
+	if err == ErrNotFound {
+		//Deleted comments cannot be moderated any more
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error moderating comment: %v", err)
+		return
+	}
+	http.Redirect(w, r, commentAnchor(comment), http.StatusSeeOther)
+}
+
+// APIListComments is an HTTP handler function returning the comment tree of an article as JSON
+func APIListComments(w http.ResponseWriter, r *http.Request, store *Store) {
+	post, err := findArticle(r, store)
+	if err == ErrNotFound || (err == nil && !canView(r, post)) {
+		writeJSONError(w, http.StatusNotFound, "Article not found")
+		return
+	}
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		log.Printf("Error loading article: %v", err)
+		return
+	}
+	comments, err := loadComments(r, store, post)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		log.Printf("Error loading comments: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		Data []*commentNode `json:"data"`
+	}{comments})
+}
@@ -0,0 +1,199 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCommentPermissions(t *testing.T) {
+	f := newFixture(t)
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	comment := Comment{UserID: f.reader.ID, CreatedAt: created}
+	soon, late := created.Add(time.Minute), created.Add(commentEditWindow)
+	deleted := comment
+	deleted.Deleted = true
+
+	tests := []struct {
+		name      string
+		user      *User
+		comment   Comment
+		now       time.Time
+		canEdit   bool
+		canDelete bool
+	}{
+		{"author within the window", f.reader, comment, soon, true, true},
+		{"author after the window", f.reader, comment, late, false, false},
+		{"other user", f.other, comment, soon, false, false},
+		{"guest", nil, comment, soon, false, false},
+		{"moderator after the window", f.editor, comment, late, false, true},
+		{"moderator on a deleted comment", f.editor, deleted, soon, false, false},
+	}
+	for _, tt := range tests {
+		if got := canEditComment(tt.user, tt.comment, tt.now); got != tt.canEdit {
+			t.Errorf("%s: can edit %v, want %v", tt.name, got, tt.canEdit)
+		}
+		if got := canDeleteComment(tt.user, tt.comment, tt.now); got != tt.canDelete {
+			t.Errorf("%s: can delete %v, want %v", tt.name, got, tt.canDelete)
+		}
+	}
+}
+
+// commentForm returns a form request of the user for the comment or article route with the ID in the URL
+func (f *fixture) commentForm(target string, user *User, id int, values url.Values) *http.Request {
+	return mux.SetURLVars(f.form(target, user, values), map[string]string{"id": strconv.Itoa(id)})
+}
+
+// listComments returns the comment tree of the article as the user sees it through the API
+func (f *fixture) listComments(t *testing.T, articleID int, user *User) []*commentNode {
+	t.Helper()
+	id := strconv.Itoa(articleID)
+	w := httptest.NewRecorder()
+	APIListComments(w, f.request("GET", "/api/v1/articles/"+id+"/comments", user, map[string]string{"id": id}), f.store)
+	var body struct {
+		Data []*commentNode `json:"data"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding comments: %v", err)
+	}
+	return body.Data
+}
+
+func TestCreateComment(t *testing.T) {
+	f := newFixture(t)
+
+	tests := []struct {
+		name    string
+		user    *User
+		article int
+		values  url.Values
+		want    int
+	}{
+		{"comment", f.reader, f.published.Id, url.Values{"body": {"First"}}, http.StatusSeeOther},
+		{"empty comment", f.reader, f.published.Id, url.Values{"body": {"  "}}, http.StatusBadRequest},
+		{"reply", f.other, f.published.Id, url.Values{"body": {"Reply"}, "parent_id": {"1"}}, http.StatusSeeOther},
+		{"reply to an unknown comment", f.other, f.published.Id, url.Values{"body": {"Reply"}, "parent_id": {"99"}}, http.StatusBadRequest},
+		{"draft of another author", f.reader, f.draft.Id, url.Values{"body": {"Hidden"}}, http.StatusNotFound},
+		{"own draft", f.author, f.draft.Id, url.Values{"body": {"Early"}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		CreateComment(w, f.commentForm("/comments", tt.user, tt.article, tt.values), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	//The reply is threaded under the comment
+	nodes := f.listComments(t, f.published.Id, nil)
+	if len(nodes) != 1 || nodes[0].Body != "First" || len(nodes[0].Replies) != 1 || nodes[0].Replies[0].Body != "Reply" {
+		t.Errorf("comment tree %+v, want the comment with its reply", nodes)
+	}
+}
+
+func TestChangeComment(t *testing.T) {
+	f := newFixture(t)
+	comment := Comment{ArticleID: f.published.Id, UserID: f.reader.ID, Body: "First"}
+	if err := f.store.Comments.Create(&comment); err != nil {
+		t.Fatalf("creating comment: %v", err)
+	}
+	edit := url.Values{"body": {"Edited"}}
+
+	for _, tt := range []struct {
+		user *User
+		want int
+	}{
+		{f.other, http.StatusForbidden},
+		{f.editor, http.StatusForbidden},
+		{f.reader, http.StatusSeeOther},
+	} {
+		w := httptest.NewRecorder()
+		UpdateComment(w, f.commentForm("/comments/edit", tt.user, comment.ID, edit), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%s editing: status %d, want %d", userName(tt.user), w.Code, tt.want)
+		}
+	}
+	if saved, err := f.store.Comments.Get(comment.ID); err != nil || saved.Body != "Edited" || saved.EditedAt == nil {
+		t.Errorf("comment after editing %+v (%v)", saved, err)
+	}
+
+	//After the edit window only moderators delete the comment
+	comments := f.store.Comments.(*memCommentStore)
+	comments.mu.Lock()
+	old := comments.byID[comment.ID]
+	old.CreatedAt = old.CreatedAt.Add(-commentEditWindow)
+	comments.byID[comment.ID] = old
+	comments.mu.Unlock()
+
+	w := httptest.NewRecorder()
+	UpdateComment(w, f.commentForm("/comments/edit", f.reader, comment.ID, edit), f.store)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("editing after the window: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+	for _, tt := range []struct {
+		user *User
+		want int
+	}{
+		{f.reader, http.StatusForbidden},
+		{f.editor, http.StatusSeeOther},
+		{f.editor, http.StatusForbidden},
+	} {
+		w := httptest.NewRecorder()
+		DeleteComment(w, f.commentForm("/comments/delete", tt.user, comment.ID, nil), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%s deleting: status %d, want %d", userName(tt.user), w.Code, tt.want)
+		}
+	}
+}
+
+func TestModerateComment(t *testing.T) {
+	f := newFixture(t)
+	comment := Comment{ArticleID: f.published.Id, UserID: f.reader.ID, Body: "Rude"}
+	if err := f.store.Comments.Create(&comment); err != nil {
+		t.Fatalf("creating comment: %v", err)
+	}
+	reply := Comment{ArticleID: f.published.Id, ParentID: comment.ID, UserID: f.other.ID, Body: "Reply"}
+	if err := f.store.Comments.Create(&reply); err != nil {
+		t.Fatalf("creating reply: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	ModerateComment(w, f.commentForm("/comments/hide", f.editor, comment.ID, nil), f.store, true)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("hiding: status %d, want %d", w.Code, http.StatusSeeOther)
+	}
+
+	//Other users see a placeholder keeping the reply, moderators the hidden comment
+	for _, tt := range []struct {
+		user *User
+		body string
+	}{
+		{nil, ""},
+		{f.reader, ""},
+		{f.editor, "Rude"},
+	} {
+		nodes := f.listComments(t, f.published.Id, tt.user)
+		if len(nodes) != 1 || !nodes[0].Hidden || nodes[0].Body != tt.body || len(nodes[0].Replies) != 1 {
+			t.Errorf("%s sees %+v, want the hidden comment with body %q and its reply", userName(tt.user), nodes, tt.body)
+		}
+	}
+
+	//Hidden comments cannot be replied to, restoring them shows them again
+	w = httptest.NewRecorder()
+	values := url.Values{"body": {"Another reply"}, "parent_id": {strconv.Itoa(comment.ID)}}
+	CreateComment(w, f.commentForm("/comments", f.other, f.published.Id, values), f.store)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("replying to a hidden comment: status %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	w = httptest.NewRecorder()
+	ModerateComment(w, f.commentForm("/comments/show", f.editor, comment.ID, nil), f.store, false)
+	if nodes := f.listComments(t, f.published.Id, nil); w.Code != http.StatusSeeOther || len(nodes) != 1 || nodes[0].Body != "Rude" {
+		t.Errorf("restoring: status %d, guests see %+v", w.Code, nodes)
+	}
+}
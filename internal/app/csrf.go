@@ -0,0 +1,153 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/securecookie"
+)
+
+const (
+	csrfCookie    = "csrf"         //Cookie holding the signed CSRF token of the browser
+	csrfField     = "csrf_token"   //Form field carrying the token, added to every form by the templates
+	csrfHeader    = "X-CSRF-Token" //Header carrying the token in requests made by scripts and API clients
+	csrfTokenSize = 32             //Length of the token in bytes
+	csrfMaxAge    = 30 * 24 * 3600 //Lifetime of the cookie in seconds, a new token is issued afterwards
+	csrfFormLimit = 1 << 20        //Largest form without files that is read to find the token
+	csrfPeekLimit = 16 << 10       //Bytes of a multipart form that are read to find the token in its first field
+)
+
+// CSRFKey is the context key for passing the CSRF token of the browser to renderPage
+const CSRFKey ContextKey = "csrf"
+
+// CSRFMiddleware is middleware protecting every state-changing request against cross-site request forgery
+// Every browser gets a random token in a signed cookie. Requests with another method than GET, HEAD, OPTIONS or TRACE
+// Have to send the token in the csrf_token form field or the X-CSRF-Token header, which other sites cannot read.
+// Requests to paths starting with one of the exempt prefixes are not checked, e.g. the fake issuers called by the server itself.
+// Neither are requests with an API token, AuthMiddleware ignores the cookies of those and browsers never add the header
+func CSRFMiddleware(key []byte, secure bool, exempt ...string) mux.MiddlewareFunc {
+	codec := securecookie.New(key, nil).MaxAge(csrfMaxAge)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, prefix := range exempt {
+				if strings.HasPrefix(r.URL.Path, prefix+"/") {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			if _, ok := bearerToken(r); ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			//Reading the token of the browser, a missing or invalid cookie is replaced with a new token
+			var token []byte
+			if cookie, err := r.Cookie(csrfCookie); err == nil {
+				codec.Decode(csrfCookie, cookie.Value, &token)
+			}
+			if len(token) != csrfTokenSize {
+				token = make([]byte, csrfTokenSize)
+				if _, err := rand.Read(token); err != nil {
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					log.Printf("Error creating CSRF token: %v", err)
+					return
+				}
+				encoded, err := codec.Encode(csrfCookie, token)
+				if err != nil {
+					http.Error(w, "Internal server error", http.StatusInternalServerError)
+					log.Printf("Error encoding CSRF cookie: %v", err)
+					return
+				}
+				http.SetCookie(w, &http.Cookie{
+					Name: csrfCookie, Value: encoded, Path: "/", MaxAge: csrfMaxAge,
+					HttpOnly: true, Secure: secure, SameSite: http.SameSiteLaxMode,
+				})
+			}
+
+			//Checking the token of state-changing requests
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			default:
+				sent := r.Header.Get(csrfHeader)
+				if sent == "" {
+					sent = formCSRFToken(w, r)
+				}
+				if !validCSRFToken(token, sent) {
+					http.Error(w, "Invalid or missing CSRF token, please reload the page and try again", http.StatusForbidden)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), CSRFKey, token)))
+		})
+	}
+}
+
+// csrfToken returns the token the pages put into their forms, empty when CSRFMiddleware is not used
+// The token of the browser is masked with a new random pad every time, so the pages never contain the same bytes
+func csrfToken(r *http.Request) string {
+	token, _ := r.Context().Value(CSRFKey).([]byte)
+	if len(token) == 0 {
+		return ""
+	}
+	masked := make([]byte, 2*len(token))
+	if _, err := rand.Read(masked[:len(token)]); err != nil {
+		log.Printf("Error masking CSRF token: %v", err)
+		return ""
+	}
+	for i, b := range token {
+		masked[len(token)+i] = b ^ masked[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+// validCSRFToken reports whether the masked token sent with the request is the token of the browser
+func validCSRFToken(token []byte, sent string) bool {
+	masked, err := base64.RawURLEncoding.DecodeString(sent)
+	if err != nil || len(masked) != 2*len(token) {
+		return false
+	}
+	unmasked := make([]byte, len(token))
+	for i := range unmasked {
+		unmasked[i] = masked[i] ^ masked[len(token)+i]
+	}
+	return subtle.ConstantTimeCompare(unmasked, token) == 1
+}
+
+// formCSRFToken returns the token sent in the csrf_token field of the form, the body is read with a small limit
+// Forms without files are parsed whole, up to csrfFormLimit. Of multipart forms only the first field is read,
+// The forms put the token first, so the files that follow are left for the handler, which applies its own limits
+func formCSRFToken(w http.ResponseWriter, r *http.Request) string {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/form-data" {
+		r.Body = http.MaxBytesReader(w, r.Body, csrfFormLimit)
+		if err := r.ParseForm(); err != nil {
+			return ""
+		}
+		return r.PostForm.Get(csrfField)
+	}
+
+	//Reading the first part and putting the bytes read back in front of the body
+	var peeked bytes.Buffer
+	reader := multipart.NewReader(io.TeeReader(io.LimitReader(r.Body, csrfPeekLimit), &peeked), params["boundary"])
+	token := ""
+	if part, err := reader.NextPart(); err == nil && part.FormName() == csrfField {
+		value, _ := io.ReadAll(io.LimitReader(part, int64(base64.RawURLEncoding.EncodedLen(2*csrfTokenSize)+1)))
+		token = string(value)
+	}
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(&peeked, r.Body), r.Body}
+	return token
+}
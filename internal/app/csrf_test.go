@@ -0,0 +1,166 @@
+package app
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// csrfHandler returns the CSRF middleware around a handler that writes the token a page would put into its forms
+func csrfHandler() http.Handler {
+	return CSRFMiddleware([]byte("0123456789abcdef0123456789abcdef"), false, "/fake-s3")(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(csrfToken(r)))
+		}))
+}
+
+// csrfPage loads a page and returns the cookie and the form token of a new browser
+func csrfPage(t *testing.T, h http.Handler) (*http.Cookie, string) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == csrfCookie {
+			return cookie, w.Body.String()
+		}
+	}
+	t.Fatal("no CSRF cookie was set")
+	return nil, ""
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	h := csrfHandler()
+	cookie, token := csrfPage(t, h)
+	otherCookie, otherToken := csrfPage(t, h)
+
+	//Every page gets a differently masked token of the same browser
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Body.String() == token || len(w.Result().Cookies()) != 0 {
+		t.Error("a second page repeated the token or replaced the cookie")
+	}
+
+	form := func(token string) *strings.Reader {
+		return strings.NewReader(url.Values{csrfField: {token}, "body": {"hi"}}.Encode())
+	}
+	tests := []struct {
+		name   string
+		cookie *http.Cookie
+		token  string
+		header bool
+		want   int
+	}{
+		{"form token", cookie, token, false, http.StatusOK},
+		{"header token", cookie, token, true, http.StatusOK},
+		{"missing token", cookie, "", false, http.StatusForbidden},
+		{"missing cookie", nil, token, false, http.StatusForbidden},
+		{"token of another browser", cookie, otherToken, false, http.StatusForbidden},
+		{"cookie of another browser", otherCookie, token, true, http.StatusForbidden},
+		{"garbage token", cookie, "not-a-token", true, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("POST", "/delete/1", form(tt.token))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if tt.header {
+			r = httptest.NewRequest("DELETE", "/api/v1/articles/1", nil)
+			r.Header.Set(csrfHeader, tt.token)
+		}
+		if tt.cookie != nil {
+			r.AddCookie(tt.cookie)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
+
+func TestCSRFMiddlewareMultipart(t *testing.T) {
+	cookie, token := csrfPage(t, csrfHandler())
+
+	//The handler still reads the whole form after the middleware read the token from its first field
+	var got string
+	h := CSRFMiddleware([]byte("0123456789abcdef0123456789abcdef"), false)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				t.Errorf("parsing the form after the middleware: %v", err)
+				return
+			}
+			file, _, err := r.FormFile("files")
+			if err != nil {
+				t.Errorf("reading the file after the middleware: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(file)
+			got = r.FormValue("title") + " " + string(data)
+		}))
+
+	for _, tt := range []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"token first", token, http.StatusOK},
+		{"token missing", "", http.StatusForbidden},
+	} {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if tt.token != "" {
+			mw.WriteField(csrfField, tt.token)
+		}
+		mw.WriteField("title", "Notes")
+		file, _ := mw.CreateFormFile("files", "notes.txt")
+		file.Write(bytes.Repeat([]byte("n"), 64<<10))
+		mw.Close()
+
+		r := httptest.NewRequest("POST", "/save_article", &body)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		r.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+	if want := "Notes " + strings.Repeat("n", 64<<10); got != want {
+		t.Errorf("handler read a form of %d bytes, want the whole form", len(got))
+	}
+}
+
+// TestCSRFMiddlewareLargeForm checks that a large form is not read whole before the token is checked
+func TestCSRFMiddlewareLargeForm(t *testing.T) {
+	h := csrfHandler()
+	cookie, token := csrfPage(t, h)
+
+	body := url.Values{"body": {strings.Repeat("x", csrfFormLimit)}, csrfField: {token}}.Encode()
+	r := httptest.NewRequest("POST", "/login", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("form over the limit: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
+
+func TestCSRFMiddlewareExempt(t *testing.T) {
+	w := httptest.NewRecorder()
+	csrfHandler().ServeHTTP(w, httptest.NewRequest("PUT", "/fake-s3/voar/media/1", strings.NewReader("data")))
+	if w.Code != http.StatusOK {
+		t.Errorf("exempt path: status %d, want %d", w.Code, http.StatusOK)
+	}
+
+	w = httptest.NewRecorder()
+	csrfHandler().ServeHTTP(w, httptest.NewRequest("PUT", "/fake-s3-other/voar", strings.NewReader("data")))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("path sharing the exempt prefix: status %d, want %d", w.Code, http.StatusForbidden)
+	}
+}
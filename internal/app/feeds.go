@@ -0,0 +1,356 @@
+package app
+
+import (
+	"VoAr/internal/markdown"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	feedSize  = 20     //Number of the newest articles in every feed
+	feedAge   = 5 * 60 //How long clients and proxies may reuse a feed before revalidating it, in seconds
+	siteTitle = "VoAr" //Name of the site in the titles of the feeds
+)
+
+// feedSource is an article listing offered as a feed
+type feedSource struct {
+	Title  string        //Title of the feed
+	Path   string        //Path of the listing page the feed belongs to
+	Filter ArticleFilter //Filter of the listing, the feeds only contain published articles
+}
+
+// feedPath returns the path of the feeds of the listing page without the format extension, e.g. "/tag/go/feed"
+func feedPath(listPath string) string {
+	if listPath == "/post" {
+		return "/feed"
+	}
+	return listPath + "/feed"
+}
+
+// feedEntry is an article of a feed with its absolute URL, its rendered full text and when it was published
+// The ID is the address of the article by its ID, which redirects to the URL and stays the same when the slug changes
+type feedEntry struct {
+	Pst
+	ID        string
+	URL       string
+	HTML      string
+	Published time.Time
+}
+
+// feedLabels returns the category and the tag names of the article
+func (e feedEntry) feedLabels() []string {
+	var labels []string
+	if e.Category != nil {
+		labels = append(labels, e.Category.Name)
+	}
+	for _, tag := range e.Tags {
+		labels = append(labels, tag.Name)
+	}
+	return labels
+}
+
+// Feed is an HTTP handler function serving the newest articles as RSS, Atom or JSON Feed, the format is named in the URL
+func Feed(w http.ResponseWriter, r *http.Request, store *Store, cache *markdown.Cache) {
+	serveFeed(w, r, store, cache, feedSource{Title: siteTitle, Path: "/post"})
+}
+
+// TagFeed is an HTTP handler function serving the feed of the articles with the tag named in the URL
+func TagFeed(w http.ResponseWriter, r *http.Request, store *Store, cache *markdown.Cache) {
+	tag, ok := loadTag(w, r, store)
+	if !ok {
+		return
+	}
+	serveFeed(w, r, store, cache, feedSource{Title: siteTitle + ": " + tag.Name, Path: "/tag/" + tag.Slug, Filter: ArticleFilter{Tag: tag.Slug}})
+}
+
+// CategoryFeed is an HTTP handler function serving the feed of the articles of the category named in the URL
+func CategoryFeed(w http.ResponseWriter, r *http.Request, store *Store, cache *markdown.Cache) {
+	category, ok := loadCategory(w, r, store)
+	if !ok {
+		return
+	}
+	serveFeed(w, r, store, cache, feedSource{
+		Title: siteTitle + ": " + category.Name, Path: "/category/" + category.Slug, Filter: ArticleFilter{Category: category.Slug},
+	})
+}
+
+// AuthorFeed is an HTTP handler function serving the feed of the articles of the user named in the URL
+func AuthorFeed(w http.ResponseWriter, r *http.Request, store *Store, cache *markdown.Cache) {
+	author, ok := loadAuthor(w, r, store)
+	if !ok {
+		return
+	}
+	serveFeed(w, r, store, cache, feedSource{
+		Title: siteTitle + ": " + author.Name, Path: "/author/" + strconv.Itoa(author.ID), Filter: ArticleFilter{Author: author.ID},
+	})
+}
+
+// serveFeed writes the newest published articles of the listing in the format named in the URL
+// The ETag is the hash of the feed and Last-Modified the latest change of any article, so unchanged feeds
+// Are answered with 304 Not Modified. The latest change of the listed articles is not used for Last-Modified,
+// It goes backwards when an article leaves the feed. Unpublished articles are never included, whoever asks
+func serveFeed(w http.ResponseWriter, r *http.Request, store *Store, cache *markdown.Cache, src feedSource) {
+	posts, err := store.Articles.List(src.Filter, feedSize, 0)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error listing articles for a feed: %v", err)
+		return
+	}
+	lastChange, err := store.Articles.LastChange()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error finding the last change of the articles: %v", err)
+		return
+	}
+
+	//Rendering the full text of the articles and finding the latest change, which is the updated time of the feed
+	base := baseURL()
+	entries := make([]feedEntry, len(posts))
+	var updated time.Time
+	for i, post := range posts {
+		html, err := cache.Render(post.Id, post.Revision, post.Full_Text)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			log.Printf("Error rendering article %d: %v", post.Id, err)
+			return
+		}
+		entries[i] = feedEntry{Pst: post, ID: base + "/show/" + strconv.Itoa(post.Id), URL: base + post.URL(), HTML: string(html), Published: post.UpdatedAt}
+		if post.PublishedAt != nil {
+			entries[i].Published = *post.PublishedAt
+		}
+		if post.UpdatedAt.After(updated) {
+			updated = post.UpdatedAt
+		}
+	}
+
+	//Encoding the feed in the requested format
+	self := base + feedPath(src.Path) + "." + mux.Vars(r)["format"]
+	var body []byte
+	var contentType string
+	switch mux.Vars(r)["format"] {
+	case "rss":
+		body, err = rssFeed(src, base, self, updated, entries)
+		contentType = "application/rss+xml; charset=utf-8"
+	case "atom":
+		body, err = atomFeed(src, base, self, updated, entries)
+		contentType = "application/atom+xml; charset=utf-8"
+	default:
+		body, err = jsonFeed(src, base, self, entries)
+		contentType = "application/feed+json; charset=utf-8"
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error encoding feed %s: %v", self, err)
+		return
+	}
+
+	//Answering conditional requests, http.ServeContent compares If-None-Match with the ETag and If-Modified-Since with the last change
+	sum := sha256.Sum256(body)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(feedAge))
+	http.ServeContent(w, r, "", lastChange, bytes.NewReader(body))
+}
+
+// rssDocument is an RSS 2.0 feed, the full text is in content:encoded and the author name in dc:creator
+type rssDocument struct {
+	XMLName       xml.Name  `xml:"rss"`
+	Version       string    `xml:"version,attr"`
+	AtomNS        string    `xml:"xmlns:atom,attr"`
+	ContentNS     string    `xml:"xmlns:content,attr"`
+	DublinCoreNS  string    `xml:"xmlns:dc,attr"`
+	Title         string    `xml:"channel>title"`
+	Link          string    `xml:"channel>link"`
+	Description   string    `xml:"channel>description"`
+	Self          xmlLink   `xml:"channel>atom:link"`
+	LastBuildDate string    `xml:"channel>lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"channel>item"`
+}
+
+// rssItem is an article of an RSS feed
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        string   `xml:"guid"`
+	Description string   `xml:"description"`
+	Content     string   `xml:"content:encoded"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	PubDate     string   `xml:"pubDate"`
+}
+
+// xmlLink is an Atom link element, also used for the self link of RSS feeds
+type xmlLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// rssFeed encodes the entries as an RSS 2.0 feed
+func rssFeed(src feedSource, base, self string, updated time.Time, entries []feedEntry) ([]byte, error) {
+	doc := rssDocument{
+		Version:      "2.0",
+		AtomNS:       "http://www.w3.org/2005/Atom",
+		ContentNS:    "http://purl.org/rss/1.0/modules/content/",
+		DublinCoreNS: "http://purl.org/dc/elements/1.1/",
+		Title:        src.Title,
+		Link:         base + src.Path,
+		Description:  "The newest articles of " + src.Title,
+		Self:         xmlLink{Href: self, Rel: "self", Type: "application/rss+xml"},
+		Items:        []rssItem{},
+	}
+	if !updated.IsZero() {
+		doc.LastBuildDate = updated.UTC().Format(time.RFC1123Z)
+	}
+	for _, e := range entries {
+		doc.Items = append(doc.Items, rssItem{
+			Title: e.Title, Link: e.URL, GUID: e.ID, Description: e.Anons, Content: e.HTML, Creator: e.AuthorName,
+			Categories: e.feedLabels(), PubDate: e.Published.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return encodeXML(doc)
+}
+
+// atomDocument is an Atom feed, xml:base resolves the relative links of the rendered articles, e.g. to uploaded images
+type atomDocument struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Base    string      `xml:"xml:base,attr"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []xmlLink   `xml:"link"`
+	Author  atomPerson  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomEntry is an article of an Atom feed
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       xmlLink        `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     *atomPerson    `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary"`
+	Content    atomContent    `xml:"content"`
+}
+
+// atomPerson is the author of an Atom feed or entry
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+// atomCategory is a category or a tag of an Atom entry
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// atomContent is the full text of an Atom entry as escaped HTML
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// atomFeed encodes the entries as an Atom feed, entries without an author fall back to the site as the feed author
+func atomFeed(src feedSource, base, self string, updated time.Time, entries []feedEntry) ([]byte, error) {
+	doc := atomDocument{
+		Base:    base + "/",
+		Title:   src.Title,
+		ID:      base + src.Path,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []xmlLink{
+			{Href: self, Rel: "self", Type: "application/atom+xml"},
+			{Href: base + src.Path, Rel: "alternate", Type: "text/html"},
+		},
+		Author:  atomPerson{Name: siteTitle},
+		Entries: []atomEntry{},
+	}
+	for _, e := range entries {
+		entry := atomEntry{
+			Title:     e.Title,
+			ID:        e.ID,
+			Link:      xmlLink{Href: e.URL, Rel: "alternate", Type: "text/html"},
+			Published: e.Published.UTC().Format(time.RFC3339),
+			Updated:   e.UpdatedAt.UTC().Format(time.RFC3339),
+			Summary:   e.Anons,
+			Content:   atomContent{Type: "html", Body: e.HTML},
+		}
+		if e.AuthorName != "" {
+			entry.Author = &atomPerson{Name: e.AuthorName}
+		}
+		for _, label := range e.feedLabels() {
+			entry.Categories = append(entry.Categories, atomCategory{Term: label})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return encodeXML(doc)
+}
+
+// encodeXML encodes the feed document with the XML declaration
+func encodeXML(doc interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// jsonFeedDocument is a JSON Feed version 1.1, see https://jsonfeed.org/version/1.1
+type jsonFeedDocument struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+// jsonFeedItem is an article of a JSON Feed
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	Summary       string           `json:"summary"`
+	ContentHTML   string           `json:"content_html"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+// jsonFeedAuthor is the author of a JSON Feed item
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+// jsonFeed encodes the entries as a JSON Feed
+func jsonFeed(src feedSource, base, self string, entries []feedEntry) ([]byte, error) {
+	doc := jsonFeedDocument{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       src.Title,
+		HomePageURL: base + src.Path,
+		FeedURL:     self,
+		Items:       []jsonFeedItem{},
+	}
+	for _, e := range entries {
+		item := jsonFeedItem{
+			ID: e.ID, URL: e.URL, Title: e.Title, Summary: e.Anons, ContentHTML: e.HTML,
+			DatePublished: e.Published.UTC().Format(time.RFC3339), DateModified: e.UpdatedAt.UTC().Format(time.RFC3339),
+			Tags: e.feedLabels(),
+		}
+		if e.AuthorName != "" {
+			item.Authors = []jsonFeedAuthor{{Name: e.AuthorName}}
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
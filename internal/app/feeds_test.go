@@ -0,0 +1,113 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"VoAr/internal/markdown"
+)
+
+// feed requests the feed of the format for the user with the extra request headers
+func (f *fixture) feed(format string, user *User, header http.Header) *httptest.ResponseRecorder {
+	r := f.request("GET", "/feed."+format, user, map[string]string{"format": format})
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	Feed(w, r, f.store, markdown.NewCache(10))
+	return w
+}
+
+func TestFeedFormats(t *testing.T) {
+	f := newFixture(t)
+
+	//The author is signed in, the feeds still leave out the unpublished articles
+	for format, contentType := range map[string]string{
+		"rss":  "application/rss+xml",
+		"atom": "application/atom+xml",
+		"json": "application/feed+json",
+	} {
+		w := f.feed(format, f.author, nil)
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), contentType) {
+			t.Errorf("%s: status %d, Content-Type %q", format, w.Code, w.Header().Get("Content-Type"))
+			continue
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, "Published article") {
+			t.Errorf("%s: the published article is missing", format)
+		}
+		for _, title := range []string{"Draft article", "Hidden article", "Deleted article"} {
+			if strings.Contains(body, title) {
+				t.Errorf("%s: lists %q", format, title)
+			}
+		}
+	}
+
+	var doc jsonFeedDocument
+	if err := json.NewDecoder(f.feed("json", nil, nil).Body).Decode(&doc); err != nil {
+		t.Fatalf("decoding JSON Feed: %v", err)
+	}
+	if len(doc.Items) != 1 || doc.Items[0].ContentHTML != "<p>Full <em>text</em></p>\n" || !strings.HasSuffix(doc.FeedURL, "/feed.json") {
+		t.Errorf("JSON Feed %+v, want the rendered published article", doc)
+	}
+}
+
+func TestFeedConditional(t *testing.T) {
+	f := newFixture(t)
+	first := f.feed("atom", nil, nil)
+	etag, modified := first.Header().Get("ETag"), first.Header().Get("Last-Modified")
+	if etag == "" || modified == "" || first.Header().Get("Cache-Control") == "" {
+		t.Fatalf("headers %v, want ETag, Last-Modified and Cache-Control", first.Header())
+	}
+
+	for name, header := range map[string]http.Header{
+		"If-None-Match":     {"If-None-Match": {etag}},
+		"If-Modified-Since": {"If-Modified-Since": {modified}},
+	} {
+		if w := f.feed("atom", nil, header); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("%s: status %d with %d bytes, want %d", name, w.Code, w.Body.Len(), http.StatusNotModified)
+		}
+	}
+
+	//Changing an article changes the feed
+	post := f.published
+	post.Title = "Changed article"
+	if err := f.store.Articles.Update(&post, f.author.ID); err != nil {
+		t.Fatalf("updating article: %v", err)
+	}
+	w := f.feed("atom", nil, http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Changed article") {
+		t.Errorf("after a change: status %d, want %d with the new title", w.Code, http.StatusOK)
+	}
+}
+
+func TestFilteredFeeds(t *testing.T) {
+	f := newFixture(t)
+	f.tagArticle(t, "Tagged article", "Go", nil)
+	cache := markdown.NewCache(10)
+
+	tests := []struct {
+		name    string
+		handler func(http.ResponseWriter, *http.Request, *Store, *markdown.Cache)
+		vars    map[string]string
+		want    int
+	}{
+		{"tag", TagFeed, map[string]string{"slug": "go", "format": "rss"}, http.StatusOK},
+		{"unknown tag", TagFeed, map[string]string{"slug": "rust", "format": "rss"}, http.StatusNotFound},
+		{"unknown category", CategoryFeed, map[string]string{"slug": "go", "format": "rss"}, http.StatusNotFound},
+		{"unknown author", AuthorFeed, map[string]string{"id": "99", "format": "rss"}, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.handler(w, f.request("GET", "/feed", nil, tt.vars), f.store, cache)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+		if tt.want == http.StatusOK && (!strings.Contains(w.Body.String(), "Tagged article") || strings.Contains(w.Body.String(), "Published article")) {
+			t.Errorf("%s: feed misses the tagged article or lists others", tt.name)
+		}
+	}
+}
@@ -1,100 +1,102 @@
 package app
 
 import (
-	"html/template"
+	"VoAr/pkg/oauth"
 	"log"
 	"net/http"
+	"time"
 )
 
 // contextKey is a custom type for convience when using it in yhe context
 type ContextKey string
 
-// dbkey is the context key for passing the database instance to handlers
-const DbKey ContextKey = "db"
-
 // User represents a structure for storing user data
 type User struct {
-	ID    int
-	Name  string
-	Email string
+	ID            int
+	Name          string
+	Email         string
+	Role          Role   //Role deciding the permissions of the user
+	PasswordHash  string //Bcrypt hash of the password of local accounts, empty for OAuth-only users
+	EmailVerified bool   //Whether the user confirmed the email address
+	AvatarURL     string //Picture of the user reported by the provider of the last OAuth sign-in
+	Locale        string //Preferred language reported by the provider, e.g. "en" or "de-AT"
+	Banned        bool   //Banned users are treated as guests and cannot sign in
+}
+
+// Identity is an account of a third-party provider linked to a user
+type Identity struct {
+	ID             int
+	UserID         int
+	Provider       string //Name of the goth provider, e.g. "google"
+	ProviderUserID string //ID of the user at the provider
+	Email          string //Email reported by the provider when the identity was linked
+	Name           string //Name reported by the provider when the identity was linked
+	CreatedAt      time.Time
+}
+
+// APIToken is a personal access token a user creates to call the JSON API from scripts and apps
+type APIToken struct {
+	ID         int
+	UserID     int
+	Name       string //Name given by the user, e.g. the app using the token
+	Hash       string //SHA-256 of the token, the token itself is only shown once when it is created
+	CreatedAt  time.Time
+	LastUsedAt *time.Time //When the token last authenticated a request, nil when it was never used
 }
 
 // Post represents a structure for storing arcticle data
 type Pst struct {
-	Id        int    //Unique identifier for the arcticle
-	Title     string //Title of the arcticle
-	Anons     string //Brief summary or announcement of the article
-	Full_Text string //Full text content of the article
+	Id          int        `json:"id"`           //Unique identifier for the arcticle
+	Title       string     `json:"title"`        //Title of the arcticle
+	Slug        string     `json:"slug"`         //Unique address of the article derived from its title, set by the store
+	Anons       string     `json:"anons"`        //Brief summary or announcement of the article
+	Full_Text   string     `json:"full_text"`    //Full text content of the article
+	UserId      int        `json:"user_id"`      //Identifier of the user who wrote the article
+	AuthorName  string     `json:"author_name"`  //Name of the user who wrote the article
+	AuthorEmail string     `json:"-"`            //Email of the user who wrote the article, never shown to other users
+	Revision    int        `json:"revision"`     //Incremented on every edit, the rendered Markdown is cached per revision
+	Category    *Category  `json:"category"`     //Category the article is filed under, nil when it has none
+	Tags        []Tag      `json:"tags"`         //Tags of the article ordered by name
+	Status      Status     `json:"status"`       //Publication status, only published articles are listed
+	PublishedAt *time.Time `json:"published_at"` //When the article was or will be published, nil for drafts
+	UpdatedAt   time.Time  `json:"updated_at"`   //Last change of the content or the status of the article
+	Hidden      bool       `json:"-"`            //Hidden by a moderator, only the author and moderators can see it
+	Deleted     bool       `json:"-"`            //Deleted articles are kept until a moderator restores or purges them
 }
 
-var (
-	posts     = []Pst{} //Global slice to store multiple article instances
-	showItems = Pst{}   //Global varriable to store a single article instance for display
-)
-
 // mainPage is an HTTP handler function for serving the main page.
-// It parses the template files for the main page, header, and footer,
-// Handles potential errors, and executes the main page template
+// It executes the main page template, which includes the header and footer
 func MainPage(w http.ResponseWriter, r *http.Request) {
-	// Parsing HTML template files for the main page, header, and footer
-	// Замените строки загрузки шаблонов в mainPage, chat и complete функциях
-	t, err := template.ParseFiles("web/templates/mainPage.html", "web/templates/header.html", "web/templates/footer.html")
-	if err != nil {
-		// Handling template parsing error by returning an internal server error response
-		http.Error(w, "Error interesting data", http.StatusInternalServerError)
-		log.Printf("Error scanning data into database: %v", err)
-		return
-	}
-
-	// Executing the main page template and writing the output to the response writer
-	t.ExecuteTemplate(w, "mainPage", nil)
+	renderPage(w, r, "mainPage", nil)
 }
 
 // examples is an HTTP handler function for serving the examples page.
-// It parses the template files for the examples page, header, and footer,
-// Handles potential errors, and executes the examples page template.
+// It executes the examples page template, which includes the header and footer
 func Examples(w http.ResponseWriter, r *http.Request) {
-	// Parsing HTML template files for the examples page, header, and footer
-	t, err := template.ParseFiles("web/templates/examples.html", "web/templates/header.html", "web/templates/footer.html")
-	if err != nil {
-		// Handling template parsing error by returning an internal server error response
-		http.Error(w, "Error interesting data", http.StatusInternalServerError)
-		log.Printf("Error scanning data into database: %v", err)
-		return
-	}
-	// Executing the examples page template and writing the output to the response writer
-	t.ExecuteTemplate(w, "examples", nil)
+	renderPage(w, r, "examples", nil)
 }
 
 // create is an HTTP handler function for serving the create page.
-// It parses the template files for the create page, header, and footer,
-// Handles potential errors, and executes the create page template.
-func Create(w http.ResponseWriter, r *http.Request) {
-	// Parsing HTML template files for the create page, header, and footer
-	t, err := template.ParseFiles("web/templates/create.html", "web/templates/header.html", "web/templates/footer.html")
+// It executes the create page template, which includes the header and footer
+func Create(w http.ResponseWriter, r *http.Request, store *Store) {
+	form, err := newArticleForm(store, Pst{})
 	if err != nil {
-		// Handling template parsing error by returning an internal server error response
-		http.Error(w, "Error interesting data", http.StatusInternalServerError)
-		log.Printf("Error scanning data into database: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading categories: %v", err)
 		return
 	}
+	renderPage(w, r, "create", form)
+}
 
-	// Executing the create page template and writing the output to the response writer
-	t.ExecuteTemplate(w, "create", nil)
+// GoogleSignIn is an HTTP handler function for serving the sign-in page
+// The page offers the email and password form together with a button for every enabled provider
+func GoogleSignIn(w http.ResponseWriter, r *http.Request, providers []oauth.Provider) {
+	renderPage(w, r, "googleSignIn", signInForm{Providers: providers, ReturnTo: safeReturnTo(r.FormValue("return_to"))})
 }
 
 // chat is an HTTP handler function for serving the chat page
-// It parses the template files handles potential errors, and executes the chat templates
+// It executes the chat template with the signed-in user, guests are asked to sign in first
 func Chat(w http.ResponseWriter, r *http.Request) {
-	//Parsing HTML template files for the chat page, header, and footer
-	t, err := template.ParseFiles("web/templates/chat.html", "web/templates/header.html", "web/templates/footer.html")
-	if err != nil {
-		//Handling template parsing error by returning an internal server error response
-		http.Error(w, "Error interesting data", http.StatusInternalServerError)
-		log.Printf("Error scanning data into database: %v", err)
-		return
-	}
-
-	//Executing the chat template and writing the output to the response writer
-	t.ExecuteTemplate(w, "chat", nil)
+	//Executing the chat template, the signed-in user is available to it through the page
+	renderPage(w, r, "chat", nil)
 }
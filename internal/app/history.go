@@ -0,0 +1,147 @@
+package app
+
+import (
+	"VoAr/internal/diff"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// historyPage is the data of the revision history page of an article
+type historyPage struct {
+	Pst
+	Revisions  []ArticleRevision //Revisions of the article, newest first
+	CanRestore bool              //Whether the signed-in user may restore an older revision
+}
+
+// diffSection is the line diff of one field of an article between two revisions
+type diffSection struct {
+	Name    string
+	Lines   []diff.Line
+	Changed bool
+}
+
+// diffPage is the data of the page comparing two revisions of an article
+type diffPage struct {
+	Pst
+	From     ArticleRevision
+	To       ArticleRevision
+	Sections []diffSection
+}
+
+// viewableArticle loads the article named in the URL, articles the signed-in user cannot see are not found
+// It writes the error response itself and reports whether the handler should continue
+func viewableArticle(w http.ResponseWriter, r *http.Request, store *Store) (Pst, bool) {
+	post, err := findArticle(r, store)
+	if err == ErrNotFound || (err == nil && !canView(r, post)) {
+		http.Error(w, "Article not found", http.StatusNotFound)
+		return post, false
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading article: %v", err)
+		return post, false
+	}
+	return post, true
+}
+
+// History is an HTTP handler function listing the revisions of an article with the user who saved them and when
+func History(w http.ResponseWriter, r *http.Request, store *Store) {
+	post, ok := viewableArticle(w, r, store)
+	if !ok {
+		return
+	}
+	revisions, err := store.Articles.Revisions(post.Id)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading revisions of article %d: %v", post.Id, err)
+		return
+	}
+	renderPage(w, r, "history", historyPage{Pst: post, Revisions: revisions, CanRestore: canEdit(r, post)})
+}
+
+// loadRevision loads the given revision of the article, a missing revision is reported as not found
+// It writes the error response itself and reports whether the handler should continue
+func loadRevision(w http.ResponseWriter, store *Store, post Pst, revision int) (ArticleRevision, bool) {
+	rev, err := store.Articles.GetRevision(post.Id, revision)
+	if err == ErrNotFound {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return rev, false
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading revision %d of article %d: %v", revision, post.Id, err)
+		return rev, false
+	}
+	return rev, true
+}
+
+// Diff is an HTTP handler function showing the line diff of the title, anons and full text between two revisions
+// The from and to parameters name the revisions, by default the current revision is compared with the one before it
+func Diff(w http.ResponseWriter, r *http.Request, store *Store) {
+	post, ok := viewableArticle(w, r, store)
+	if !ok {
+		return
+	}
+	to, ok := positiveParam(r, "to", post.Revision)
+	if !ok {
+		http.Error(w, "Invalid to parameter", http.StatusBadRequest)
+		return
+	}
+	defaultFrom := to - 1
+	if defaultFrom < 1 {
+		defaultFrom = 1
+	}
+	from, ok := positiveParam(r, "from", defaultFrom)
+	if !ok {
+		http.Error(w, "Invalid from parameter", http.StatusBadRequest)
+		return
+	}
+
+	//Loading both revisions and comparing them field by field
+	page := diffPage{Pst: post}
+	if page.From, ok = loadRevision(w, store, post, from); !ok {
+		return
+	}
+	if page.To, ok = loadRevision(w, store, post, to); !ok {
+		return
+	}
+	for _, field := range []struct{ name, old, new string }{
+		{"Title", page.From.Title, page.To.Title},
+		{"Anons", page.From.Anons, page.To.Anons},
+		{"Full text", page.From.Full_Text, page.To.Full_Text},
+	} {
+		lines := diff.Lines(field.old, field.new)
+		page.Sections = append(page.Sections, diffSection{Name: field.name, Lines: lines, Changed: diff.Changed(lines)})
+	}
+	renderPage(w, r, "diff", page)
+}
+
+// RestoreRevision is an HTTP handler function bringing back the title, anons and full text of an older revision
+// The restored text is saved as a new revision, so the history keeps every change. The category, tags and status stay
+// Only users allowed to edit the article by their role can restore it
+func RestoreRevision(w http.ResponseWriter, r *http.Request, store *Store) {
+	post, ok := loadModifiableArticle(w, r, store, canEdit)
+	if !ok {
+		return
+	}
+	revision, err := strconv.Atoi(mux.Vars(r)["revision"])
+	if err != nil {
+		http.Error(w, "Revision not found", http.StatusNotFound)
+		return
+	}
+	rev, ok := loadRevision(w, store, post, revision)
+	if !ok {
+		return
+	}
+
+	post.Title, post.Anons, post.Full_Text = rev.Title, rev.Anons, rev.Full_Text
+	if err := store.Articles.Update(&post, CurrentUser(r).ID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error restoring revision %d of article %d: %v", revision, post.Id, err)
+		return
+	}
+	http.Redirect(w, r, "/show/"+strconv.Itoa(post.Id)+"/history", http.StatusSeeOther)
+}
@@ -0,0 +1,302 @@
+package app
+
+import (
+	"VoAr/pkg/oauth"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+)
+
+// linkProviderKey is the session value naming the provider whose identity is being linked from the settings page
+const linkProviderKey = "link_provider"
+
+// settingsPage is the data of the account settings page
+type settingsPage struct {
+	Identities  []Identity       //Identities linked to the user
+	Available   []oauth.Provider //Enabled providers the user has not linked yet
+	HasPassword bool             //Whether the user can also sign in with an email and a password
+	CanUnlink   bool             //Whether removing an identity still leaves a way to sign in
+	Tokens      []APIToken       //Personal API tokens of the user
+}
+
+// AuthCallback is an HTTP handler function completing the sign-in with a third-party provider
+// The user is provisioned from the identity: a linked identity signs in to its user, an unknown one gets a new user
+// Unless its email is already taken. The session is established and the user is sent back
+// To the page that asked for the sign-in
+// When the flow was started from the settings page the identity is linked to the signed-in user instead
+func AuthCallback(w http.ResponseWriter, r *http.Request, store *Store) {
+	user, err := gothic.CompleteUserAuth(w, r)
+	if err != nil {
+		// Handling authentication error by returning an internal server error response
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error completing user authentication: %v", err)
+		return
+	}
+	identity := Identity{Provider: user.Provider, ProviderUserID: user.UserID, Email: user.Email, Name: user.Name}
+
+	//Linking the identity to the signed-in user when the settings page asked for it
+	if current := CurrentUser(r); current != nil && takeLinkProvider(w, r) == user.Provider {
+		identity.UserID = current.ID
+		err := store.Identities.Link(&identity)
+		if err == ErrIdentityLinked {
+			w.WriteHeader(http.StatusConflict)
+			renderPage(w, r, "notice", notice{
+				Title:   "Account already linked",
+				Message: "This " + user.Provider + " account is already linked to another user.",
+			})
+			return
+		}
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			log.Printf("Error linking identity: %v", err)
+			return
+		}
+		http.Redirect(w, r, "/settings", http.StatusSeeOther)
+		return
+	}
+
+	//Creating or updating the user the identity signs in to
+	saved, err := provisionUser(store, user, &identity)
+	if err == errNoEmail {
+		w.WriteHeader(http.StatusBadRequest)
+		renderPage(w, r, "notice", notice{
+			Title:   "Email address required",
+			Message: "Your " + user.Provider + " account did not share an email address. Please make it visible to VoAr or sign in another way.",
+		})
+		return
+	}
+	if err == errEmailTaken {
+		w.WriteHeader(http.StatusConflict)
+		renderPage(w, r, "notice", notice{
+			Title:   "Account already exists",
+			Message: "An account with the email address of your " + user.Provider + " account already exists. Please sign in to it and link " + user.Provider + " on the settings page.",
+		})
+		return
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error provisioning user: %v", err)
+		return
+	}
+	if saved.Banned {
+		w.WriteHeader(http.StatusForbidden)
+		renderPage(w, r, "notice", bannedNotice)
+		return
+	}
+
+	//Storing the user in the session so that other handlers, like the chat, know who is signed in
+	if err := SaveSessionUser(w, r, user, saved.ID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error saving user to the session: %v", err)
+		return
+	}
+
+	//Sending the user back to the page that required the sign-in
+	http.Redirect(w, r, takeReturnTo(w, r), http.StatusSeeOther)
+}
+
+var (
+	// errNoEmail is returned by provisionUser when the provider did not report an email for a new identity
+	errNoEmail = errors.New("provider did not report an email address")
+
+	// errEmailTaken is returned by provisionUser when another user already has the email of a new identity
+	errEmailTaken = errors.New("email address belongs to another user")
+)
+
+// provisionUser returns the user the identity signs in to, creating the user and the identity link when needed
+// The identity is keyed on the provider and its user ID, a new identity always gets a new user
+// Providers do not all verify the email they report, so it is never used to link to an existing user
+// The avatar and locale reported by the provider are stored on every sign-in
+func provisionUser(store *Store, user goth.User, identity *Identity) (User, error) {
+	avatar, locale := user.AvatarURL, localeOf(user)
+
+	var saved User
+	linked, err := store.Identities.Find(identity.Provider, identity.ProviderUserID)
+	switch {
+	case err == nil:
+		//Signing in to the user the identity is already linked to
+		if saved, err = store.Users.Get(linked.UserID); err != nil {
+			return saved, err
+		}
+	case err != ErrNotFound:
+		return saved, err
+	case identity.Email == "":
+		return saved, errNoEmail
+	default:
+		//Creating a new user for the new identity, an account that already has the email is never taken over:
+		//Its owner signs in to it and links the identity from the settings page
+		saved = User{Name: displayName(user), Email: strings.ToLower(identity.Email)}
+		if err := store.Users.Create(&saved); err != nil {
+			if err == ErrUserExists {
+				return saved, errEmailTaken
+			}
+			return saved, err
+		}
+		identity.UserID = saved.ID
+		if err := store.Identities.Link(identity); err != nil {
+			return saved, err
+		}
+	}
+
+	//Refreshing the profile fields reported by the provider
+	if err := store.Users.UpdateProfile(saved.ID, avatar, locale); err != nil {
+		return saved, err
+	}
+	if avatar != "" {
+		saved.AvatarURL = avatar
+	}
+	if locale != "" {
+		saved.Locale = locale
+	}
+	return saved, nil
+}
+
+// displayName returns the name of the provider user, falling back to the nickname and the email
+func displayName(user goth.User) string {
+	for _, name := range []string{user.Name, strings.TrimSpace(user.FirstName + " " + user.LastName), user.NickName} {
+		if name != "" {
+			return name
+		}
+	}
+	return strings.SplitN(user.Email, "@", 2)[0]
+}
+
+// localeOf returns the locale claim reported by the provider, most providers do not report one
+func localeOf(user goth.User) string {
+	locale, _ := user.RawData["locale"].(string)
+	return locale
+}
+
+// takeLinkProvider returns and clears the provider stored by LinkIdentity
+func takeLinkProvider(w http.ResponseWriter, r *http.Request) string {
+	session, err := gothic.Store.Get(r, SessionName)
+	if err != nil {
+		return ""
+	}
+	provider, _ := session.Values[linkProviderKey].(string)
+	if provider != "" {
+		delete(session.Values, linkProviderKey)
+		if err := session.Save(r, w); err != nil {
+			log.Printf("Error saving session: %v", err)
+		}
+	}
+	return provider
+}
+
+// savedUser returns the signed-in user, guests are sent to the sign-in page
+func savedUser(w http.ResponseWriter, r *http.Request) (*User, bool) {
+	user := CurrentUser(r)
+	if user == nil {
+		http.Redirect(w, r, signInURL(r), http.StatusSeeOther)
+		return nil, false
+	}
+	return user, true
+}
+
+// Settings is an HTTP handler function for the account settings page listing the linked identities and the API tokens
+// The route is wrapped with RequireAuth
+func Settings(w http.ResponseWriter, r *http.Request, store *Store, providers []oauth.Provider) {
+	user, ok := savedUser(w, r)
+	if !ok {
+		return
+	}
+
+	//Querying the store for the identities and the API tokens of the user
+	identities, err := store.Identities.ListByUser(user.ID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading identities: %v", err)
+		return
+	}
+
+	tokens, err := store.APITokens.ListByUser(user.ID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading API tokens: %v", err)
+		return
+	}
+
+	//Offering the enabled providers that are not linked yet
+	linked := map[string]bool{}
+	for _, identity := range identities {
+		linked[identity.Provider] = true
+	}
+	page := settingsPage{
+		Identities:  identities,
+		HasPassword: user.PasswordHash != "",
+		CanUnlink:   user.PasswordHash != "" || len(identities) > 1,
+		Tokens:      tokens,
+	}
+	for _, provider := range providers {
+		if !linked[provider.Name] {
+			page.Available = append(page.Available, provider)
+		}
+	}
+	renderPage(w, r, "settings", page)
+}
+
+// LinkIdentity is an HTTP handler function starting the sign-in with a provider to link its identity
+// The provider is remembered in the session, so that AuthCallback links instead of signing in
+func LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	if _, ok := savedUser(w, r); !ok {
+		return
+	}
+	provider := mux.Vars(r)["provider"]
+	if _, err := goth.GetProvider(provider); err != nil {
+		http.Error(w, "Unknown provider", http.StatusNotFound)
+		return
+	}
+
+	session, _ := gothic.Store.Get(r, SessionName)
+	session.Values[linkProviderKey] = provider
+	if err := session.Save(r, w); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error saving session: %v", err)
+		return
+	}
+	http.Redirect(w, r, "/auth/"+provider, http.StatusSeeOther)
+}
+
+// UnlinkIdentity is an HTTP handler function removing a linked identity of the signed-in user
+// The last identity of a user without a password cannot be removed, it would lock the user out
+func UnlinkIdentity(w http.ResponseWriter, r *http.Request, store *Store) {
+	user, ok := savedUser(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Identity not found", http.StatusNotFound)
+		return
+	}
+
+	//Checking that another way to sign in remains
+	identities, err := store.Identities.ListByUser(user.ID)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading identities: %v", err)
+		return
+	}
+	if user.PasswordHash == "" && len(identities) <= 1 {
+		http.Error(w, "The last sign-in method cannot be removed", http.StatusConflict)
+		return
+	}
+
+	//Removing the identity, identities of other users are reported as missing
+	if err := store.Identities.Unlink(user.ID, id); err != nil {
+		if err == ErrNotFound {
+			http.Error(w, "Identity not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error unlinking identity: %v", err)
+		return
+	}
+	http.Redirect(w, r, "/settings", http.StatusSeeOther)
+}
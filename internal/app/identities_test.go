@@ -0,0 +1,252 @@
+package app
+
+import (
+	"encoding/gob"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"VoAr/pkg/pgsession"
+
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+	"github.com/markbates/goth/providers/faux"
+)
+
+func TestProvisionUser(t *testing.T) {
+	f := newFixture(t)
+
+	//A new identity gets a new user with the email in lower case
+	github := goth.User{Provider: "github", UserID: "42", Name: "New User", Email: "New@Example.com", AvatarURL: "https://example.com/a.png"}
+	identity := Identity{Provider: "github", ProviderUserID: "42", Email: github.Email, Name: github.Name}
+	created, err := provisionUser(f.store, github, &identity)
+	if err != nil {
+		t.Fatalf("provisioning a new identity: %v", err)
+	}
+	if created.ID == 0 || created.Email != "new@example.com" || created.Role != DefaultRole || created.AvatarURL != github.AvatarURL {
+		t.Errorf("provisioned user %+v", created)
+	}
+
+	//Signing in again with the identity returns the same user, even when the provider reports another email
+	again := Identity{Provider: "github", ProviderUserID: "42", Email: "changed@example.com"}
+	github.Email = again.Email
+	saved, err := provisionUser(f.store, github, &again)
+	if err != nil || saved.ID != created.ID {
+		t.Errorf("signing in again: user %d (%v), want %d", saved.ID, err, created.ID)
+	}
+
+	//An identity with the email of an existing account never signs in to it
+	taken := Identity{Provider: "gitlab", ProviderUserID: "7", Email: "Author@example.com"}
+	if _, err := provisionUser(f.store, goth.User{Provider: "gitlab", UserID: "7", Email: taken.Email}, &taken); err != errEmailTaken {
+		t.Errorf("provisioning an identity with a taken email: %v, want errEmailTaken", err)
+	}
+	if _, err := f.store.Identities.Find("gitlab", "7"); err != ErrNotFound {
+		t.Errorf("identity with a taken email was linked: %v", err)
+	}
+
+	//New identities need an email
+	anonymous := Identity{Provider: "gitlab", ProviderUserID: "8"}
+	if _, err := provisionUser(f.store, goth.User{Provider: "gitlab", UserID: "8", NickName: "anon"}, &anonymous); err != errNoEmail {
+		t.Errorf("provisioning an identity without email: %v, want errNoEmail", err)
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		user goth.User
+		want string
+	}{
+		{goth.User{Name: "Ada Lovelace", NickName: "ada"}, "Ada Lovelace"},
+		{goth.User{FirstName: "Ada", LastName: "Lovelace"}, "Ada Lovelace"},
+		{goth.User{NickName: "ada"}, "ada"},
+		{goth.User{Email: "ada@example.com"}, "ada"},
+	}
+	for _, tt := range tests {
+		if got := displayName(tt.user); got != tt.want {
+			t.Errorf("displayName(%+v) = %q, want %q", tt.user, got, tt.want)
+		}
+	}
+}
+
+// useCookieSessions keeps the sessions of the test in signed cookies and registers the faux provider of goth
+func useCookieSessions(t *testing.T) {
+	t.Helper()
+	gob.Register(goth.User{})
+	previous := gothic.Store
+	gothic.Store = sessions.NewCookieStore([]byte("0123456789abcdef0123456789abcdef"))
+	goth.UseProviders(&faux.Provider{})
+	t.Cleanup(func() {
+		gothic.Store = previous
+		goth.ClearProviders()
+	})
+}
+
+// withCookies adds the cookies set by an earlier response to the request
+func withCookies(r *http.Request, w *httptest.ResponseRecorder) *http.Request {
+	for _, cookie := range w.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+	return r
+}
+
+// fauxCallback returns the callback request of a sign-in with the faux provider reporting the given user
+func fauxCallback(t *testing.T, f *fixture, id, email string, user *User) *http.Request {
+	t.Helper()
+	begin := httptest.NewRecorder()
+	session := fmt.Sprintf(`{"ID":%q,"Name":"Faux","Email":%q,"AuthURL":"http://example.com/auth?state=state"}`, id, email)
+	if err := gothic.StoreInSession("faux", session, httptest.NewRequest("GET", "/auth/faux", nil), begin); err != nil {
+		t.Fatalf("storing the faux session: %v", err)
+	}
+	r := f.request("GET", "/auth/faux/callback?state=state", user, map[string]string{"provider": "faux"})
+	return withCookies(r, begin)
+}
+
+func TestAuthCallback(t *testing.T) {
+	useCookieSessions(t)
+	f := newFixture(t)
+
+	tests := []struct {
+		name  string
+		id    string
+		email string
+		want  int
+	}{
+		{"new identity", "1", "new@example.com", http.StatusSeeOther},
+		{"same identity again", "1", "new@example.com", http.StatusSeeOther},
+		{"email of an existing account", "2", "author@example.com", http.StatusConflict},
+		{"no email", "3", "", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		AuthCallback(w, fauxCallback(t, f, tt.id, tt.email, nil), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+	if user, err := f.store.Users.FindByEmail("new@example.com"); err != nil || user.Name != "Faux" {
+		t.Errorf("provisioned user %+v (%v)", user, err)
+	}
+}
+
+// sessionCookies returns the cookies of a session holding the goth user and, when userID is not 0, the users row
+func sessionCookies(t *testing.T, user goth.User, userID int) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	session, _ := gothic.Store.Get(r, SessionName)
+	session.Values["user"] = user
+	if userID != 0 {
+		session.Values[pgsession.UserIDKey] = userID
+	}
+	if err := session.Save(r, w); err != nil {
+		t.Fatalf("saving session: %v", err)
+	}
+	return w
+}
+
+func TestAuthMiddlewareSessions(t *testing.T) {
+	useCookieSessions(t)
+	f := newFixture(t)
+	identity := goth.User{Provider: "github", UserID: "1", Name: "Author", Email: "author@example.com"}
+
+	tests := []struct {
+		name    string
+		userID  int
+		want    *User
+		cleared bool
+	}{
+		{"user of the session", f.author.ID, f.author, false},
+		{"no user ID", 0, nil, true},
+		{"unknown user ID", 999, nil, true},
+	}
+	for _, tt := range tests {
+		var got *User
+		h := AuthMiddleware(f.store)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = CurrentUser(r)
+		}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, withCookies(httptest.NewRequest("GET", "/", nil), sessionCookies(t, identity, tt.userID)))
+
+		if (got == nil) != (tt.want == nil) || (got != nil && got.ID != tt.want.ID) {
+			t.Errorf("%s: signed in as %+v, want %+v", tt.name, got, tt.want)
+		}
+		cleared := false
+		for _, cookie := range w.Result().Cookies() {
+			cleared = cleared || (cookie.Name == SessionName && cookie.MaxAge < 0)
+		}
+		if cleared != tt.cleared {
+			t.Errorf("%s: session cleared is %v, want %v", tt.name, cleared, tt.cleared)
+		}
+	}
+}
+
+func TestLinkIdentity(t *testing.T) {
+	useCookieSessions(t)
+	f := newFixture(t)
+	if err := f.store.Identities.Link(&Identity{UserID: f.other.ID, Provider: "faux", ProviderUserID: "taken"}); err != nil {
+		t.Fatalf("linking: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		want int
+	}{
+		{"new identity", "mine", http.StatusSeeOther},
+		{"identity of another user", "taken", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		//The settings page remembers the provider in the session, the callback then links instead of signing in
+		begin := httptest.NewRecorder()
+		LinkIdentity(begin, f.request("POST", "/settings/link/faux", f.author, map[string]string{"provider": "faux"}))
+		if begin.Code != http.StatusSeeOther {
+			t.Fatalf("%s: starting the link: status %d", tt.name, begin.Code)
+		}
+		w := httptest.NewRecorder()
+		AuthCallback(w, withCookies(fauxCallback(t, f, tt.id, "faux@example.com", f.author), begin), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	identities, err := f.store.Identities.ListByUser(f.author.ID)
+	if err != nil || len(identities) != 1 || identities[0].ProviderUserID != "mine" {
+		t.Errorf("identities of the author %+v (%v), want the new one", identities, err)
+	}
+	if _, err := f.store.Users.FindByEmail("faux@example.com"); err != ErrNotFound {
+		t.Errorf("linking created a user: %v", err)
+	}
+}
+
+func TestUnlinkIdentity(t *testing.T) {
+	f := newFixture(t)
+	link := func(user *User, id string) Identity {
+		identity := Identity{UserID: user.ID, Provider: "github", ProviderUserID: id}
+		if err := f.store.Identities.Link(&identity); err != nil {
+			t.Fatalf("linking: %v", err)
+		}
+		return identity
+	}
+	first, second, others := link(f.author, "1"), link(f.author, "2"), link(f.other, "3")
+
+	tests := []struct {
+		name     string
+		identity Identity
+		want     int
+	}{
+		{"identity of another user", others, http.StatusNotFound},
+		{"one of two identities", first, http.StatusSeeOther},
+		{"last way to sign in", second, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		id := strconv.Itoa(tt.identity.ID)
+		w := httptest.NewRecorder()
+		UnlinkIdentity(w, f.request("POST", "/settings/identities/"+id+"/unlink", f.author, map[string]string{"id": id}), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package app
+
+import (
+	"VoAr/internal/thumbnail"
+	"VoAr/pkg/blob"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxUploadSize    = 10 << 20                                 //Largest file accepted, in bytes
+	maxUploadFiles   = 10                                       //Most files attached with one submission of the article form
+	maxArticleForm   = maxUploadFiles*maxUploadSize + (1 << 20) //Largest article form, the files and the text fields
+	uploadMemory     = 8 << 20                                  //Files above this size are kept in temporary files while the form is parsed
+	thumbnailSize    = 320                                      //Width and height of the box the thumbnails fit into, in pixels
+	maxFilenameRunes = 255                                      //Longest file name kept, longer names are cut
+	mediaMaxAge      = 5 * 60                                   //Seconds shared caches may keep a file of a published article
+
+	uploadFailedParam = "upload_failed" //Query parameter of the article page telling that the attached files were not stored
+)
+
+// uploadTypes maps the content types accepted for uploads to the extension of their storage keys
+// The content type is detected from the content of the file, the name and the type sent by the browser are ignored
+var uploadTypes = map[string]string{
+	"image/jpeg":                ".jpg",
+	"image/png":                 ".png",
+	"image/gif":                 ".gif",
+	"image/webp":                ".webp",
+	"application/pdf":           ".pdf",
+	"text/plain; charset=utf-8": ".txt",
+}
+
+// Media is a file uploaded to an article, the content is kept in the blob storage
+type Media struct {
+	ID           int       `json:"id"`
+	ArticleID    int       `json:"article_id"`
+	UserID       int       `json:"user_id"`      //User who uploaded the file, 0 when the account was removed
+	Filename     string    `json:"filename"`     //Name of the uploaded file without its directory
+	ContentType  string    `json:"content_type"` //Content type detected from the content
+	Size         int64     `json:"size"`
+	Key          string    `json:"-"` //Storage key of the content
+	ThumbnailKey string    `json:"-"` //Storage key of the thumbnail, empty for files without one
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// URL returns the address the file is served at
+func (m Media) URL() string {
+	return "/media/" + strconv.Itoa(m.ID)
+}
+
+// ThumbnailURL returns the address of the thumbnail, or the file itself for images without a thumbnail
+func (m Media) ThumbnailURL() string {
+	if m.ThumbnailKey == "" {
+		return m.URL()
+	}
+	return m.URL() + "/thumbnail"
+}
+
+// IsImage reports whether the file is an image that can be embedded into the article
+func (m Media) IsImage() bool {
+	return strings.HasPrefix(m.ContentType, "image/")
+}
+
+// SizeLabel returns the size of the file for display, e.g. "340 KB"
+func (m Media) SizeLabel() string {
+	switch {
+	case m.Size >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(m.Size)/(1<<20))
+	case m.Size >= 1<<10:
+		return fmt.Sprintf("%d KB", m.Size>>10)
+	default:
+		return fmt.Sprintf("%d bytes", m.Size)
+	}
+}
+
+// upload is a checked file of the submitted article form, held in memory until the article is saved
+type upload struct {
+	filename    string
+	contentType string
+	data        []byte
+}
+
+// readUploads parses the article form with its size limited and checks the files of its "files" field
+// Forms that are not multipart have no files. It writes the error response itself and reports whether the handler should continue
+// It has to be called before any value of the form is read, so that the limit applies
+func readUploads(w http.ResponseWriter, r *http.Request) ([]upload, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxArticleForm)
+	err := r.ParseMultipartForm(uploadMemory)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("The files are too large, attach up to %d files of up to 10 MB each", maxUploadFiles), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	if err != nil && err != http.ErrNotMultipart {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return nil, false
+	}
+	if r.MultipartForm == nil {
+		return nil, true
+	}
+
+	//Browsers send an empty part when no file was chosen
+	var uploads []upload
+	for _, header := range r.MultipartForm.File["files"] {
+		if header.Filename == "" && header.Size == 0 {
+			continue
+		}
+		if len(uploads) == maxUploadFiles {
+			http.Error(w, fmt.Sprintf("Please attach up to %d files at once", maxUploadFiles), http.StatusBadRequest)
+			return nil, false
+		}
+		name := uploadFilename(header.Filename)
+		if header.Size > maxUploadSize {
+			http.Error(w, name+" is larger than 10 MB", http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+
+		f, err := header.Open()
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			log.Printf("Error opening uploaded file: %v", err)
+			return nil, false
+		}
+		data, err := io.ReadAll(io.LimitReader(f, maxUploadSize))
+		f.Close()
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			log.Printf("Error reading uploaded file: %v", err)
+			return nil, false
+		}
+
+		//Checking the content, the type sent by the browser is only derived from the file name
+		contentType := http.DetectContentType(data)
+		if _, ok := uploadTypes[contentType]; !ok || len(data) == 0 {
+			http.Error(w, name+" is not a JPEG, PNG, GIF or WebP image, a PDF document or a plain text file", http.StatusUnsupportedMediaType)
+			return nil, false
+		}
+		uploads = append(uploads, upload{filename: name, contentType: contentType, data: data})
+	}
+	return uploads, true
+}
+
+// uploadFilename returns the name of the uploaded file without the directory some browsers send, cut to maxFilenameRunes
+func uploadFilename(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, `\`, "/")))
+	if name == "" || name == "." || name == "/" {
+		return "file"
+	}
+	if runes := []rune(name); len(runes) > maxFilenameRunes {
+		name = string(runes[:maxFilenameRunes])
+	}
+	return name
+}
+
+// storeUploads puts the checked files of the article into the storage, makes thumbnails of the images and records them
+// Files are stored under random keys, so the key of a file never changes its content and responses can be cached
+func storeUploads(store *Store, storage blob.Storage, post Pst, userID int, uploads []upload) error {
+	for _, u := range uploads {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		media := Media{
+			ArticleID:   post.Id,
+			UserID:      userID,
+			Filename:    u.filename,
+			ContentType: u.contentType,
+			Size:        int64(len(u.data)),
+			Key:         fmt.Sprintf("articles/%d/%s%s", post.Id, hex.EncodeToString(b), uploadTypes[u.contentType]),
+		}
+		if err := storage.Put(media.Key, bytes.NewReader(u.data), media.Size, media.ContentType); err != nil {
+			return err
+		}
+
+		//Making the thumbnail of JPEG, PNG and GIF images, the file is kept without one when it cannot be decoded
+		if media.IsImage() && media.ContentType != "image/webp" {
+			thumb, contentType, err := thumbnail.Make(u.data, thumbnailSize)
+			if err != nil {
+				log.Printf("Error making thumbnail of %s: %v", media.Key, err)
+			} else {
+				key := strings.TrimSuffix(media.Key, path.Ext(media.Key)) + "-thumbnail" + uploadTypes[contentType]
+				if err := storage.Put(key, bytes.NewReader(thumb), int64(len(thumb)), contentType); err != nil {
+					deleteBlobs(storage, media)
+					return err
+				}
+				media.ThumbnailKey = key
+			}
+		}
+
+		if err := store.Media.Create(&media); err != nil {
+			deleteBlobs(storage, media)
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteBlobs removes the content and the thumbnail of the file from the storage, failures are only logged
+func deleteBlobs(storage blob.Storage, media Media) {
+	for _, key := range []string{media.Key, media.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := storage.Delete(key); err != nil {
+			log.Printf("Error deleting blob %s: %v", key, err)
+		}
+	}
+}
+
+// loadMedia loads the file named in the URL together with its article, files of articles the user cannot see are not found
+// It writes the error response itself and reports whether the handler should continue
+func loadMedia(w http.ResponseWriter, r *http.Request, store *Store) (Media, Pst, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return Media{}, Pst{}, false
+	}
+	media, err := store.Media.Get(id)
+	var post Pst
+	if err == nil {
+		post, err = store.Articles.Get(media.ArticleID)
+	}
+	if err == ErrNotFound || (err == nil && !canView(r, post)) {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return media, post, false
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading file %d: %v", id, err)
+		return media, post, false
+	}
+	return media, post, true
+}
+
+// ServeMedia is an HTTP handler function sending an uploaded file, or its thumbnail when thumb is set
+// The content behind a file ID never changes, but the article can be hidden, unpublished or lose the file,
+// So files of published articles are cached for a few minutes only and revalidated with their ETag afterwards,
+// Which repeats the access check. Files of other articles are revalidated on every use
+func ServeMedia(w http.ResponseWriter, r *http.Request, store *Store, storage blob.Storage, thumb bool) {
+	media, post, ok := loadMedia(w, r, store)
+	if !ok {
+		return
+	}
+	key, contentType, size, etag := media.Key, media.ContentType, media.Size, `"media-`+strconv.Itoa(media.ID)
+	if thumb {
+		if media.ThumbnailKey == "" {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		key, contentType, size, etag = media.ThumbnailKey, mime.TypeByExtension(path.Ext(media.ThumbnailKey)), -1, etag+"-thumbnail"
+	}
+	etag += `"`
+
+	w.Header().Set("ETag", etag)
+	if post.Public() {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(mediaMaxAge)+", must-revalidate")
+	} else {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	content, err := storage.Get(key)
+	if err != nil {
+		//The record is there but the blob is missing or the storage failed, either way the file cannot be sent
+		w.Header().Del("ETag")
+		w.Header().Set("Cache-Control", "no-store")
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error reading blob %s of file %d: %v", key, media.ID, err)
+		return
+	}
+	defer content.Close()
+
+	//Images are shown inline, other files are downloaded, the content type is never guessed again by the browser
+	disposition := "attachment"
+	if media.IsImage() {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": media.Filename}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	if _, err := io.Copy(w, content); err != nil {
+		log.Printf("Error sending file %d: %v", media.ID, err)
+	}
+}
+
+// etagMatches reports whether the If-None-Match header lists the ETag, weak validators match too
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteMedia is an HTTP handler function removing a file from an article
+// Only users allowed to edit the article by their role can remove its files
+func DeleteMedia(w http.ResponseWriter, r *http.Request, store *Store, storage blob.Storage) {
+	media, post, ok := loadMedia(w, r, store)
+	if !ok {
+		return
+	}
+	if !canEdit(r, post) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	err := store.Media.Delete(media.ID)
+	if err == ErrNotFound {
+		http.Error(w, "File not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error deleting file %d: %v", media.ID, err)
+		return
+	}
+	deleteBlobs(storage, media)
+
+	//Redirecting the user back to the edit form listing the remaining files
+	http.Redirect(w, r, "/edit/"+strconv.Itoa(post.Id), http.StatusSeeOther)
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// pageSizes are the page sizes offered on the article listing pages, the first one is the default
+var pageSizes = []int{10, 20, 50}
+
+// pager describes the current page of a paginated list and links to its neighbours
+type pager struct {
+	Path  string     //Path of the list
+	Query url.Values //Query parameters of the list other than the page, e.g. a search query
+	Page  int        //Current page, starting at 1
+	Pages int        //Number of pages, at least 1
+	Total int        //Number of items over all pages
+	Size  int        //Number of items on a page
+}
+
+// newPager returns the pager for the given page of a list of total items
+func newPager(path string, page, total, size int) pager {
+	pages := (total + size - 1) / size
+	if pages < 1 {
+		pages = 1
+	}
+	return pager{Path: path, Query: url.Values{}, Page: page, Pages: pages, Total: total, Size: size}
+}
+
+// Link returns the URL of the given page of the list
+func (p pager) Link(page int) string {
+	query := url.Values{}
+	for name, values := range p.Query {
+		query[name] = values
+	}
+	query.Set("page", strconv.Itoa(page))
+	return p.Path + "?" + query.Encode()
+}
+
+// SizeLink returns the URL of the first page of the list with size items on a page
+func (p pager) SizeLink(size int) string {
+	query := url.Values{}
+	for name, values := range p.Query {
+		query[name] = values
+	}
+	query.Set("per_page", strconv.Itoa(size))
+	return p.Path + "?" + query.Encode()
+}
+
+// HasPrev reports whether there is a page before the current one
+func (p pager) HasPrev() bool { return p.Page > 1 }
+
+// HasNext reports whether there is a page after the current one
+func (p pager) HasNext() bool { return p.Page < p.Pages }
+
+// Prev returns the number of the previous page
+func (p pager) Prev() int { return p.Page - 1 }
+
+// Next returns the number of the next page
+func (p pager) Next() int { return p.Page + 1 }
+
+// Offset returns the number of items before the current page
+func (p pager) Offset() int { return (p.Page - 1) * p.Size }
+
+// listPage reads the page and per_page parameters of an article listing page
+// The page size must be one of pageSizes, it returns false when a parameter is invalid
+func listPage(r *http.Request) (page, size int, ok bool) {
+	page, ok = pageParam(r)
+	if !ok {
+		return 0, 0, false
+	}
+	size, ok = positiveParam(r, "per_page", pageSizes[0])
+	if !ok {
+		return 0, 0, false
+	}
+	for _, allowed := range pageSizes {
+		if size == allowed {
+			return page, size, true
+		}
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestPager(t *testing.T) {
+	p := newPager("/search", 2, 45, 20)
+	p.Query.Set("q", "go")
+	if p.Pages != 3 || !p.HasPrev() || !p.HasNext() || p.Offset() != 20 {
+		t.Errorf("pager %+v: %d pages, previous %v, next %v, offset %d", p, p.Pages, p.HasPrev(), p.HasNext(), p.Offset())
+	}
+	if link := p.Link(p.Next()); link != "/search?page=3&q=go" {
+		t.Errorf("link to the next page %q", link)
+	}
+	if link := p.SizeLink(50); link != "/search?per_page=50&q=go" {
+		t.Errorf("link to the page size %q", link)
+	}
+
+	//Empty lists still have one page
+	if p := newPager("/post", 1, 0, 10); p.Pages != 1 || p.HasPrev() || p.HasNext() {
+		t.Errorf("empty pager %+v", p)
+	}
+}
+
+func TestListPages(t *testing.T) {
+	f := newFixture(t)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", http.StatusOK},
+		{"?per_page=20", http.StatusOK},
+		{"?per_page=50&page=1", http.StatusOK},
+		{"?per_page=15", http.StatusBadRequest},
+		{"?per_page=100", http.StatusBadRequest},
+		{"?page=0", http.StatusBadRequest},
+		{"?page=" + strconv.Itoa(maxPage+1), http.StatusBadRequest},
+		{"?page=2", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		Post(w, f.request("GET", "/post"+tt.query, nil, nil), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%q: status %d, want %d", tt.query, w.Code, tt.want)
+		}
+	}
+}
+
+func TestAPICursor(t *testing.T) {
+	f := newFixture(t)
+	publish := func(title string) {
+		now := time.Now()
+		post := Pst{Title: title, Anons: "Anons", Full_Text: "Text", UserId: f.author.ID, Status: StatusPublished, PublishedAt: &now}
+		if err := f.store.Articles.Create(&post); err != nil {
+			t.Fatalf("creating article %q: %v", title, err)
+		}
+	}
+	for i := 1; i <= 4; i++ {
+		publish(fmt.Sprintf("Article %d", i))
+	}
+	list := func(query string) articleList {
+		t.Helper()
+		w := httptest.NewRecorder()
+		APIListArticles(w, f.request("GET", "/api/v1/articles"+query, nil, nil), f.store)
+		var list articleList
+		if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+			t.Fatalf("%q: decoding list: %v", query, err)
+		}
+		return list
+	}
+
+	//Articles published while following the cursors neither move nor repeat the remaining ones
+	var titles []string
+	page := list("?per_page=2")
+	for {
+		titles = append(titles, articleTitles(page.Data)...)
+		if page.NextCursor == "" {
+			break
+		}
+		publish(fmt.Sprintf("Newer article %d", len(titles)))
+		page = list("?per_page=2&cursor=" + page.NextCursor)
+		if page.Page != 0 {
+			t.Errorf("page %d of a list selected by a cursor", page.Page)
+		}
+	}
+	want := []string{"Article 4", "Article 3", "Article 2", "Article 1", "Published article"}
+	if !reflect.DeepEqual(titles, want) {
+		t.Errorf("followed the cursors through %q, want %q", titles, want)
+	}
+}
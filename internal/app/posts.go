@@ -1,18 +1,31 @@
 package app
 
 import (
-	"database/sql"
+	"VoAr/internal/markdown"
+	"VoAr/pkg/blob"
 	"html/template"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 // save_article is an HTTP handler function for saving an article to the database
 // It retrieves form values from the request, validates them, and inserts the data into the database
-func Save_article(w http.ResponseWriter, r *http.Request) {
+// The author of the article is the signed-in user, the route is wrapped with RequirePermission
+// The attached files are put into the storage once the article is saved
+func Save_article(w http.ResponseWriter, r *http.Request, store *Store, storage blob.Storage) {
+	//Retrieving the signed-in user
+	author := CurrentUser(r)
+
+	//Reading and checking the attached files before the article is saved
+	uploads, ok := readUploads(w, r)
+	if !ok {
+		return
+	}
+
 	//Retrieving form values from the request
 	title := r.FormValue("title")
 	anons := r.FormValue("anons")
@@ -24,153 +37,310 @@ func Save_article(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	//Retrieving the database instance from the request context
-	db := r.Context().Value(DbKey).(*sql.DB)
+	//Resolving the category, the tags and the status of the article, new articles are drafts unless published right away
+	post := Pst{Title: title, Anons: anons, Full_Text: full_text}
+	if !applyForm(w, r, store, &post) {
+		return
+	}
 
-	//Executing the SQL query to insert the article into the database and getting the result
-	result, err := db.Exec("INSERT INTO articles (title, anons, full_text, user_id) VALUES ($1, $2, $3) RETURNING id", title, anons, full_text)
-	if err != nil {
+	//Inserting the article into the store
+	post.UserId = author.ID
+	if err := store.Articles.Create(&post); err != nil {
 		//Handling database insertion error by returning an internal server error response
 		http.Error(w, "Error interesting data", http.StatusInternalServerError)
 		log.Printf("Error scanning data into database: %v", err)
 		return
 	}
 
-	//Getting the number of rows affected by the insertion
-	rowsAffected, err := result.RowsAffected()
+	//Storing the attached files of the article
+	//The article is saved by now, a failure is reported on the article page instead of asking to submit the form again
+	if err := storeUploads(store, storage, post, author.ID, uploads); err != nil {
+		log.Printf("Error storing files of article %d: %v", post.Id, err)
+		http.Redirect(w, r, post.URL()+"?"+uploadFailedParam+"=1", http.StatusSeeOther)
+		return
+	}
+
+	//Redirecting the user to the new article after succesful article inserion, drafts are only visible there and to the author
+	http.Redirect(w, r, post.URL(), http.StatusSeeOther)
+}
+
+// post is an HTTP handler function for displaying a list of articles.
+// It reads the page number and the page size from the request parameters, queries the store for the newest articles first,
+// and renders the list with links to the other pages using the post template.
+func Post(w http.ResponseWriter, r *http.Request, store *Store) {
+	listFiltered(w, r, store, "/post", ArticleFilter{}, "")
+}
+
+// articlePage is the data of the article page
+type articlePage struct {
+	Pst
+	HTML         template.HTML
+	Media        []Media
+	CanEdit      bool
+	CanDelete    bool
+	Comments     []*commentNode
+	CommentsOpen bool
+	UploadFailed bool //The article was saved but its attached files could not be stored, shown to its editors
+}
+
+// showPost is an HTTP handler function for displaying a specific article by its slug
+// It retrieves the article slug from the request parameters, queries the database for the article
+// And renders the article using the show template, the Markdown of the full text is rendered through the cache
+// The old "/show/{id}" addresses and earlier slugs of the article redirect permanently to its current address
+// The attached files and the comment threads are shown below the article, together with a warning for its editors
+// When the files of the last submission could not be stored
+func ShowPost(w http.ResponseWriter, r *http.Request, store *Store, cache *markdown.Cache) {
+	//Querying the store for the specific articles using its slug, or its ID on the old addresses
+	//The article is kept in a local variable, every request checks the access to its own article
+	var post Pst
+	var err error
+	slug, bySlug := mux.Vars(r)["slug"]
+	if bySlug {
+		post, err = store.Articles.FindBySlug(slug)
+	} else {
+		post, err = findArticle(r, store)
+	}
 	if err != nil {
-		//Handling error while getting the number of rows affected
-		http.Error(w, "Error getting rows affected", http.StatusInternalServerError)
-		log.Printf("Error scanning data into database: %v", err)
+		// Handling case when the article is not found
+		if err == ErrNotFound {
+			http.Error(w, "Article not found", http.StatusNotFound)
+			log.Printf("Article not found: %v", err)
+			return
+		}
+		// Handling other errors by returning an internal server error response
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error: %v", err)
+		return
+	}
+
+	//Hidden articles are only shown to their author and to moderators
+	if !canView(r, post) {
+		http.Error(w, "Article not found", http.StatusNotFound)
 		return
 	}
-	//Checking if any rows 	were affected, if not, returning an internal server error
-	if rowsAffected == 0 {
-		http.Error(w, "No rows affected data not inserted", http.StatusInternalServerError)
-		log.Printf("No rows affected, data not inserted")
+
+	//Redirecting to the current address of the article, so that links keep working and search engines index one address
+	if slug != post.Slug {
+		http.Redirect(w, r, post.URL(), http.StatusMovedPermanently)
 		return
 	}
-	//Redirecting the user to the main page after succesful article inserion
-	http.Redirect(w, r, "/", http.StatusSeeOther)
-}
 
-// post is an HTTP handler function for displaying a list of articles.
-// It retrieves a page number from the request parameters, queries the database for articles,
-// and renders the list using the post template.
-func Post(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	// Parsing HTML template files for the post page, show page, header, and footer
-	t, err := template.ParseFiles("web/templates/post.html", "web/templates/show.html", "web/templates/header.html", "web/templates/footer.html")
+	//Rendering the Markdown of the current revision to sanitized HTML
+	html, err := cache.Render(post.Id, post.Revision, post.Full_Text)
 	if err != nil {
-		// Handling template parsing error by returning an internal server error response
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		log.Printf("Error parsing template files: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error rendering article %d: %v", post.Id, err)
 		return
 	}
 
-	//Default page and page size values
-	page := 1
-	pageSize := 10
+	//Loading the comment threads of the article
+	comments, err := loadComments(r, store, post)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error loading comments of article %d: %v", post.Id, err)
+		return
+	}
 
-	//Checking if a specific page is requested and updating the page variable
-	pageParam := r.FormValue("page")
-	if pageParam != "" {
-		page, err = strconv.Atoi(pageParam)
-		if err != nil {
-			// Handling error when converting the page parameter to an integer
-			http.Error(w, "Invalid page parameter", http.StatusBadRequest)
-			log.Printf("Error converting page parameter to integer: %v", err)
-			return
-		}
+	//Loading the files attached to the article
+	media, err := store.Media.ListByArticle(post.Id)
+	if err != nil {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		log.Printf("Error loading files of article %d: %v", post.Id, err)
+		return
 	}
 
-	//Calculating the offset for pagination
-	offset := (page - 1) * pageSize
+	//Executing the show template with the article, its files, its comments and whether the signed-in user may edit or delete it
+	edit := canEdit(r, post)
+	uploadFailed := edit && r.FormValue(uploadFailedParam) == "1"
+	renderPage(w, r, "show", articlePage{post, html, media, edit, canDelete(r, post), comments, commentsOpen(post), uploadFailed})
+}
 
-	//Querying the database for a list of articles with pagination
-	res, err := db.Query("SELECT * FROM articles LIMIT $1 OFFSET $2", pageSize, offset)
+// Preview is an HTTP handler function rendering the submitted Markdown for the live preview of the article forms
+// It responds with the sanitized HTML fragment, the same HTML the article page will show
+func Preview(w http.ResponseWriter, r *http.Request) {
+	html, err := markdown.Render(r.FormValue("full_text"))
 	if err != nil {
-		// Handling database query error by returning an internal server error response
-		http.Error(w, "Internal server eror", http.StatusInternalServerError)
-		log.Printf("Error executing database query: %v", err)
-		return
-	}
-
-	//Creating a slice to store the retrieved articles
-	posts = []Pst{}
-	//Iterating through the query result and scanning each row into a Post struct
-	for res.Next() {
-		var post Pst
-		err = res.Scan(&post.Id, &post.Title, &post.Anons, &post.Full_Text)
-		if err != nil {
-			// Handling error while scanning database rows
-			http.Error(w, "Internal server eror", http.StatusInternalServerError)
-			log.Printf("Error scanning database rows: %v", err)
-			return
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error rendering preview: %v", err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(html))
+}
+
+// applyForm sets the category, the tags and the status of the article from the submitted article form
+// It writes the error response itself and reports whether the handler should continue
+func applyForm(w http.ResponseWriter, r *http.Request, store *Store, post *Pst) bool {
+	message, err := applyTaxonomy(store, post, r.FormValue("category"), r.FormValue("tags"))
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error resolving article category: %v", err)
+		return false
+	}
+	if message == "" {
+		publishAt, ok := parsePublishTime(r.FormValue("publish_at"))
+		if !ok {
+			message = "Please enter a valid publication time"
+		} else {
+			message = applyStatus(post, r.FormValue("status"), publishAt, time.Now())
 		}
-		//Appending the scanned Post struct to the post slice
-		posts = append(posts, post)
 	}
+	if message != "" {
+		http.Error(w, message, http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// findArticle returns the article whose ID is given in the URL, or ErrNotFound if it does not exist
+func findArticle(r *http.Request, store *Store) (Pst, error) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		return Pst{}, ErrNotFound
+	}
+	return store.Articles.Get(id)
+}
+
+// canView reports whether the signed-in user may read the article
+// Unpublished articles and articles hidden by a moderator stay visible to their author and to moderators
+func canView(r *http.Request, post Pst) bool {
+	if post.Public() {
+		return true
+	}
+	user := CurrentUser(r)
+	return user.Can(PermModerate) || (user != nil && post.UserId == user.ID)
+}
+
+// canEdit reports whether the signed-in user may edit the article
+// Authors may edit their own articles, editors and admins may edit every article
+func canEdit(r *http.Request, post Pst) bool {
+	return mayModify(CurrentUser(r), post, PermEditOwnArticle, PermEditAnyArticle)
+}
 
-	//Executing the post template and writing the output to the response writer
-	err = t.ExecuteTemplate(w, "post", posts)
+// canDelete reports whether the signed-in user may delete the article
+// Authors may delete their own articles, editors and admins may delete every article
+func canDelete(r *http.Request, post Pst) bool {
+	return mayModify(CurrentUser(r), post, PermDeleteOwnArticle, PermDeleteAnyArticle)
+}
+
+// mayModify reports whether the user has the permission for any article, or is the author and has the permission for own articles
+// Guests can never modify articles
+func mayModify(user *User, post Pst, own, any Permission) bool {
+	if user == nil {
+		return false
+	}
+	return user.Can(any) || (post.UserId != 0 && post.UserId == user.ID && user.Can(own))
+}
+
+// loadModifiableArticle loads the article named in the URL and checks that the signed-in user may modify it
+// The allowed function is canEdit or canDelete
+// It writes the error response itself and reports whether the handler should continue
+func loadModifiableArticle(w http.ResponseWriter, r *http.Request, store *Store, allowed func(*http.Request, Pst) bool) (Pst, bool) {
+	//Querying the store for the article using the ID from the URL
+	post, err := findArticle(r, store)
+	if err == ErrNotFound {
+		http.Error(w, "Article not found", http.StatusNotFound)
+		return post, false
+	}
 	if err != nil {
-		// Handling template execution error by returning an internal server error response
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		log.Printf("Error executing template: %v", err)
+		log.Printf("Error loading article: %v", err)
+		return post, false
 	}
+
+	//Allowing only users whose role permits the change to continue
+	if !allowed(r, post) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return post, false
+	}
+	return post, true
 }
 
-// showPost is an HTTP handler function for displaying a specific article by its ID
-// It retrieves the article ID from the request parameters, queries the database for the article
-// And renders the article using the show template
-func ShowPost(w http.ResponseWriter, r *http.Request, db *sql.DB) {
-	var err error
-	//Extracing variables from the request parameters
-	vars := mux.Vars(r)
-	// Parsing HTML template files for the show page, header, and footer
-	t, err := template.ParseFiles("web/templates/show.html", "web/templates/header.html", "web/templates/footer.html")
+// EditPost is an HTTP handler function for displaying the edit form of an article
+// Only users allowed to edit the article by their role can open the form
+func EditPost(w http.ResponseWriter, r *http.Request, store *Store) {
+	//Loading the article and checking the permission
+	post, ok := loadModifiableArticle(w, r, store, canEdit)
+	if !ok {
+		return
+	}
+
+	// Executing the edit template with the current article data, its files and the categories to choose from
+	form, err := newArticleForm(store, post)
 	if err != nil {
-		// Handling template parsing error by returning an internal server error response
-		http.Error(w, "Error interesting data", http.StatusInternalServerError)
-		log.Printf("Error scanning data into database: %v", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading categories and files: %v", err)
 		return
 	}
+	renderPage(w, r, "edit", form)
+}
 
-	//Default page and page size values
-	page := 1
-	pageSize := 10
+// UpdatePost is an HTTP handler function for saving changes made to an article
+// Only users allowed to edit the article by their role can update it, the attached files are added to the article
+func UpdatePost(w http.ResponseWriter, r *http.Request, store *Store, storage blob.Storage) {
+	//Loading the article and checking the permission
+	post, ok := loadModifiableArticle(w, r, store, canEdit)
+	if !ok {
+		return
+	}
 
-	//Checking if a	requested and updating the page variable
-	if pageParam := r.FormValue("page"); pageParam != "" {
-		page, _ = strconv.Atoi(pageParam)
+	//Reading and checking the attached files before the article is saved
+	uploads, ok := readUploads(w, r)
+	if !ok {
+		return
 	}
 
-	//Calculating the offset for pagination
-	offset := (page - 1) * pageSize
+	//Retrieving form values from the request
+	post.Title = r.FormValue("title")
+	post.Anons = r.FormValue("anons")
+	post.Full_Text = r.FormValue("full_text")
 
-	//Querying the database for the specific articles using its ID
-	res := db.QueryRow("SELECT * FROM articles WHERE id = $1 LIMIT $2 OFFSET $3", vars["id"], pageSize, offset)
-	if err != nil {
-		// Handling database query error by returning an internal server error response
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("Error: %v", err)
+	//Validating if all  required fields are provided
+	if post.Title == "" || post.Anons == "" || post.Full_Text == "" {
+		http.Error(w, "Please provide all required fields", http.StatusBadRequest)
 		return
 	}
 
-	//Creating a Post instance to store the retrieved article data
-	showItems = Pst{}
-	//Scanning the database query result into the showItems variable
-	err = res.Scan(&showItems.Id, &showItems.Title, &showItems.Anons, &showItems.Full_Text)
-	if err != nil {
-		// Handling case when the article is not found
-		if err == sql.ErrNoRows {
-			http.Error(w, "Article not found", http.StatusNotFound)
-			log.Printf("Article not found: %v", err)
-		}
-		// Handling other errors by returning an internal server error response
-		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
-		log.Printf("Error: %v", err)
+	//Resolving the category, the tags and the status of the article
+	if !applyForm(w, r, store, &post) {
 		return
 	}
-	t.ExecuteTemplate(w, "show", showItems)
+
+	//Saving the changes to the store as a new revision made by the signed-in user
+	if err := store.Articles.Update(&post, CurrentUser(r).ID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error updating article: %v", err)
+		return
+	}
+
+	//Storing the attached files of the article
+	//The article is saved by now, a failure is reported on the article page instead of asking to submit the form again
+	if err := storeUploads(store, storage, post, CurrentUser(r).ID, uploads); err != nil {
+		log.Printf("Error storing files of article %d: %v", post.Id, err)
+		http.Redirect(w, r, post.URL()+"?"+uploadFailedParam+"=1", http.StatusSeeOther)
+		return
+	}
+
+	//Redirecting the user to the updated article
+	http.Redirect(w, r, post.URL(), http.StatusSeeOther)
+}
+
+// DeletePost is an HTTP handler function for deleting an article
+// Only users allowed to delete the article by their role can delete it
+func DeletePost(w http.ResponseWriter, r *http.Request, store *Store) {
+	//Loading the article and checking the permission
+	post, ok := loadModifiableArticle(w, r, store, canDelete)
+	if !ok {
+		return
+	}
+
+	//Deleting the article from the store
+	if err := store.Articles.Delete(post.Id); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error deleting article: %v", err)
+		return
+	}
+
+	//Redirecting the user to the list of articles
+	http.Redirect(w, r, "/post", http.StatusSeeOther)
 }
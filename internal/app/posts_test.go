@@ -0,0 +1,140 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"VoAr/internal/markdown"
+)
+
+func TestShowPostAccess(t *testing.T) {
+	f := newFixture(t)
+	cache := markdown.NewCache(10)
+
+	tests := []struct {
+		post Pst
+		user *User
+		want int
+	}{
+		{f.published, nil, http.StatusOK},
+		{f.published, f.reader, http.StatusOK},
+		{f.draft, nil, http.StatusNotFound},
+		{f.draft, f.other, http.StatusNotFound},
+		{f.draft, f.reader, http.StatusNotFound},
+		{f.draft, f.author, http.StatusOK},
+		{f.draft, f.editor, http.StatusOK},
+		{f.hidden, nil, http.StatusNotFound},
+		{f.hidden, f.other, http.StatusNotFound},
+		{f.hidden, f.author, http.StatusOK},
+		{f.hidden, f.editor, http.StatusOK},
+		{f.deleted, f.author, http.StatusNotFound},
+		{f.deleted, f.editor, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		ShowPost(w, f.request("GET", tt.post.URL(), tt.user, map[string]string{"slug": tt.post.Slug}), f.store, cache)
+		if w.Code != tt.want {
+			t.Errorf("%s viewing %q: status %d, want %d", userName(tt.user), tt.post.Title, w.Code, tt.want)
+		}
+	}
+}
+
+func TestEditPostAccess(t *testing.T) {
+	f := newFixture(t)
+
+	tests := []struct {
+		post Pst
+		user *User
+		want int
+	}{
+		{f.published, nil, http.StatusForbidden},
+		{f.published, f.reader, http.StatusForbidden},
+		{f.published, f.other, http.StatusForbidden},
+		{f.published, f.author, http.StatusOK},
+		{f.published, f.editor, http.StatusOK},
+		{f.draft, f.other, http.StatusForbidden},
+		{f.draft, f.author, http.StatusOK},
+		{f.hidden, f.other, http.StatusForbidden},
+		{f.hidden, f.author, http.StatusOK},
+		{f.deleted, f.author, http.StatusNotFound},
+		{f.deleted, f.editor, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		id := strconv.Itoa(tt.post.Id)
+		w := httptest.NewRecorder()
+		EditPost(w, f.request("GET", "/edit/"+id, tt.user, map[string]string{"id": id}), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%s editing %q: status %d, want %d", userName(tt.user), tt.post.Title, w.Code, tt.want)
+		}
+	}
+}
+
+func TestDeletePostAccess(t *testing.T) {
+	tests := []struct {
+		name string
+		user func(*fixture) *User
+		want int
+	}{
+		{"guest", func(*fixture) *User { return nil }, http.StatusForbidden},
+		{"reader", func(f *fixture) *User { return f.reader }, http.StatusForbidden},
+		{"other author", func(f *fixture) *User { return f.other }, http.StatusForbidden},
+		{"author", func(f *fixture) *User { return f.author }, http.StatusSeeOther},
+		{"editor", func(f *fixture) *User { return f.editor }, http.StatusSeeOther},
+	}
+	for _, tt := range tests {
+		f := newFixture(t)
+		id := strconv.Itoa(f.draft.Id)
+		w := httptest.NewRecorder()
+		DeletePost(w, f.request("POST", "/delete/"+id, tt.user(f), map[string]string{"id": id}), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%s deleting: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+
+		//Only an allowed deletion removes the article
+		_, err := f.store.Articles.Get(f.draft.Id)
+		if deleted := err == ErrNotFound; deleted != (tt.want == http.StatusSeeOther) {
+			t.Errorf("%s deleting: article deleted is %v", tt.name, deleted)
+		}
+	}
+
+	//Deleted articles cannot be deleted again
+	f := newFixture(t)
+	id := strconv.Itoa(f.deleted.Id)
+	w := httptest.NewRecorder()
+	DeletePost(w, f.request("POST", "/delete/"+id, f.editor, map[string]string{"id": id}), f.store)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("deleting a deleted article: status %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+// TestShowPostConcurrent shows different articles to different users at once, each must get the page of its own article
+// Run with -race to check that the handler keeps no shared state between requests
+func TestShowPostConcurrent(t *testing.T) {
+	f := newFixture(t)
+	cache := markdown.NewCache(10)
+
+	done := make(chan error)
+	for i := 0; i < 20; i++ {
+		post, user, want := f.published, (*User)(nil), http.StatusOK
+		if i%2 == 1 {
+			post, user, want = f.draft, f.other, http.StatusNotFound
+		}
+		go func() {
+			w := httptest.NewRecorder()
+			ShowPost(w, f.request("GET", post.URL(), user, map[string]string{"slug": post.Slug}), f.store, cache)
+			if w.Code != want {
+				done <- fmt.Errorf("%s viewing %q: status %d, want %d", userName(user), post.Title, w.Code, want)
+				return
+			}
+			done <- nil
+		}()
+	}
+	for i := 0; i < 20; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"VoAr/internal/render"
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// RendererKey is the context key for passing the template renderer to handlers
+const RendererKey ContextKey = "renderer"
+
+// Pages lists every template executed by the handlers
+// The renderer is created with this list, so a missing template stops the server at startup
+var Pages = []string{
+	"mainPage", "examples", "create", "chat", "googleSignIn", "post", "show", "edit",
+	"register", "forgot", "reset", "notice", "settings", "admin", "adminUsers", "adminArticles", "search",
+	"history", "diff", "apiToken",
+}
+
+// Page is the data passed to every page template
+// The header uses the signed-in user for its navigation and Meta for the title and the metadata, the page body uses Data
+type Page struct {
+	User *User       //Signed-in user, nil for guests
+	Data interface{} //Data specific to the page
+	Meta Meta        //Title, description and link preview metadata of the page
+	CSRF string      //Token every form has to send in its csrf_token field
+}
+
+// RendererMiddleware is middleware that injects the template renderer into the request context
+func RendererMiddleware(renderer *render.Renderer) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			//Creating a new context with the renderer and serving the next HTTP handler with it
+			ctx := context.WithValue(r.Context(), RendererKey, renderer)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// renderPage executes the named page template using the renderer from the request context
+// The data is wrapped in a Page together with the signed-in user and the metadata of the page
+// Rendering errors are logged and reported to the client as an internal server error
+func renderPage(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	renderer := r.Context().Value(RendererKey).(*render.Renderer)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := renderer.Render(w, name, Page{User: CurrentUser(r), Data: data, Meta: pageMeta(r, name, data), CSRF: csrfToken(r)}); err != nil {
+		// Handling template execution error by returning an internal server error response
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error executing template %q: %v", name, err)
+	}
+}
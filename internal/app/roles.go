@@ -0,0 +1,87 @@
+package app
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// Role is the role of a user, it decides which permissions the user has
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"  //Manages users and every article, the only role with the admin area
+	RoleEditor Role = "editor" //Edits and moderates every article
+	RoleAuthor Role = "author" //Writes and manages their own articles
+	RoleReader Role = "reader" //Only reads
+)
+
+// DefaultRole is the role of new users, it matches the default of the users.role column
+const DefaultRole = RoleAuthor
+
+// Roles lists every role, from the most to the least privileged
+var Roles = []Role{RoleAdmin, RoleEditor, RoleAuthor, RoleReader}
+
+// Permission is an action that requires a role
+type Permission string
+
+const (
+	PermCreateArticle    Permission = "article.create"
+	PermEditOwnArticle   Permission = "article.edit.own"
+	PermEditAnyArticle   Permission = "article.edit.any"
+	PermDeleteOwnArticle Permission = "article.delete.own"
+	PermDeleteAnyArticle Permission = "article.delete.any"
+	PermModerate         Permission = "moderate"     //Hiding and restoring content of other users
+	PermManageUsers      Permission = "users.manage" //Changing roles and banning users
+	PermAdmin            Permission = "admin"        //Reaching the admin area under /admin
+)
+
+// rolePermissions maps every role to the permissions it grants
+var rolePermissions = map[Role][]Permission{
+	RoleAdmin: {
+		PermCreateArticle, PermEditOwnArticle, PermEditAnyArticle, PermDeleteOwnArticle, PermDeleteAnyArticle,
+		PermModerate, PermManageUsers, PermAdmin,
+	},
+	RoleEditor: {
+		PermCreateArticle, PermEditOwnArticle, PermEditAnyArticle, PermDeleteOwnArticle, PermDeleteAnyArticle,
+		PermModerate,
+	},
+	RoleAuthor: {PermCreateArticle, PermEditOwnArticle, PermDeleteOwnArticle},
+	RoleReader: {},
+}
+
+// Valid reports whether the role is one of Roles
+func (role Role) Valid() bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Can reports whether the user has the permission, a nil user is a guest without permissions
+func (u *User) Can(perm Permission) bool {
+	if u == nil {
+		return false
+	}
+	for _, granted := range rolePermissions[u.Role] {
+		if granted == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// TemplateFuncs are the functions available in the page templates
+// "can" checks a permission of the signed-in user, e.g. {{ if can .User "article.create" }}
+var TemplateFuncs = template.FuncMap{
+	"can": func(user *User, perm string) bool { return user.Can(Permission(perm)) },
+}
+
+// RequirePermission wraps a handler so that only signed-in users with the permission can reach it
+// Guests are redirected to the sign-in page, users without the permission get 403
+func RequirePermission(perm Permission, next http.HandlerFunc) http.HandlerFunc {
+	return RequireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if !CurrentUser(r).Can(perm) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
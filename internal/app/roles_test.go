@@ -0,0 +1,90 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCan(t *testing.T) {
+	f := newFixture(t)
+
+	tests := []struct {
+		user *User
+		perm Permission
+		want bool
+	}{
+		{nil, PermCreateArticle, false},
+		{f.reader, PermCreateArticle, false},
+		{f.reader, PermEditOwnArticle, false},
+		{f.author, PermCreateArticle, true},
+		{f.author, PermEditOwnArticle, true},
+		{f.author, PermEditAnyArticle, false},
+		{f.author, PermModerate, false},
+		{f.editor, PermEditAnyArticle, true},
+		{f.editor, PermDeleteAnyArticle, true},
+		{f.editor, PermModerate, true},
+		{f.editor, PermManageUsers, false},
+		{f.editor, PermAdmin, false},
+		{f.admin, PermManageUsers, true},
+		{f.admin, PermAdmin, true},
+		{&User{Role: "unknown"}, PermCreateArticle, false},
+	}
+	for _, tt := range tests {
+		if got := tt.user.Can(tt.perm); got != tt.want {
+			t.Errorf("%s can %s: %v, want %v", userName(tt.user), tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	f := newFixture(t)
+	h := RequirePermission(PermCreateArticle, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		user *User
+		want int
+	}{
+		{nil, http.StatusSeeOther},
+		{f.reader, http.StatusForbidden},
+		{f.author, http.StatusOK},
+		{f.admin, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h(w, f.request("GET", "/create", tt.user, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", userName(tt.user), w.Code, tt.want)
+		}
+	}
+}
+
+func TestNavigationByRole(t *testing.T) {
+	f := newFixture(t)
+
+	//The navigation offers writing and the admin area only to the roles that may use them
+	tests := []struct {
+		user         *User
+		write, admin bool
+	}{
+		{nil, false, false},
+		{f.reader, false, false},
+		{f.author, true, false},
+		{f.editor, true, false},
+		{f.admin, true, true},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		MainPage(w, f.request("GET", "/", tt.user, nil))
+		page := w.Body.String()
+		if write := strings.Contains(page, `href="/create"`); write != tt.write {
+			t.Errorf("%s: link to write is %v, want %v", userName(tt.user), write, tt.write)
+		}
+		if admin := strings.Contains(page, `href="/admin"`); admin != tt.admin {
+			t.Errorf("%s: link to the admin area is %v, want %v", userName(tt.user), admin, tt.admin)
+		}
+	}
+}
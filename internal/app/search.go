@@ -0,0 +1,191 @@
+package app
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	searchPerPage = 10 //Number of results on a page of the search page
+
+	snippetStart = "\x02" //Marks the start of a match in a raw snippet
+	snippetStop  = "\x03" //Marks the end of a match in a raw snippet
+)
+
+// searchPage is the data of the search page
+type searchPage struct {
+	Query   string
+	Results []SearchResult
+	Pager   pager
+}
+
+// searchList is the response body of the article search endpoint
+type searchList struct {
+	Data    []SearchResult `json:"data"`
+	Query   string         `json:"query"`
+	Page    int            `json:"page"`
+	PerPage int            `json:"per_page"`
+	Total   int            `json:"total"`
+}
+
+// highlight escapes a raw snippet and wraps the marked matches in <mark> elements
+func highlight(snippet string) template.HTML {
+	escaped := template.HTMLEscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, snippetStart, "<mark>")
+	escaped = strings.ReplaceAll(escaped, snippetStop, "</mark>")
+	return template.HTML(escaped)
+}
+
+// Search is an HTTP handler function for the search page listing the articles matching the q parameter
+func Search(w http.ResponseWriter, r *http.Request, store *Store) {
+	page, ok := pageParam(r)
+	if !ok {
+		http.Error(w, "Invalid page parameter", http.StatusBadRequest)
+		return
+	}
+	data := searchPage{Query: strings.TrimSpace(r.FormValue("q"))}
+
+	//An empty query shows the search form only
+	if data.Query != "" {
+		results, total, err := store.Articles.Search(data.Query, searchPerPage, (page-1)*searchPerPage)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			log.Printf("Error searching articles: %v", err)
+			return
+		}
+		data.Results = results
+		data.Pager = newPager("/search", page, total, searchPerPage)
+		data.Pager.Query.Set("q", data.Query)
+	}
+	renderPage(w, r, "search", data)
+}
+
+// APISearchArticles is an HTTP handler function returning a page of the articles matching the q parameter as JSON
+// The results are ordered by relevance and carry highlighted snippets
+func APISearchArticles(w http.ResponseWriter, r *http.Request, store *Store) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if query == "" {
+		writeJSONError(w, http.StatusBadRequest, "Query parameter q is required")
+		return
+	}
+	page, ok := pageParam(r)
+	if !ok {
+		writeJSONError(w, http.StatusBadRequest, "Invalid page parameter")
+		return
+	}
+	perPage, ok := positiveParam(r, "per_page", defaultPerPage)
+	if !ok || perPage > maxPerPage {
+		writeJSONError(w, http.StatusBadRequest, "Invalid per_page parameter")
+		return
+	}
+
+	results, total, err := store.Articles.Search(query, perPage, (page-1)*perPage)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "Internal server error")
+		log.Printf("Error searching articles: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, searchList{Data: results, Query: query, Page: page, PerPage: perPage, Total: total})
+}
+
+// searchTerms splits a search query into lower case terms for the memory store
+// Quotes and the operators of web search queries are ignored
+func searchTerms(query string) []string {
+	var terms []string
+	for _, term := range strings.Fields(strings.ToLower(query)) {
+		term = strings.Trim(term, `"-`)
+		if term != "" && term != "or" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
+
+// matchArticle ranks an article for the terms like the weights of the search migration, title matches count most
+// It returns false when a term does not occur in the article
+func matchArticle(post Pst, terms []string) (SearchResult, bool) {
+	result := SearchResult{Pst: post}
+	title, anons, text := strings.ToLower(post.Title), strings.ToLower(post.Anons), strings.ToLower(post.Full_Text)
+	for _, term := range terms {
+		n := 3*strings.Count(title, term) + 2*strings.Count(anons, term) + strings.Count(text, term)
+		if n == 0 {
+			return result, false
+		}
+		result.Rank += float64(n)
+	}
+	result.Snippet = highlight(markTerms(snippetAround(post.Anons+" "+post.Full_Text, terms[0]), terms))
+	return result, true
+}
+
+// snippetAround returns the words around the first occurrence of the term in the text
+func snippetAround(text, term string) string {
+	const context = 80
+	start := strings.Index(strings.ToLower(text), term)
+	if start < 0 || start > len(text) {
+		start = 0
+	}
+	from, to := start-context, start+len(term)+context
+	if from < 0 {
+		from = 0
+	}
+	if to > len(text) {
+		to = len(text)
+	}
+	//Widening the window to whole words, which also keeps multi-byte characters intact
+	for from > 0 && text[from-1] != ' ' {
+		from--
+	}
+	for to < len(text) && text[to] != ' ' {
+		to++
+	}
+	return strings.TrimSpace(text[from:to])
+}
+
+// markTerms wraps every occurrence of the terms in the snippet markers, ignoring case
+func markTerms(text string, terms []string) string {
+	lower := strings.ToLower(text)
+	if len(lower) != len(text) {
+		//Lower casing changed the byte offsets of some characters, the snippet is shown without highlights
+		return text
+	}
+
+	//Flagging the bytes covered by a match, overlapping matches merge into one highlight
+	marked := make([]bool, len(text)+1)
+	for _, term := range terms {
+		for i := 0; ; {
+			j := strings.Index(lower[i:], term)
+			if j < 0 {
+				break
+			}
+			for k := i + j; k < i+j+len(term); k++ {
+				marked[k] = true
+			}
+			i += j + len(term)
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			b.WriteString(snippetStart)
+		}
+		b.WriteByte(text[i])
+		if marked[i] && !marked[i+1] {
+			b.WriteString(snippetStop)
+		}
+	}
+	return b.String()
+}
+
+// sortResults orders search results by rank, newer articles first when ranks are equal
+func sortResults(results []SearchResult) {
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].Id > results[j].Id
+	})
+}
@@ -0,0 +1,98 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHighlight(t *testing.T) {
+	got := highlight(markTerms("Go <b>routines</b> in go", []string{"go"}))
+	if want := "<mark>Go</mark> &lt;b&gt;routines&lt;/b&gt; in <mark>go</mark>"; string(got) != want {
+		t.Errorf("highlight = %q, want %q", got, want)
+	}
+}
+
+func TestSearchTerms(t *testing.T) {
+	got := searchTerms(`"Go Routines" or -java`)
+	if strings.Join(got, ",") != "go,routines,java" {
+		t.Errorf("searchTerms = %q", got)
+	}
+}
+
+func TestAPISearchArticles(t *testing.T) {
+	f := newFixture(t)
+	now := time.Now()
+	for _, a := range []struct{ title, text string }{
+		{"Concurrency in Go", "Goroutines and channels"},
+		{"Cooking", "Goroutines are mentioned once"},
+	} {
+		post := Pst{Title: a.title, Anons: "Anons", Full_Text: a.text, UserId: f.author.ID, Status: StatusPublished, PublishedAt: &now}
+		if err := f.store.Articles.Create(&post); err != nil {
+			t.Fatalf("creating article: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"?q=goroutines", http.StatusOK},
+		{"", http.StatusBadRequest},
+		{"?q=go&page=0", http.StatusBadRequest},
+		{"?q=go&page=" + strconv.Itoa(maxPage+1), http.StatusBadRequest},
+		{"?q=go&per_page=101", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		APISearchArticles(w, f.request("GET", "/api/v1/articles/search"+tt.query, nil, nil), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%q: status %d, want %d", tt.query, w.Code, tt.want)
+		}
+	}
+
+	//Every term has to match
+	w := httptest.NewRecorder()
+	APISearchArticles(w, f.request("GET", "/api/v1/articles/search?q=goroutines+channels", nil, nil), f.store)
+	var list searchList
+	if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+		t.Fatalf("decoding results: %v", err)
+	}
+	if list.Total != 1 || len(list.Data) != 1 || list.Data[0].Title != "Concurrency in Go" {
+		t.Errorf("found %d results %+v, want the article matching every term", list.Total, list.Data)
+	}
+	if !strings.Contains(string(list.Data[0].Snippet), "<mark>Goroutines</mark>") {
+		t.Errorf("snippet %q has no highlighted match", list.Data[0].Snippet)
+	}
+}
+
+func TestSearchPage(t *testing.T) {
+	f := newFixture(t)
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"", http.StatusOK},
+		{"?q=article", http.StatusOK},
+		{"?q=article&page=2", http.StatusOK},
+		{"?q=article&page=" + strconv.Itoa(maxPage+1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		Search(w, f.request("GET", "/search"+tt.query, f.reader, nil), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%q: status %d, want %d", tt.query, w.Code, tt.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	Search(w, f.request("GET", "/search?q=article", nil, nil), f.store)
+	if body := w.Body.String(); !strings.Contains(body, "Published article") || strings.Contains(body, "Draft article") {
+		t.Errorf("search page lists unpublished articles or misses the published one")
+	}
+}
@@ -0,0 +1,288 @@
+package app
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxSlugRunes        = 80    //Length article slugs are cut to, the number appended to repeated slugs comes on top
+	maxDescriptionRunes = 160   //Length meta descriptions are cut to, search engines show about as much
+	sitemapLimit        = 50000 //Most URLs a sitemap may list
+	sitemapBatch        = 500   //Number of articles loaded at once for the sitemap
+	sitemapAge          = 3600  //Seconds the sitemap may be cached
+)
+
+// URL returns the address of the article page, which is built from the slug of the article
+// Slugs keep the letters of every script, they are percent-encoded for the sitemap and the Location header
+func (p Pst) URL() string {
+	if p.Slug == "" {
+		return "/show/" + strconv.Itoa(p.Id)
+	}
+	return "/articles/" + url.PathEscape(p.Slug)
+}
+
+// articleSlug returns the slug of the title cut to maxSlugRunes, titles without letters or digits get "article"
+func articleSlug(title string) string {
+	slug := []rune(slugify(title))
+	if len(slug) > maxSlugRunes {
+		slug = slug[:maxSlugRunes]
+	}
+	if s := strings.TrimRight(string(slug), "-"); s != "" {
+		return s
+	}
+	return "article"
+}
+
+// numberedSlug returns the nth candidate for an article with the base slug: the base itself, then "base-2", "base-3" and so on
+func numberedSlug(base string, n int) string {
+	if n == 1 {
+		return base
+	}
+	return base + "-" + strconv.Itoa(n)
+}
+
+// keepsSlug reports whether the current slug of an article is one of the candidates for the base slug of its title
+// So that saving an article without changing its title keeps its address
+func keepsSlug(current, base string) bool {
+	if current == base {
+		return true
+	}
+	if !strings.HasPrefix(current, base+"-") {
+		return false
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(current, base+"-"))
+	return err == nil && n >= 2 && numberedSlug(base, n) == current
+}
+
+// siteDescription describes the pages whose data has no description of its own
+const siteDescription = siteTitle + " is a blog with articles on every topic, discussions below them and a chat"
+
+// Meta is the metadata of a page for the title, search engines and the link previews of social networks
+type Meta struct {
+	Title       string     //Title of the page, the site title is appended, empty on the main page
+	Description string     //Short description shown by search engines and link previews
+	Canonical   string     //Absolute URL search engines index the page under
+	Type        string     //Open Graph type, "article" on the article page and "website" otherwise
+	Image       string     //Absolute URL of the preview image, empty for pages without one
+	Published   *time.Time //Publication time of the article
+	Modified    time.Time  //Last change of the article, zero on other pages
+	Author      string     //Name of the author of the article
+	Tags        []Tag      //Tags of the article
+	NoIndex     bool       //Asks search engines to leave the page out, set on forms, private pages and unpublished articles
+}
+
+// metaProvider is implemented by the data of pages that describe themselves, like the article page
+type metaProvider interface {
+	meta(r *http.Request) Meta
+}
+
+// defaultMeta is the metadata of the pages whose data does not describe the page
+var defaultMeta = map[string]Meta{
+	"mainPage":      {},
+	"examples":      {Title: "Examples"},
+	"chat":          {Title: "Chat"},
+	"create":        {Title: "Write an article", NoIndex: true},
+	"edit":          {Title: "Edit article", NoIndex: true},
+	"googleSignIn":  {Title: "Sign in", NoIndex: true},
+	"register":      {Title: "Register", NoIndex: true},
+	"forgot":        {Title: "Forgot password", NoIndex: true},
+	"reset":         {Title: "Reset password", NoIndex: true},
+	"settings":      {Title: "Settings", NoIndex: true},
+	"apiToken":      {Title: "API token", NoIndex: true},
+	"admin":         {Title: "Admin", NoIndex: true},
+	"adminUsers":    {Title: "Users", NoIndex: true},
+	"adminArticles": {Title: "Articles", NoIndex: true},
+	"search":        {Title: "Search", NoIndex: true},
+	"history":       {Title: "History", NoIndex: true},
+	"diff":          {Title: "Changes", NoIndex: true},
+}
+
+// pageMeta returns the metadata of the named page, from its data when the data describes the page
+// The description, the canonical URL and the type fall back to the site description, the requested path and "website"
+func pageMeta(r *http.Request, name string, data interface{}) Meta {
+	meta := defaultMeta[name]
+	if provider, ok := data.(metaProvider); ok {
+		meta = provider.meta(r)
+	}
+	if meta.Description == "" {
+		meta.Description = siteDescription
+	}
+	if meta.Canonical == "" {
+		meta.Canonical = baseURL() + r.URL.EscapedPath()
+	}
+	if meta.Type == "" {
+		meta.Type = "website"
+	}
+	return meta
+}
+
+// description returns the text on a single line cut to maxDescriptionRunes at a word boundary
+func description(text string) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= maxDescriptionRunes {
+		return text
+	}
+	cut := string(runes[:maxDescriptionRunes-1])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:-") + "…"
+}
+
+// meta describes the article page, the first attached image is the preview image
+// Articles only their author and moderators can see are not indexed
+func (p articlePage) meta(r *http.Request) Meta {
+	base := baseURL()
+	meta := Meta{
+		Title: p.Title, Description: description(p.Anons), Canonical: base + p.URL(), Type: "article",
+		Modified: p.UpdatedAt, Author: p.AuthorName, Tags: p.Tags, NoIndex: !p.Public(),
+	}
+	if p.Public() {
+		meta.Published = p.PublishedAt
+	}
+	for _, media := range p.Media {
+		if media.IsImage() {
+			meta.Image = base + media.URL()
+			break
+		}
+	}
+	return meta
+}
+
+// meta describes the article listings, the canonical URL leaves out the page size
+func (l postList) meta(r *http.Request) Meta {
+	meta := Meta{Title: "Articles", Description: "The newest articles on " + siteTitle, Canonical: baseURL() + l.Pager.Path}
+	if l.Heading != "" {
+		meta.Title, meta.Description = l.Heading, l.Heading+" on "+siteTitle+", the newest first"
+	}
+	if l.Pager.Page > 1 {
+		meta.Title += fmt.Sprintf(", page %d", l.Pager.Page)
+		meta.Canonical += "?page=" + strconv.Itoa(l.Pager.Page)
+	}
+	return meta
+}
+
+// meta describes the notices, which are answers to the links of emails and are not indexed
+func (n notice) meta(r *http.Request) Meta {
+	return Meta{Title: n.Title, Description: description(n.Message), NoIndex: true}
+}
+
+// robotsRules keeps crawlers away from the forms, the account pages, the admin area, the revision history and the API
+const robotsRules = `User-agent: *
+Disallow: /admin
+Disallow: /api/
+Disallow: /auth/
+Disallow: /create
+Disallow: /edit/
+Disallow: /forgot
+Disallow: /googleSignIn
+Disallow: /login
+Disallow: /register
+Disallow: /reset
+Disallow: /search
+Disallow: /settings
+Disallow: /verify
+Disallow: /show/*/history
+Disallow: /show/*/diff
+
+Sitemap: %s/sitemap.xml
+`
+
+// Robots is an HTTP handler function serving robots.txt with the rules for crawlers and the address of the sitemap
+func Robots(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, robotsRules, baseURL())
+}
+
+// sitemapURL is a page listed by the sitemap, with the last change of its content when it is known
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// sitemapDoc is the XML document of the sitemap
+type sitemapDoc struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// sitemapTime formats the time as the W3C date and time expected by the sitemap
+func sitemapTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// Sitemap is an HTTP handler function serving sitemap.xml with the public pages for search engines
+// It lists the published articles, newest first, and the listings of their categories, tags and authors,
+// Listings were last changed when their newest changed article was
+func Sitemap(w http.ResponseWriter, r *http.Request, store *Store) {
+	base := baseURL()
+	var articles []sitemapURL
+	lists := map[string]time.Time{}
+
+	//Walking through the published articles in batches, without a viewer only public articles are listed
+	filter := ArticleFilter{}
+	for len(articles) < sitemapLimit {
+		posts, err := store.Articles.List(filter, sitemapBatch, 0)
+		if err != nil {
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			log.Printf("Error listing articles for the sitemap: %v", err)
+			return
+		}
+		for _, post := range posts {
+			articles = append(articles, sitemapURL{Loc: base + post.URL(), LastMod: sitemapTime(post.UpdatedAt)})
+
+			//Recording the listings the article appears on
+			paths := []string{"/post"}
+			if post.UserId != 0 {
+				paths = append(paths, "/author/"+strconv.Itoa(post.UserId))
+			}
+			if post.Category != nil {
+				paths = append(paths, "/category/"+url.PathEscape(post.Category.Slug))
+			}
+			for _, tag := range post.Tags {
+				paths = append(paths, "/tag/"+url.PathEscape(tag.Slug))
+			}
+			for _, path := range paths {
+				if post.UpdatedAt.After(lists[path]) {
+					lists[path] = post.UpdatedAt
+				}
+			}
+		}
+		if len(posts) < sitemapBatch {
+			break
+		}
+		filter.Before = posts[len(posts)-1].Id
+	}
+
+	//Listing the main page and the listings first, ordered by path, then the articles
+	paths := make([]string, 0, len(lists))
+	for path := range lists {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	doc := sitemapDoc{URLs: []sitemapURL{{Loc: base + "/"}}}
+	for _, path := range paths {
+		doc.URLs = append(doc.URLs, sitemapURL{Loc: base + path, LastMod: sitemapTime(lists[path])})
+	}
+	doc.URLs = append(doc.URLs, articles...)
+	if len(doc.URLs) > sitemapLimit {
+		doc.URLs = doc.URLs[:sitemapLimit]
+	}
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(sitemapAge))
+	io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(doc); err != nil {
+		log.Printf("Error writing the sitemap: %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"VoAr/internal/markdown"
+)
+
+func TestArticleSlug(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"  Go 1.22 released ", "go-1-22-released"},
+		{"Привет мир", "привет-мир"},
+		{"?!", "article"},
+		{strings.Repeat("ab ", 40), strings.TrimRight(strings.Repeat("ab-", 27)[:maxSlugRunes], "-")},
+	}
+	for _, tt := range tests {
+		if got := articleSlug(tt.title); got != tt.want {
+			t.Errorf("articleSlug(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestKeepsSlug(t *testing.T) {
+	tests := []struct {
+		current string
+		want    bool
+	}{
+		{"go", true},
+		{"go-2", true},
+		{"go-12", true},
+		{"go-1", false},
+		{"go-02", false},
+		{"go-lang", false},
+		{"golang", false},
+	}
+	for _, tt := range tests {
+		if got := keepsSlug(tt.current, "go"); got != tt.want {
+			t.Errorf("keepsSlug(%q, %q) = %v, want %v", tt.current, "go", got, tt.want)
+		}
+	}
+}
+
+func TestDescription(t *testing.T) {
+	if got := description("  A short\n anons  "); got != "A short anons" {
+		t.Errorf("short description %q", got)
+	}
+	got := description(strings.Repeat("word ", 100))
+	if len([]rune(got)) > maxDescriptionRunes || !strings.HasSuffix(got, "word…") {
+		t.Errorf("long description %q, want it cut after a word", got)
+	}
+}
+
+func TestShowPostRedirectsOldAddress(t *testing.T) {
+	f := newFixture(t)
+	id := strconv.Itoa(f.published.Id)
+
+	w := httptest.NewRecorder()
+	ShowPost(w, f.request("GET", "/show/"+id, nil, map[string]string{"id": id}), f.store, markdown.NewCache(10))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != f.published.URL() {
+		t.Errorf("got status %d to %q, want %d to %q", w.Code, w.Header().Get("Location"), http.StatusMovedPermanently, f.published.URL())
+	}
+
+	//Renaming the article moves it, the earlier slug redirects to the new address
+	old := f.published.Slug
+	post := f.published
+	post.Title = "Renamed article"
+	if err := f.store.Articles.Update(&post, f.author.ID); err != nil {
+		t.Fatalf("updating article: %v", err)
+	}
+	w = httptest.NewRecorder()
+	ShowPost(w, f.request("GET", "/articles/"+old, nil, map[string]string{"slug": old}), f.store, markdown.NewCache(10))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/articles/renamed-article" {
+		t.Errorf("earlier slug: status %d to %q, want %d to %q", w.Code, w.Header().Get("Location"), http.StatusMovedPermanently, "/articles/renamed-article")
+	}
+}
+
+func TestArticleMeta(t *testing.T) {
+	t.Setenv("BASE_URL", "https://blog.example.com/")
+	f := newFixture(t)
+
+	tests := []struct {
+		name string
+		post Pst
+		want []string
+		not  []string
+	}{
+		{"published article", f.published, []string{
+			`<link rel="canonical" href="https://blog.example.com/articles/published-article">`,
+			`<meta property="og:type" content="article">`,
+			`<meta property="og:title" content="Published article">`,
+		}, []string{`content="noindex"`}},
+		{"draft", f.draft, []string{`<meta name="robots" content="noindex">`}, []string{`rel="canonical"`}},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := f.request("GET", tt.post.URL(), f.author, map[string]string{"slug": tt.post.Slug})
+		ShowPost(w, r, f.store, markdown.NewCache(10))
+		page := w.Body.String()
+		for _, s := range tt.want {
+			if !strings.Contains(page, s) {
+				t.Errorf("%s: page misses %s", tt.name, s)
+			}
+		}
+		for _, s := range tt.not {
+			if strings.Contains(page, s) {
+				t.Errorf("%s: page has %s", tt.name, s)
+			}
+		}
+	}
+}
+
+func TestSitemap(t *testing.T) {
+	t.Setenv("BASE_URL", "https://blog.example.com")
+	f := newFixture(t)
+	f.tagArticle(t, "Tagged article", "Go", nil)
+
+	w := httptest.NewRecorder()
+	Sitemap(w, f.request("GET", "/sitemap.xml", f.author, nil), f.store)
+	var doc sitemapDoc
+	if err := xml.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("decoding sitemap: %v", err)
+	}
+	var locs []string
+	for _, u := range doc.URLs {
+		locs = append(locs, strings.TrimPrefix(u.Loc, "https://blog.example.com"))
+	}
+	want := []string{"/", "/author/" + strconv.Itoa(f.author.ID), "/post", "/tag/go", "/articles/tagged-article", "/articles/published-article"}
+	if strings.Join(locs, " ") != strings.Join(want, " ") {
+		t.Errorf("sitemap lists %q, want %q", locs, want)
+	}
+}
+
+func TestRobots(t *testing.T) {
+	t.Setenv("BASE_URL", "https://blog.example.com")
+	w := httptest.NewRecorder()
+	Robots(w, httptest.NewRequest("GET", "/robots.txt", nil))
+	body := w.Body.String()
+	if !strings.Contains(body, "Disallow: /admin\n") || !strings.HasSuffix(body, "Sitemap: https://blog.example.com/sitemap.xml\n") {
+		t.Errorf("robots.txt %q", body)
+	}
+}
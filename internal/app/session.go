@@ -0,0 +1,105 @@
+package app
+
+import (
+	"VoAr/pkg/pgsession"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/sessions"
+	"github.com/markbates/goth"
+	"github.com/markbates/goth/gothic"
+)
+
+// SessionName is the name of the session that holds the signed-in user, kept in the store configured by oauth.Setup()
+const SessionName = "session-name"
+
+// SessionRevoker is implemented by session stores that can end every session of a user
+type SessionRevoker interface {
+	RevokeUser(userID int) error
+}
+
+// SessionRegenerator is implemented by session stores that can move a session to a new ID
+type SessionRegenerator interface {
+	Regenerate(session *sessions.Session) error
+}
+
+// SessionUser returns the user stored in the session after a successful OAuth callback
+// The second return value reports whether a signed-in user was found
+func SessionUser(r *http.Request) (goth.User, bool) {
+	session, err := gothic.Store.Get(r, SessionName)
+	if err != nil {
+		return goth.User{}, false
+	}
+	user, ok := session.Values["user"].(goth.User)
+	return user, ok
+}
+
+// SessionUserID returns the users.id of the signed-in user
+func SessionUserID(r *http.Request) (int, bool) {
+	session, err := gothic.Store.Get(r, SessionName)
+	if err != nil {
+		return 0, false
+	}
+	id, ok := session.Values[pgsession.UserIDKey].(int)
+	return id, ok
+}
+
+// SaveSessionUser stores the authenticated user in the session
+// Only the identity fields are kept so that tokens and raw provider data never reach the session
+// The userID links the session to the users row, so it can be revoked with the other sessions of the user
+// Every sign-in starts a new session ID, so a session ID known before the sign-in cannot be used to act as the user
+func SaveSessionUser(w http.ResponseWriter, r *http.Request, user goth.User, userID int) error {
+	session, _ := gothic.Store.Get(r, SessionName)
+	if regenerator, ok := gothic.Store.(SessionRegenerator); ok {
+		if err := regenerator.Regenerate(session); err != nil {
+			return err
+		}
+	}
+	session.Values["user"] = goth.User{
+		Provider:  user.Provider,
+		UserID:    user.UserID,
+		Name:      user.Name,
+		Email:     user.Email,
+		AvatarURL: user.AvatarURL,
+	}
+	session.Values[pgsession.UserIDKey] = userID
+	return session.Save(r, w)
+}
+
+// clearSession deletes the session of the browser together with its cookie
+func clearSession(w http.ResponseWriter, r *http.Request) {
+	session, _ := gothic.Store.Get(r, SessionName)
+	session.Options.MaxAge = -1
+	if err := session.Save(r, w); err != nil {
+		log.Printf("Error deleting session cookie: %v", err)
+	}
+}
+
+// LogoutEverywhere is an HTTP handler function that ends every session of the signed-in user
+// Other browsers and devices are signed out on their next request
+func LogoutEverywhere(w http.ResponseWriter, r *http.Request) {
+	//Retrieving the users row linked to the current session
+	userID, ok := SessionUserID(r)
+	if !ok {
+		http.Error(w, "Please sign in first", http.StatusUnauthorized)
+		return
+	}
+
+	//Revoking the sessions on the server, which only works with a server-side session store
+	revoker, ok := gothic.Store.(SessionRevoker)
+	if !ok {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Session store %T cannot revoke sessions", gothic.Store)
+		return
+	}
+	if err := revoker.RevokeUser(userID); err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error revoking sessions of user %d: %v", userID, err)
+		return
+	}
+
+	//Expiring the cookie of the current browser as well
+	clearSession(w, r)
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"log"
+	"time"
+)
+
+// Status is the publication status of an article, only published articles are shown to other users
+type Status string
+
+const (
+	StatusDraft     Status = "draft"     //Being written, only the author and moderators can see it
+	StatusScheduled Status = "scheduled" //Published by the scheduler once PublishedAt has come
+	StatusPublished Status = "published" //Listed and readable by everyone
+	StatusArchived  Status = "archived"  //Taken off the lists, the author can publish it again
+)
+
+// publishTimeLayout is the format of the publication time of the datetime-local input of the article forms
+const publishTimeLayout = "2006-01-02T15:04"
+
+// statusTransitions maps every status to the statuses an article can move to from it
+var statusTransitions = map[Status][]Status{
+	StatusDraft:     {StatusScheduled, StatusPublished},
+	StatusScheduled: {StatusDraft, StatusPublished},
+	StatusPublished: {StatusDraft, StatusArchived},
+	StatusArchived:  {StatusDraft, StatusPublished},
+}
+
+// Valid reports whether the status is one of the article statuses
+func (s Status) Valid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+// CanBecome reports whether an article with the status can move to next, keeping the status is always allowed
+func (s Status) CanBecome(next Status) bool {
+	if s == next {
+		return s.Valid()
+	}
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// Public reports whether everybody can see the article: it is published and neither hidden nor deleted
+func (post Pst) Public() bool {
+	return post.Status == StatusPublished && !post.Hidden && !post.Deleted
+}
+
+// nextStatuses returns the statuses offered by the article forms for an article with the status, starting with the status itself
+func nextStatuses(s Status) []Status {
+	return append([]Status{s}, statusTransitions[s]...)
+}
+
+// applyStatus moves the article to the submitted status, an empty status keeps the current one
+// New articles start out as drafts. Scheduled articles need a publication time in the future,
+// Publishing sets the publication time to now unless an archived article is published again
+// It returns a message for the client when the change is rejected
+func applyStatus(post *Pst, status string, publishAt, now time.Time) string {
+	from := post.Status
+	if from == "" {
+		from = StatusDraft
+	}
+	next := Status(status)
+	if status == "" {
+		next = from
+	}
+	if !next.Valid() {
+		return "Unknown status"
+	}
+	if !from.CanBecome(next) {
+		return "An article cannot go from " + string(from) + " to " + string(next)
+	}
+
+	switch next {
+	case StatusScheduled:
+		if publishAt.IsZero() && (from != StatusScheduled || post.PublishedAt == nil) {
+			return "Please choose when to publish the article"
+		}
+		if !publishAt.IsZero() {
+			if !publishAt.After(now) {
+				return "Please choose a publication time in the future"
+			}
+			post.PublishedAt = &publishAt
+		}
+	case StatusPublished:
+		if from != StatusPublished && (from != StatusArchived || post.PublishedAt == nil) {
+			post.PublishedAt = &now
+		}
+	case StatusDraft:
+		post.PublishedAt = nil
+	}
+	post.Status = next
+	return ""
+}
+
+// parsePublishTime parses the publication time of the article forms in the time zone of the server, empty means none
+func parsePublishTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, true
+	}
+	t, err := time.ParseInLocation(publishTimeLayout, value, time.Local)
+	return t, err == nil
+}
+
+// PublishScheduled publishes the scheduled articles whose publication time has come every interval
+// It blocks forever and is meant to be started with "go"
+func PublishScheduled(store *Store, interval time.Duration) {
+	for now := range time.Tick(interval) {
+		published, err := store.Articles.PublishDue(now)
+		if err != nil {
+			log.Printf("Error publishing scheduled articles: %v", err)
+			continue
+		}
+		if published > 0 {
+			log.Printf("Published %d scheduled article(s)", published)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestApplyStatus(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	earlier, later := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		from      Status
+		published *time.Time
+		status    string
+		publishAt time.Time
+		wantErr   bool
+		want      Status
+		wantAt    *time.Time
+	}{
+		{"new article", "", nil, "", time.Time{}, false, StatusDraft, nil},
+		{"publish draft", StatusDraft, nil, "published", time.Time{}, false, StatusPublished, &now},
+		{"schedule draft", StatusDraft, nil, "scheduled", later, false, StatusScheduled, &later},
+		{"schedule without time", StatusDraft, nil, "scheduled", time.Time{}, true, StatusDraft, nil},
+		{"schedule in the past", StatusDraft, nil, "scheduled", earlier, true, StatusDraft, nil},
+		{"archive draft", StatusDraft, nil, "archived", time.Time{}, true, StatusDraft, nil},
+		{"unknown status", StatusDraft, nil, "secret", time.Time{}, true, StatusDraft, nil},
+		{"archive published", StatusPublished, &earlier, "archived", time.Time{}, false, StatusArchived, &earlier},
+		{"republish archived", StatusArchived, &earlier, "published", time.Time{}, false, StatusPublished, &earlier},
+		{"unpublish", StatusPublished, &earlier, "draft", time.Time{}, false, StatusDraft, nil},
+	}
+	for _, tt := range tests {
+		post := Pst{Status: tt.from, PublishedAt: tt.published}
+		msg := applyStatus(&post, tt.status, tt.publishAt, now)
+		if (msg != "") != tt.wantErr {
+			t.Errorf("%s: message %q, want an error %v", tt.name, msg, tt.wantErr)
+		}
+		if tt.wantErr {
+			continue
+		}
+		if post.Status != tt.want {
+			t.Errorf("%s: status %q, want %q", tt.name, post.Status, tt.want)
+		}
+		if (post.PublishedAt == nil) != (tt.wantAt == nil) || (tt.wantAt != nil && !post.PublishedAt.Equal(*tt.wantAt)) {
+			t.Errorf("%s: published at %v, want %v", tt.name, post.PublishedAt, tt.wantAt)
+		}
+	}
+}
+
+func TestPublishDue(t *testing.T) {
+	f := newFixture(t)
+	now := time.Now()
+	due, future := now.Add(-time.Minute), now.Add(time.Hour)
+
+	for _, at := range []*time.Time{&due, &future} {
+		post := Pst{Title: "Scheduled", Anons: "Anons", Full_Text: "Text", UserId: f.author.ID, Status: StatusScheduled, PublishedAt: at}
+		if err := f.store.Articles.Create(&post); err != nil {
+			t.Fatalf("creating article: %v", err)
+		}
+	}
+
+	published, err := f.store.Articles.PublishDue(now)
+	if err != nil || published != 1 {
+		t.Fatalf("published %d articles (%v), want 1", published, err)
+	}
+	posts, err := f.store.Articles.List(ArticleFilter{}, 10, 0)
+	if err != nil {
+		t.Fatalf("listing: %v", err)
+	}
+	if got := articleTitles(posts); len(got) != 2 || got[0] != "Scheduled" {
+		t.Errorf("guests see %q, want the due article and the published one", got)
+	}
+}
@@ -0,0 +1,228 @@
+package app
+
+import (
+	"errors"
+	"html/template"
+	"time"
+)
+
+var (
+	// ErrNotFound is returned by stores when the requested row does not exist
+	ErrNotFound = errors.New("not found")
+
+	// ErrUserExists is returned by UserStore.Create when the email is already taken
+	ErrUserExists = errors.New("user with the same name or email already exists")
+
+	// ErrIdentityLinked is returned by IdentityStore.Link when the identity belongs to another user
+	ErrIdentityLinked = errors.New("identity is already linked to another user")
+
+	// ErrCategoryExists is returned by CategoryStore.Create when the slug is already taken
+	ErrCategoryExists = errors.New("category with the same slug already exists")
+)
+
+// StoreKey is the context key for passing the Store to handlers
+const StoreKey ContextKey = "store"
+
+// ArticleStore is the storage of articles used by the HTTP handlers
+type ArticleStore interface {
+	// List returns up to limit visible articles matching the filter starting at offset, newest first,
+	// With their author, category and tags filled in. Articles are visible when they are published and not hidden,
+	// Unpublished articles of filter.Viewer are listed too. Deleted articles are always left out
+	List(filter ArticleFilter, limit, offset int) ([]Pst, error)
+	// Count returns the number of visible articles matching the filter, ignoring filter.Before
+	Count(filter ArticleFilter) (int, error)
+	// ListAll returns up to limit articles starting at offset including hidden and deleted ones, newest first
+	ListAll(limit, offset int) ([]Pst, error)
+	// Get returns the article with the given ID or ErrNotFound, hidden articles are returned, deleted ones are not
+	Get(id int) (Pst, error)
+	// FindBySlug returns the article with the given current or earlier slug or ErrNotFound, deleted articles are not found
+	FindBySlug(slug string) (Pst, error)
+	// Create inserts the article owned by post.UserId with its category, tags and status and sets post.Id, post.Slug and post.UpdatedAt
+	// Tags that do not exist yet are created, the article is recorded as the first revision written by its author
+	Create(post *Pst) error
+	// Update replaces the title, anons, full text, category, tags, status and publication time of the article
+	// And sets post.Revision to its new revision, which is recorded as made by the editor, and post.UpdatedAt.
+	// The slug follows the title, post.Slug is set to it. It returns ErrNotFound when the article does not exist
+	Update(post *Pst, editorID int) error
+	// Revisions returns the recorded revisions of the article with the names of their editors, newest first
+	Revisions(id int) ([]ArticleRevision, error)
+	// GetRevision returns the given revision of the article or ErrNotFound
+	GetRevision(id, revision int) (ArticleRevision, error)
+	// Delete marks the article as deleted or returns ErrNotFound, it can be restored afterwards
+	// Like SetHidden and Restore it moves the updated time of the article forward
+	Delete(id int) error
+	// SetHidden hides or shows the article or returns ErrNotFound
+	SetHidden(id int, hidden bool) error
+	// Restore brings back a hidden or deleted article or returns ErrNotFound
+	Restore(id int) error
+	// Stats counts the articles
+	Stats() (ArticleStats, error)
+	// LastChange returns the latest updated time of all articles, hidden and deleted ones included, or the zero time
+	// Without articles. Every change of an article moves it forward, also removing the article from the listings
+	LastChange() (time.Time, error)
+	// Search returns up to limit published articles matching the query starting at offset, best matches first,
+	// Together with the total number of matches. Hidden articles are left out
+	Search(query string, limit, offset int) ([]SearchResult, int, error)
+	// PublishDue publishes the scheduled articles whose publication time is not after now and returns their number
+	PublishDue(now time.Time) (int, error)
+}
+
+// ArticleRevision is the title, anons and full text of an article as saved by one create or update
+type ArticleRevision struct {
+	ArticleID  int
+	Revision   int
+	Title      string
+	Anons      string
+	Full_Text  string
+	UserID     int    //User who saved the revision, 0 when the account was removed
+	AuthorName string //Name of the user who saved the revision
+	CreatedAt  time.Time
+}
+
+// ArticleFilter narrows an article listing, empty fields do not filter
+type ArticleFilter struct {
+	Tag      string //Slug of a tag the articles must have
+	Category string //Slug of the category of the articles
+	Before   int    //Only articles with a lower ID, the cursor of keyset pagination
+	Viewer   int    //ID of the signed-in user, whose unpublished and hidden articles are listed as well
+	Author   int    //ID of the user who wrote the articles
+}
+
+// SearchResult is an article found by a search together with its rank and the highlighted passages
+type SearchResult struct {
+	Pst
+	Rank    float64       `json:"rank"`    //Relevance of the article for the query, higher is better
+	Snippet template.HTML `json:"snippet"` //Escaped passages of the article with the matches wrapped in <mark>
+}
+
+// ArticleStats are the article counts shown on the admin dashboard
+type ArticleStats struct {
+	Total   int
+	Hidden  int
+	Deleted int
+}
+
+// UserStore is the storage of users used by the HTTP handlers
+type UserStore interface {
+	// Create inserts a new user and sets user.ID, it returns ErrUserExists for a duplicate email
+	Create(user *User) error
+	// Get returns the user with the given ID or ErrNotFound
+	Get(id int) (User, error)
+	// FindByEmail returns the user with the given email or ErrNotFound
+	FindByEmail(email string) (User, error)
+	// SetPassword replaces the password hash of the user or returns ErrNotFound
+	SetPassword(id int, hash string) error
+	// MarkEmailVerified records that the user confirmed their email address
+	MarkEmailVerified(id int) error
+	// UpdateProfile stores the avatar and locale of the user, empty values keep the stored ones
+	UpdateProfile(id int, avatarURL, locale string) error
+	// SetRole changes the role of the user or returns ErrNotFound
+	SetRole(id int, role Role) error
+	// List returns up to limit users starting at offset, ordered by ID
+	List(limit, offset int) ([]User, error)
+	// SetBanned bans or unbans the user or returns ErrNotFound
+	SetBanned(id int, banned bool) error
+	// Stats counts the users
+	Stats() (UserStats, error)
+}
+
+// UserStats are the user counts shown on the admin dashboard
+type UserStats struct {
+	Total  int
+	Banned int
+	ByRole map[Role]int
+}
+
+// TokenStore keeps the hashes of one-time tokens, like email verification and password reset tokens
+type TokenStore interface {
+	// Create stores the hash of a token that the user can use for purpose until expires
+	Create(userID int, purpose, hash string, expires time.Time) error
+	// Consume marks an unused and unexpired token as used and returns its user, or ErrNotFound
+	Consume(purpose, hash string) (int, error)
+}
+
+// APITokenStore keeps the hashes of the personal API tokens, unlike the tokens of TokenStore they are used many times
+type APITokenStore interface {
+	// Create stores the token of token.UserID and sets token.ID and token.CreatedAt
+	Create(token *APIToken) error
+	// Use returns the token with the hash and records that it was used, or ErrNotFound
+	Use(hash string) (APIToken, error)
+	// ListByUser returns the tokens of the user, oldest first
+	ListByUser(userID int) ([]APIToken, error)
+	// Revoke deletes the token of the user or returns ErrNotFound
+	Revoke(userID, id int) error
+}
+
+// IdentityStore maps the identities of the third-party providers to users
+type IdentityStore interface {
+	// Find returns the identity with the given provider and provider user ID or ErrNotFound
+	Find(provider, providerUserID string) (Identity, error)
+	// ListByUser returns the identities linked to the user, oldest first
+	ListByUser(userID int) ([]Identity, error)
+	// Link links the identity to identity.UserID and sets identity.ID
+	// Linking an identity the user already has is a no-op, it returns ErrIdentityLinked when another user has it
+	Link(identity *Identity) error
+	// Unlink removes the identity of the user or returns ErrNotFound
+	Unlink(userID, id int) error
+}
+
+// TagStore reads the tags attached to articles, tags are created by ArticleStore.Create and Update
+type TagStore interface {
+	// FindBySlug returns the tag with the given slug or ErrNotFound
+	FindBySlug(slug string) (Tag, error)
+	// Cloud returns up to limit tags of published articles that are not hidden with their article counts, most used first
+	Cloud(limit int) ([]Tag, error)
+}
+
+// CategoryStore is the storage of the categories articles are filed under
+type CategoryStore interface {
+	// List returns every category ordered by name
+	List() ([]Category, error)
+	// FindBySlug returns the category with the given slug or ErrNotFound
+	FindBySlug(slug string) (Category, error)
+	// Create inserts the category and sets category.ID, it returns ErrCategoryExists when the slug is taken
+	Create(category *Category) error
+}
+
+// CommentStore is the storage of the comments on articles
+type CommentStore interface {
+	// ListByArticle returns every comment on the article including hidden and deleted ones, oldest first,
+	// With the name of their author filled in
+	ListByArticle(articleID int) ([]Comment, error)
+	// Get returns the comment with the given ID or ErrNotFound
+	Get(id int) (Comment, error)
+	// Create inserts the comment and sets comment.ID and comment.CreatedAt
+	Create(comment *Comment) error
+	// Update replaces the body of the comment and records when it was edited, it returns ErrNotFound
+	// When the comment does not exist or is deleted
+	Update(id int, body string) error
+	// Delete marks the comment as deleted or returns ErrNotFound, its replies are kept
+	Delete(id int) error
+	// SetHidden hides or shows the comment or returns ErrNotFound
+	SetHidden(id int, hidden bool) error
+}
+
+// MediaStore is the storage of the records of the files uploaded to articles, the files are in a blob.Storage
+type MediaStore interface {
+	// ListByArticle returns the files of the article, oldest first
+	ListByArticle(articleID int) ([]Media, error)
+	// Get returns the file with the given ID or ErrNotFound
+	Get(id int) (Media, error)
+	// Create inserts the file and sets media.ID and media.CreatedAt
+	Create(media *Media) error
+	// Delete removes the record of the file or returns ErrNotFound, the blobs are deleted by the caller
+	Delete(id int) error
+}
+
+// Store groups the storage interfaces that are injected into the handlers
+type Store struct {
+	Articles   ArticleStore
+	Users      UserStore
+	Tokens     TokenStore
+	APITokens  APITokenStore
+	Identities IdentityStore
+	Tags       TagStore
+	Categories CategoryStore
+	Comments   CommentStore
+	Media      MediaStore
+}
@@ -0,0 +1,951 @@
+package app
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewMemoryStore returns a Store that keeps everything in memory
+// It is meant for tests of the HTTP layer and for running without a database
+func NewMemoryStore() *Store {
+	users := &memUserStore{byID: map[int]User{}}
+	articles := &memArticleStore{
+		users: users, byID: map[int]Pst{}, revisions: map[int][]ArticleRevision{}, slugs: map[string]int{},
+		tags: map[string]Tag{}, tagSlugs: map[string]bool{},
+	}
+	return &Store{
+		Articles:   articles,
+		Users:      users,
+		Tokens:     &memTokenStore{byHash: map[string]memToken{}},
+		APITokens:  &memAPITokenStore{byID: map[int]APIToken{}},
+		Identities: &memIdentityStore{byID: map[int]Identity{}},
+		Tags:       &memTagStore{articles: articles},
+		Categories: &memCategoryStore{byID: map[int]Category{}},
+		Comments:   &memCommentStore{users: users, byID: map[int]Comment{}},
+		Media:      &memMediaStore{byID: map[int]Media{}},
+	}
+}
+
+// memArticleStore implements ArticleStore with a map guarded by a mutex
+type memArticleStore struct {
+	mu        sync.RWMutex
+	users     *memUserStore
+	byID      map[int]Pst
+	revisions map[int][]ArticleRevision //Revisions of every article, oldest first
+	slugs     map[string]int            //ID of the article of every current and earlier slug
+	tags      map[string]Tag            //Every tag ever used by its lower case name
+	tagSlugs  map[string]bool           //Slugs of the tags
+	nextID    int
+}
+
+// withAuthor fills in the author fields of the article from the user store
+// The tags are copied, so that callers cannot change the stored article
+func (s *memArticleStore) withAuthor(post Pst) Pst {
+	if user, ok := s.users.get(post.UserId); ok {
+		post.AuthorName, post.AuthorEmail = user.Name, user.Email
+	}
+	post.Tags = append([]Tag{}, post.Tags...)
+	return post
+}
+
+// matches reports whether the article is visible to the viewer of the filter and passes the filter
+func (f ArticleFilter) matches(post Pst) bool {
+	own := f.Viewer != 0 && post.UserId == f.Viewer && !post.Deleted
+	if !post.Public() && !own {
+		return false
+	}
+	if f.Category != "" && (post.Category == nil || post.Category.Slug != f.Category) {
+		return false
+	}
+	if f.Author != 0 && post.UserId != f.Author {
+		return false
+	}
+	if f.Tag == "" {
+		return true
+	}
+	for _, tag := range post.Tags {
+		if tag.Slug == f.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedIDs returns the IDs of the articles in ascending order, the caller must hold the mutex
+func (s *memArticleStore) sortedIDs() []int {
+	ids := make([]int, 0, len(s.byID))
+	for id := range s.byID {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func (s *memArticleStore) List(filter ArticleFilter, limit, offset int) ([]Pst, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	//Listing by descending ID to get the same order as the PostgreSQL store
+	ids := s.sortedIDs()
+	posts := []Pst{}
+	skipped := 0
+	for i := len(ids) - 1; i >= 0; i-- {
+		post := s.byID[ids[i]]
+		if !filter.matches(post) || (filter.Before != 0 && post.Id >= filter.Before) {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		if len(posts) == limit {
+			break
+		}
+		posts = append(posts, s.withAuthor(post))
+	}
+	return posts, nil
+}
+
+func (s *memArticleStore) Count(filter ArticleFilter) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	count := 0
+	for _, post := range s.byID {
+		if filter.matches(post) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *memArticleStore) ListAll(limit, offset int) ([]Pst, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.sortedIDs()
+	posts := []Pst{}
+	for i := len(ids) - 1 - offset; i >= 0 && len(posts) < limit; i-- {
+		posts = append(posts, s.withAuthor(s.byID[ids[i]]))
+	}
+	return posts, nil
+}
+
+func (s *memArticleStore) Get(id int) (Pst, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	post, ok := s.byID[id]
+	if !ok || post.Deleted {
+		return Pst{}, ErrNotFound
+	}
+	return s.withAuthor(post), nil
+}
+
+func (s *memArticleStore) FindBySlug(slug string) (Pst, error) {
+	s.mu.RLock()
+	id, ok := s.slugs[slug]
+	s.mu.RUnlock()
+	if !ok {
+		return Pst{}, ErrNotFound
+	}
+	return s.Get(id)
+}
+
+// claimSlug sets the slug of the article to the first free candidate for its title and records it for the article
+// The current slug is kept while it fits the title, the caller must hold the mutex
+func (s *memArticleStore) claimSlug(post *Pst, current string) {
+	base := articleSlug(post.Title)
+	if keepsSlug(current, base) {
+		post.Slug = current
+		return
+	}
+	for n := 1; ; n++ {
+		slug := numberedSlug(base, n)
+		if owner, taken := s.slugs[slug]; !taken || owner == post.Id {
+			s.slugs[slug] = post.Id
+			post.Slug = slug
+			return
+		}
+	}
+}
+
+// claimTags replaces the tags with the stored tags of the same name, ignoring case, and stores new tags
+// Under the first free candidate of their slug, like the PostgreSQL store. The caller must hold the mutex
+func (s *memArticleStore) claimTags(tags []Tag) {
+	for i, tag := range tags {
+		key := strings.ToLower(tag.Name)
+		if stored, ok := s.tags[key]; ok {
+			tags[i] = stored
+			continue
+		}
+		for n := 1; ; n++ {
+			if slug := numberedSlug(tag.Slug, n); !s.tagSlugs[slug] {
+				tags[i].Slug = slug
+				break
+			}
+		}
+		s.tags[key], s.tagSlugs[tags[i].Slug] = tags[i], true
+	}
+}
+
+func (s *memArticleStore) Create(post *Pst) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	post.Id = s.nextID
+	s.claimSlug(post, "")
+	s.claimTags(post.Tags)
+	post.Revision = 1
+	post.UpdatedAt = time.Now()
+	stored := *post
+	stored.Tags = append([]Tag{}, post.Tags...)
+	s.byID[post.Id] = stored
+	s.saveRevision(stored, post.UserId)
+	return nil
+}
+
+// saveRevision records the current revision of the article as saved by the user, the caller must hold the mutex
+func (s *memArticleStore) saveRevision(post Pst, userID int) {
+	s.revisions[post.Id] = append(s.revisions[post.Id], ArticleRevision{
+		ArticleID: post.Id, Revision: post.Revision, Title: post.Title, Anons: post.Anons, Full_Text: post.Full_Text,
+		UserID: userID, CreatedAt: time.Now(),
+	})
+}
+
+// withEditor fills in the name of the user who saved the revision from the user store
+func (s *memArticleStore) withEditor(rev ArticleRevision) ArticleRevision {
+	if user, ok := s.users.get(rev.UserID); ok {
+		rev.AuthorName = user.Name
+	}
+	return rev
+}
+
+func (s *memArticleStore) Revisions(id int) ([]ArticleRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stored := s.revisions[id]
+	revisions := make([]ArticleRevision, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		revisions = append(revisions, s.withEditor(stored[i]))
+	}
+	return revisions, nil
+}
+
+func (s *memArticleStore) GetRevision(id, revision int) (ArticleRevision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, rev := range s.revisions[id] {
+		if rev.Revision == revision {
+			return s.withEditor(rev), nil
+		}
+	}
+	return ArticleRevision{}, ErrNotFound
+}
+
+func (s *memArticleStore) Update(post *Pst, editorID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.byID[post.Id]
+	if !ok || stored.Deleted {
+		return ErrNotFound
+	}
+	s.claimTags(post.Tags)
+	stored.Title, stored.Anons, stored.Full_Text = post.Title, post.Anons, post.Full_Text
+	stored.Category, stored.Tags = post.Category, append([]Tag{}, post.Tags...)
+	stored.Status, stored.PublishedAt = post.Status, post.PublishedAt
+	stored.Revision++
+	stored.UpdatedAt = time.Now()
+	s.claimSlug(post, stored.Slug)
+	stored.Slug = post.Slug
+	post.Revision, post.UpdatedAt = stored.Revision, stored.UpdatedAt
+	s.byID[post.Id] = stored
+	s.saveRevision(stored, editorID)
+	return nil
+}
+
+func (s *memArticleStore) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.byID[id]
+	if !ok || post.Deleted {
+		return ErrNotFound
+	}
+	post.Deleted, post.UpdatedAt = true, time.Now()
+	s.byID[id] = post
+	return nil
+}
+
+func (s *memArticleStore) SetHidden(id int, hidden bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.byID[id]
+	if !ok || post.Deleted {
+		return ErrNotFound
+	}
+	post.Hidden, post.UpdatedAt = hidden, time.Now()
+	s.byID[id] = post
+	return nil
+}
+
+func (s *memArticleStore) Restore(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post, ok := s.byID[id]
+	if !ok {
+		return ErrNotFound
+	}
+	post.Hidden, post.Deleted, post.UpdatedAt = false, false, time.Now()
+	s.byID[id] = post
+	return nil
+}
+
+func (s *memArticleStore) Stats() (ArticleStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := ArticleStats{Total: len(s.byID)}
+	for _, post := range s.byID {
+		switch {
+		case post.Deleted:
+			stats.Deleted++
+		case post.Hidden:
+			stats.Hidden++
+		}
+	}
+	return stats, nil
+}
+
+func (s *memArticleStore) LastChange() (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var last time.Time
+	for _, post := range s.byID {
+		if post.UpdatedAt.After(last) {
+			last = post.UpdatedAt
+		}
+	}
+	return last, nil
+}
+
+func (s *memArticleStore) PublishDue(now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	published := 0
+	for id, post := range s.byID {
+		if post.Status == StatusScheduled && !post.Deleted && post.PublishedAt != nil && !post.PublishedAt.After(now) {
+			post.Status, post.UpdatedAt = StatusPublished, time.Now()
+			s.byID[id] = post
+			published++
+		}
+	}
+	return published, nil
+}
+
+func (s *memArticleStore) Search(query string, limit, offset int) ([]SearchResult, int, error) {
+	terms := searchTerms(query)
+	if len(terms) == 0 {
+		return []SearchResult{}, 0, nil
+	}
+
+	s.mu.RLock()
+	matches := []SearchResult{}
+	for _, post := range s.byID {
+		if !post.Public() {
+			continue
+		}
+		if result, ok := matchArticle(s.withAuthor(post), terms); ok {
+			matches = append(matches, result)
+		}
+	}
+	s.mu.RUnlock()
+
+	sortResults(matches)
+	results := []SearchResult{}
+	for i := offset; i < len(matches) && len(results) < limit; i++ {
+		results = append(results, matches[i])
+	}
+	return results, len(matches), nil
+}
+
+// memUserStore implements UserStore with a map guarded by a mutex
+type memUserStore struct {
+	mu     sync.RWMutex
+	byID   map[int]User
+	nextID int
+}
+
+// get returns the user with the given ID
+func (s *memUserStore) get(id int) (User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.byID[id]
+	return user, ok
+}
+
+// findLocked looks a user up by email, the caller must hold the mutex
+func (s *memUserStore) findLocked(email string) (User, bool) {
+	for _, user := range s.byID {
+		if user.Email == email {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+func (s *memUserStore) Create(user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.findLocked(user.Email); ok {
+		return ErrUserExists
+	}
+	if user.Role == "" {
+		user.Role = DefaultRole
+	}
+	s.nextID++
+	user.ID = s.nextID
+	s.byID[user.ID] = *user
+	return nil
+}
+
+func (s *memUserStore) Get(id int) (User, error) {
+	user, ok := s.get(id)
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *memUserStore) FindByEmail(email string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.findLocked(email)
+	if !ok {
+		return User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (s *memUserStore) SetPassword(id int, hash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.byID[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.PasswordHash = hash
+	s.byID[id] = user
+	return nil
+}
+
+func (s *memUserStore) MarkEmailVerified(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.byID[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.EmailVerified = true
+	s.byID[id] = user
+	return nil
+}
+
+func (s *memUserStore) UpdateProfile(id int, avatarURL, locale string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.byID[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if avatarURL != "" {
+		user.AvatarURL = avatarURL
+	}
+	if locale != "" {
+		user.Locale = locale
+	}
+	s.byID[id] = user
+	return nil
+}
+
+func (s *memUserStore) SetRole(id int, role Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.byID[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Role = role
+	s.byID[id] = user
+	return nil
+}
+
+func (s *memUserStore) List(limit, offset int) ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]int, 0, len(s.byID))
+	for id := range s.byID {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	users := []User{}
+	for i := offset; i < len(ids) && len(users) < limit; i++ {
+		users = append(users, s.byID[ids[i]])
+	}
+	return users, nil
+}
+
+func (s *memUserStore) SetBanned(id int, banned bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.byID[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Banned = banned
+	s.byID[id] = user
+	return nil
+}
+
+func (s *memUserStore) Stats() (UserStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	stats := UserStats{Total: len(s.byID), ByRole: map[Role]int{}}
+	for _, user := range s.byID {
+		stats.ByRole[user.Role]++
+		if user.Banned {
+			stats.Banned++
+		}
+	}
+	return stats, nil
+}
+
+// memToken is a one-time token kept by memTokenStore
+type memToken struct {
+	userID  int
+	purpose string
+	expires time.Time
+	used    bool
+}
+
+// memTokenStore implements TokenStore with a map guarded by a mutex
+type memTokenStore struct {
+	mu     sync.Mutex
+	byHash map[string]memToken
+}
+
+func (s *memTokenStore) Create(userID int, purpose, hash string, expires time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.byHash[hash] = memToken{userID: userID, purpose: purpose, expires: expires}
+	return nil
+}
+
+func (s *memTokenStore) Consume(purpose, hash string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.byHash[hash]
+	if !ok || token.used || token.purpose != purpose || time.Now().After(token.expires) {
+		return 0, ErrNotFound
+	}
+	token.used = true
+	s.byHash[hash] = token
+	return token.userID, nil
+}
+
+// memAPITokenStore implements APITokenStore with a map guarded by a mutex
+type memAPITokenStore struct {
+	mu     sync.Mutex
+	byID   map[int]APIToken
+	nextID int
+}
+
+func (s *memAPITokenStore) Create(token *APIToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	token.ID = s.nextID
+	token.CreatedAt = time.Now()
+	s.byID[token.ID] = *token
+	return nil
+}
+
+func (s *memAPITokenStore) Use(hash string) (APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, token := range s.byID {
+		if token.Hash == hash {
+			now := time.Now()
+			token.LastUsedAt = &now
+			s.byID[id] = token
+			return token, nil
+		}
+	}
+	return APIToken{}, ErrNotFound
+}
+
+func (s *memAPITokenStore) ListByUser(userID int) ([]APIToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tokens := []APIToken{}
+	for _, token := range s.byID {
+		if token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].ID < tokens[j].ID })
+	return tokens, nil
+}
+
+func (s *memAPITokenStore) Revoke(userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.byID[id]
+	if !ok || token.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.byID, id)
+	return nil
+}
+
+// memIdentityStore implements IdentityStore with a map guarded by a mutex
+type memIdentityStore struct {
+	mu     sync.RWMutex
+	byID   map[int]Identity
+	nextID int
+}
+
+// findLocked looks an identity up by provider, the caller must hold the mutex
+func (s *memIdentityStore) findLocked(provider, providerUserID string) (Identity, bool) {
+	for _, identity := range s.byID {
+		if identity.Provider == provider && identity.ProviderUserID == providerUserID {
+			return identity, true
+		}
+	}
+	return Identity{}, false
+}
+
+func (s *memIdentityStore) Find(provider, providerUserID string) (Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	identity, ok := s.findLocked(provider, providerUserID)
+	if !ok {
+		return Identity{}, ErrNotFound
+	}
+	return identity, nil
+}
+
+func (s *memIdentityStore) ListByUser(userID int) ([]Identity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	identities := []Identity{}
+	for _, identity := range s.byID {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool { return identities[i].ID < identities[j].ID })
+	return identities, nil
+}
+
+func (s *memIdentityStore) Link(identity *Identity) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.findLocked(identity.Provider, identity.ProviderUserID); ok {
+		if existing.UserID != identity.UserID {
+			return ErrIdentityLinked
+		}
+		*identity = existing
+		return nil
+	}
+	s.nextID++
+	identity.ID = s.nextID
+	identity.CreatedAt = time.Now()
+	s.byID[identity.ID] = *identity
+	return nil
+}
+
+func (s *memIdentityStore) Unlink(userID, id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.byID[id]
+	if !ok || identity.UserID != userID {
+		return ErrNotFound
+	}
+	delete(s.byID, id)
+	return nil
+}
+
+// memTagStore implements TagStore by looking at the tags of the articles in memory
+type memTagStore struct {
+	articles *memArticleStore
+}
+
+func (s *memTagStore) FindBySlug(slug string) (Tag, error) {
+	s.articles.mu.RLock()
+	defer s.articles.mu.RUnlock()
+
+	for _, post := range s.articles.byID {
+		for _, tag := range post.Tags {
+			if tag.Slug == slug {
+				return Tag{Name: tag.Name, Slug: tag.Slug}, nil
+			}
+		}
+	}
+	return Tag{}, ErrNotFound
+}
+
+func (s *memTagStore) Cloud(limit int) ([]Tag, error) {
+	s.articles.mu.RLock()
+	counts := map[string]*Tag{}
+	for _, post := range s.articles.byID {
+		if !post.Public() {
+			continue
+		}
+		for _, tag := range post.Tags {
+			if counts[tag.Slug] == nil {
+				counts[tag.Slug] = &Tag{Name: tag.Name, Slug: tag.Slug}
+			}
+			counts[tag.Slug].Count++
+		}
+	}
+	s.articles.mu.RUnlock()
+
+	tags := []Tag{}
+	for _, tag := range counts {
+		tags = append(tags, *tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	return tags, nil
+}
+
+// memCategoryStore implements CategoryStore with a map guarded by a mutex
+type memCategoryStore struct {
+	mu     sync.RWMutex
+	byID   map[int]Category
+	nextID int
+}
+
+func (s *memCategoryStore) List() ([]Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	categories := []Category{}
+	for _, category := range s.byID {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool { return categories[i].Name < categories[j].Name })
+	return categories, nil
+}
+
+func (s *memCategoryStore) FindBySlug(slug string) (Category, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, category := range s.byID {
+		if category.Slug == slug {
+			return category, nil
+		}
+	}
+	return Category{}, ErrNotFound
+}
+
+func (s *memCategoryStore) Create(category *Category) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, existing := range s.byID {
+		if existing.Slug == category.Slug {
+			return ErrCategoryExists
+		}
+	}
+	s.nextID++
+	category.ID = s.nextID
+	s.byID[category.ID] = *category
+	return nil
+}
+
+// memCommentStore implements CommentStore with a map guarded by a mutex
+type memCommentStore struct {
+	mu     sync.RWMutex
+	users  *memUserStore
+	byID   map[int]Comment
+	nextID int
+}
+
+// withAuthor fills in the name of the author of the comment from the user store
+func (s *memCommentStore) withAuthor(comment Comment) Comment {
+	if user, ok := s.users.get(comment.UserID); ok {
+		comment.AuthorName = user.Name
+	}
+	return comment
+}
+
+func (s *memCommentStore) ListByArticle(articleID int) ([]Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comments := []Comment{}
+	for _, comment := range s.byID {
+		if comment.ArticleID == articleID {
+			comments = append(comments, s.withAuthor(comment))
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].ID < comments[j].ID })
+	return comments, nil
+}
+
+func (s *memCommentStore) Get(id int) (Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	comment, ok := s.byID[id]
+	if !ok {
+		return Comment{}, ErrNotFound
+	}
+	return s.withAuthor(comment), nil
+}
+
+func (s *memCommentStore) Create(comment *Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	comment.ID = s.nextID
+	comment.CreatedAt = time.Now()
+	s.byID[comment.ID] = *comment
+	return nil
+}
+
+func (s *memCommentStore) Update(id int, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.byID[id]
+	if !ok || comment.Deleted {
+		return ErrNotFound
+	}
+	now := time.Now()
+	comment.Body, comment.EditedAt = body, &now
+	s.byID[id] = comment
+	return nil
+}
+
+func (s *memCommentStore) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.byID[id]
+	if !ok || comment.Deleted {
+		return ErrNotFound
+	}
+	comment.Deleted = true
+	s.byID[id] = comment
+	return nil
+}
+
+func (s *memCommentStore) SetHidden(id int, hidden bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	comment, ok := s.byID[id]
+	if !ok || comment.Deleted {
+		return ErrNotFound
+	}
+	comment.Hidden = hidden
+	s.byID[id] = comment
+	return nil
+}
+
+// memMediaStore implements MediaStore with a map guarded by a mutex
+type memMediaStore struct {
+	mu     sync.RWMutex
+	byID   map[int]Media
+	nextID int
+}
+
+func (s *memMediaStore) ListByArticle(articleID int) ([]Media, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files := []Media{}
+	for _, media := range s.byID {
+		if media.ArticleID == articleID {
+			files = append(files, media)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ID < files[j].ID })
+	return files, nil
+}
+
+func (s *memMediaStore) Get(id int) (Media, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	media, ok := s.byID[id]
+	if !ok {
+		return Media{}, ErrNotFound
+	}
+	return media, nil
+}
+
+func (s *memMediaStore) Create(media *Media) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextID++
+	media.ID = s.nextID
+	media.CreatedAt = time.Now()
+	s.byID[media.ID] = *media
+	return nil
+}
+
+func (s *memMediaStore) Delete(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.byID[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.byID, id)
+	return nil
+}
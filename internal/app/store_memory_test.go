@@ -0,0 +1,166 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"VoAr/internal/render"
+	"VoAr/web"
+
+	"github.com/gorilla/mux"
+)
+
+// fixture is a memory store with users of every kind and articles in every state of visibility, shared by the handler tests
+type fixture struct {
+	store                             *Store
+	renderer                          *render.Renderer
+	author, other, editor, reader     *User
+	admin                             *User
+	published, draft, hidden, deleted Pst
+}
+
+// newFixture creates the users and the articles of the fixture, every article is written by the author
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+	renderer, err := render.New(web.Files, false, TemplateFuncs, Pages...)
+	if err != nil {
+		t.Fatalf("parsing templates: %v", err)
+	}
+	f := &fixture{store: NewMemoryStore(), renderer: renderer}
+
+	for _, u := range []struct {
+		user  **User
+		name  string
+		email string
+		role  Role
+	}{
+		{&f.author, "Author", "author@example.com", RoleAuthor},
+		{&f.other, "Other author", "other@example.com", RoleAuthor},
+		{&f.editor, "Editor", "editor@example.com", RoleEditor},
+		{&f.reader, "Reader", "reader@example.com", RoleReader},
+		{&f.admin, "Admin", "admin@example.com", RoleAdmin},
+	} {
+		user := &User{Name: u.name, Email: u.email, Role: u.role}
+		if err := f.store.Users.Create(user); err != nil {
+			t.Fatalf("creating user %s: %v", u.name, err)
+		}
+		*u.user = user
+	}
+
+	now := time.Now()
+	for _, a := range []struct {
+		post   *Pst
+		title  string
+		status Status
+	}{
+		{&f.published, "Published article", StatusPublished},
+		{&f.draft, "Draft article", StatusDraft},
+		{&f.hidden, "Hidden article", StatusPublished},
+		{&f.deleted, "Deleted article", StatusPublished},
+	} {
+		post := Pst{Title: a.title, Anons: "Anons", Full_Text: "Full *text*", UserId: f.author.ID, Status: a.status}
+		if a.status == StatusPublished {
+			post.PublishedAt = &now
+		}
+		if err := f.store.Articles.Create(&post); err != nil {
+			t.Fatalf("creating article %q: %v", a.title, err)
+		}
+		*a.post = post
+	}
+	if err := f.store.Articles.SetHidden(f.hidden.Id, true); err != nil {
+		t.Fatalf("hiding article: %v", err)
+	}
+	if err := f.store.Articles.Delete(f.deleted.Id); err != nil {
+		t.Fatalf("deleting article: %v", err)
+	}
+	return f
+}
+
+// request returns a request of the user, nil for a guest, with the route variables set as the router would
+func (f *fixture) request(method, target string, user *User, vars map[string]string) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	ctx := context.WithValue(r.Context(), StoreKey, f.store)
+	ctx = context.WithValue(ctx, RendererKey, f.renderer)
+	if user != nil {
+		ctx = context.WithValue(ctx, UserKey, user)
+	}
+	return mux.SetURLVars(r.WithContext(ctx), vars)
+}
+
+// userName names the user in the test output
+func userName(user *User) string {
+	if user == nil {
+		return "guest"
+	}
+	return user.Name
+}
+
+// articleTitles returns the titles of the articles in their order
+func articleTitles(posts []Pst) []string {
+	titles := []string{}
+	for _, post := range posts {
+		titles = append(titles, post.Title)
+	}
+	return titles
+}
+
+func TestMemoryArticleStoreVisibility(t *testing.T) {
+	f := newFixture(t)
+
+	tests := []struct {
+		name   string
+		filter ArticleFilter
+		want   []string
+	}{
+		{"guest", ArticleFilter{}, []string{"Published article"}},
+		{"other author", ArticleFilter{Viewer: f.other.ID}, []string{"Published article"}},
+		{"author", ArticleFilter{Viewer: f.author.ID}, []string{"Hidden article", "Draft article", "Published article"}},
+	}
+	for _, tt := range tests {
+		posts, err := f.store.Articles.List(tt.filter, 10, 0)
+		if err != nil {
+			t.Fatalf("%s: listing: %v", tt.name, err)
+		}
+		if got := articleTitles(posts); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: listed %q, want %q", tt.name, got, tt.want)
+		}
+		count, err := f.store.Articles.Count(tt.filter)
+		if err != nil || count != len(tt.want) {
+			t.Errorf("%s: counted %d (%v), want %d", tt.name, count, err, len(tt.want))
+		}
+	}
+
+	//Deleted articles are only listed for moderation
+	all, err := f.store.Articles.ListAll(10, 0)
+	if err != nil || len(all) != 4 {
+		t.Errorf("ListAll returned %d articles (%v), want 4", len(all), err)
+	}
+	if _, err := f.store.Articles.Get(f.deleted.Id); err != ErrNotFound {
+		t.Errorf("getting a deleted article: %v, want ErrNotFound", err)
+	}
+	if err := f.store.Articles.Restore(f.deleted.Id); err != nil {
+		t.Fatalf("restoring: %v", err)
+	}
+	if post, err := f.store.Articles.Get(f.deleted.Id); err != nil || post.AuthorName != "Author" {
+		t.Errorf("getting a restored article: %q by %q (%v)", post.Title, post.AuthorName, err)
+	}
+}
+
+func TestMemoryUserStore(t *testing.T) {
+	f := newFixture(t)
+
+	if err := f.store.Users.Create(&User{Name: "Copy", Email: "author@example.com"}); err != ErrUserExists {
+		t.Errorf("creating a user with a taken email: %v, want ErrUserExists", err)
+	}
+	user, err := f.store.Users.FindByEmail("editor@example.com")
+	if err != nil || user.ID != f.editor.ID || user.Role != RoleEditor {
+		t.Errorf("finding by email: user %d with role %q (%v), want %d", user.ID, user.Role, err, f.editor.ID)
+	}
+	if _, err := f.store.Users.Get(999); err != ErrNotFound {
+		t.Errorf("getting a missing user: %v, want ErrNotFound", err)
+	}
+}
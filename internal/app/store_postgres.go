@@ -0,0 +1,910 @@
+package app
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// NewPostgresStore returns a Store backed by the given PostgreSQL database
+func NewPostgresStore(db *sql.DB) *Store {
+	return &Store{
+		Articles:   &pgArticleStore{db: db},
+		Users:      &pgUserStore{db: db},
+		Tokens:     &pgTokenStore{db: db},
+		APITokens:  &pgAPITokenStore{db: db},
+		Identities: &pgIdentityStore{db: db},
+		Tags:       &pgTagStore{db: db},
+		Categories: &pgCategoryStore{db: db},
+		Comments:   &pgCommentStore{db: db},
+		Media:      &pgMediaStore{db: db},
+	}
+}
+
+// articleColumns are the columns of an article, its author and its category in the order expected by scanArticle
+// Articles created before authorship was tracked have no user and get empty author fields
+const articleColumns = `a.id, a.title, a.anons, a.full_text,
+	COALESCE(a.user_id, 0), COALESCE(u.name, ''), COALESCE(u.email, ''),
+	a.hidden_at IS NOT NULL, a.deleted_at IS NOT NULL, a.revision,
+	COALESCE(c.id, 0), COALESCE(c.name, ''), COALESCE(c.slug, ''), a.status, a.published_at,
+	a.updated_at, a.slug`
+
+// articleFrom joins the articles with their authors and categories
+const articleFrom = ` FROM articles a LEFT JOIN users u ON u.id = a.user_id LEFT JOIN categories c ON c.id = a.category_id`
+
+// articleSelect selects articles together with the name and email of their authors
+const articleSelect = "SELECT " + articleColumns + articleFrom
+
+// scanArticle scans a row selected with articleColumns into a Pst, the tags are loaded by attachTags
+// Columns selected after articleColumns are scanned into extra
+func scanArticle(row interface{ Scan(...interface{}) error }, post *Pst, extra ...interface{}) error {
+	var category Category
+	var publishedAt sql.NullTime
+	dest := []interface{}{&post.Id, &post.Title, &post.Anons, &post.Full_Text, &post.UserId, &post.AuthorName, &post.AuthorEmail,
+		&post.Hidden, &post.Deleted, &post.Revision, &category.ID, &category.Name, &category.Slug, &post.Status, &publishedAt,
+		&post.UpdatedAt, &post.Slug}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return err
+	}
+	if category.ID != 0 {
+		post.Category = &category
+	}
+	if publishedAt.Valid {
+		post.PublishedAt = &publishedAt.Time
+	}
+	return nil
+}
+
+// queryer is implemented by *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// attachTags loads the tags of the articles, the posts slice holds pointers so that search results can be filled in too
+func attachTags(db queryer, posts []*Pst) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	byID := map[int]*Pst{}
+	ids := make([]int64, len(posts))
+	for i, post := range posts {
+		post.Tags = []Tag{}
+		byID[post.Id] = post
+		ids[i] = int64(post.Id)
+	}
+
+	rows, err := db.Query(`SELECT at.article_id, t.name, t.slug FROM article_tags at JOIN tags t ON t.id = at.tag_id
+		WHERE at.article_id = ANY($1) ORDER BY lower(t.name)`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var tag Tag
+		if err := rows.Scan(&id, &tag.Name, &tag.Slug); err != nil {
+			return err
+		}
+		byID[id].Tags = append(byID[id].Tags, tag)
+	}
+	return rows.Err()
+}
+
+// setArticleTags replaces the tags of the article, creating the tags that do not exist yet
+func setArticleTags(tx *sql.Tx, articleID int, tags []Tag) error {
+	if _, err := tx.Exec("DELETE FROM article_tags WHERE article_id = $1", articleID); err != nil {
+		return err
+	}
+	for i := range tags {
+		tagID, err := claimTag(tx, &tags[i])
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO article_tags (article_id, tag_id) VALUES ($1, $2)", articleID, tagID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claimTag returns the ID of the tag with the name of the given tag, ignoring case, and sets its stored name and slug
+// A new name gets the first free candidate of its slug, so "C#" becomes "c-2" when "C++" has "c" already
+func claimTag(tx *sql.Tx, tag *Tag) (int, error) {
+	base := tag.Slug
+	for n := 1; ; n++ {
+		var id int
+		err := tx.QueryRow("SELECT id, name, slug FROM tags WHERE lower(name) = lower($1)", tag.Name).Scan(&id, &tag.Name, &tag.Slug)
+		if err != sql.ErrNoRows {
+			return id, err
+		}
+
+		//Creating the tag unless the candidate is taken or another transaction has just created the tag
+		err = tx.QueryRow("INSERT INTO tags (name, slug) VALUES ($1, $2) ON CONFLICT DO NOTHING RETURNING id", tag.Name, numberedSlug(base, n)).Scan(&id)
+		if err == nil {
+			tag.Slug = numberedSlug(base, n)
+			return id, nil
+		}
+		if err != sql.ErrNoRows {
+			return 0, err
+		}
+	}
+}
+
+// saveRevision records the title, anons and full text of the current revision of the article as saved by the user
+func saveRevision(tx *sql.Tx, post *Pst, userID int) error {
+	_, err := tx.Exec(
+		"INSERT INTO article_revisions (article_id, revision, title, anons, full_text, user_id) VALUES ($1, $2, $3, $4, $5, $6)",
+		post.Id, post.Revision, post.Title, post.Anons, post.Full_Text, nullID(userID),
+	)
+	return err
+}
+
+// claimSlug sets the slug of the article to the first free candidate for its title and records it for the article
+// The current slug is kept while it fits the title, slugs the article had before can be taken back
+func claimSlug(tx *sql.Tx, post *Pst, current string) error {
+	base := articleSlug(post.Title)
+	if keepsSlug(current, base) {
+		post.Slug = current
+		return nil
+	}
+	for n := 1; ; n++ {
+		//Recording the candidate unless another article has it, the owner of a taken slug is returned instead
+		var owner int
+		err := tx.QueryRow(
+			`INSERT INTO article_slugs (slug, article_id) VALUES ($1, $2)
+			ON CONFLICT (slug) DO UPDATE SET slug = EXCLUDED.slug RETURNING article_id`,
+			numberedSlug(base, n), post.Id,
+		).Scan(&owner)
+		if err != nil {
+			return err
+		}
+		if owner == post.Id {
+			post.Slug = numberedSlug(base, n)
+			_, err := tx.Exec("UPDATE articles SET slug = $1 WHERE id = $2", post.Slug, post.Id)
+			return err
+		}
+	}
+}
+
+// nullID converts the zero ID into a SQL NULL
+func nullID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+// categoryID returns the ID of the category of the article as a nullable column value
+func categoryID(post *Pst) sql.NullInt64 {
+	if post.Category == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(post.Category.ID), Valid: true}
+}
+
+// inTx runs fn in a transaction, which is committed when fn succeeds and rolled back otherwise
+func inTx(db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// checkAffected converts an UPDATE or DELETE result that touched no rows into ErrNotFound
+func checkAffected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// pgArticleStore implements ArticleStore on top of the "articles" table
+type pgArticleStore struct {
+	db *sql.DB
+}
+
+// articlePublic is the condition of the articles everybody can see
+const articlePublic = `a.status = 'published' AND a.hidden_at IS NULL AND a.deleted_at IS NULL`
+
+// articleFilterWhere selects the visible articles of the viewer $3 with the tag $1, the category $2 and the author $4,
+// Empty slugs and zero IDs do not filter
+const articleFilterWhere = ` WHERE (` + articlePublic + `
+	OR ($3::integer <> 0 AND a.user_id = $3::integer AND a.deleted_at IS NULL))
+	AND ($1::text = '' OR EXISTS (SELECT 1 FROM article_tags at JOIN tags t ON t.id = at.tag_id
+		WHERE at.article_id = a.id AND t.slug = $1::text))
+	AND ($2::text = '' OR c.slug = $2::text)
+	AND ($4::integer = 0 OR a.user_id = $4::integer)`
+
+func (s *pgArticleStore) List(filter ArticleFilter, limit, offset int) ([]Pst, error) {
+	//The ID grows with every new article, so ordering by it lists the newest first and keeps pages stable
+	return s.query(articleSelect+articleFilterWhere+` AND ($5::integer = 0 OR a.id < $5::integer)
+		ORDER BY a.id DESC LIMIT $6 OFFSET $7`, filter.Tag, filter.Category, filter.Viewer, filter.Author, filter.Before, limit, offset)
+}
+
+func (s *pgArticleStore) Count(filter ArticleFilter) (int, error) {
+	var count int
+	err := s.db.QueryRow("SELECT count(*)"+articleFrom+articleFilterWhere, filter.Tag, filter.Category, filter.Viewer, filter.Author).Scan(&count)
+	return count, err
+}
+
+func (s *pgArticleStore) ListAll(limit, offset int) ([]Pst, error) {
+	return s.query(articleSelect+" ORDER BY a.id DESC LIMIT $1 OFFSET $2", limit, offset)
+}
+
+// query runs a query selecting articles with articleSelect and scans every row
+func (s *pgArticleStore) query(query string, args ...interface{}) ([]Pst, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	posts := []Pst{}
+	for rows.Next() {
+		var post Pst
+		if err := scanArticle(rows, &post); err != nil {
+			return nil, err
+		}
+		posts = append(posts, post)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	refs := make([]*Pst, len(posts))
+	for i := range posts {
+		refs[i] = &posts[i]
+	}
+	return posts, attachTags(s.db, refs)
+}
+
+func (s *pgArticleStore) Get(id int) (Pst, error) {
+	return s.get(" WHERE a.id = $1 AND a.deleted_at IS NULL", id)
+}
+
+func (s *pgArticleStore) FindBySlug(slug string) (Pst, error) {
+	return s.get(" JOIN article_slugs sl ON sl.article_id = a.id WHERE sl.slug = $1 AND a.deleted_at IS NULL", slug)
+}
+
+// get returns the single article selected by the condition or ErrNotFound
+func (s *pgArticleStore) get(where string, arg interface{}) (Pst, error) {
+	var post Pst
+	err := scanArticle(s.db.QueryRow(articleSelect+where, arg), &post)
+	if err == sql.ErrNoRows {
+		return post, ErrNotFound
+	}
+	if err != nil {
+		return post, err
+	}
+	return post, attachTags(s.db, []*Pst{&post})
+}
+
+func (s *pgArticleStore) Create(post *Pst) error {
+	return inTx(s.db, func(tx *sql.Tx) error {
+		//The slug is claimed once the article has its ID
+		err := tx.QueryRow(
+			`INSERT INTO articles (title, anons, full_text, user_id, category_id, status, published_at, slug)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, '') RETURNING id, revision, updated_at`,
+			post.Title, post.Anons, post.Full_Text, post.UserId, categoryID(post), post.Status, post.PublishedAt,
+		).Scan(&post.Id, &post.Revision, &post.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		if err := claimSlug(tx, post, ""); err != nil {
+			return err
+		}
+		if err := saveRevision(tx, post, post.UserId); err != nil {
+			return err
+		}
+		return setArticleTags(tx, post.Id, post.Tags)
+	})
+}
+
+func (s *pgArticleStore) Update(post *Pst, editorID int) error {
+	return inTx(s.db, func(tx *sql.Tx) error {
+		var current string
+		err := tx.QueryRow(
+			`UPDATE articles SET title = $1, anons = $2, full_text = $3, category_id = $4, status = $5, published_at = $6,
+			revision = revision + 1, updated_at = now() WHERE id = $7 AND deleted_at IS NULL RETURNING revision, updated_at, slug`,
+			post.Title, post.Anons, post.Full_Text, categoryID(post), post.Status, post.PublishedAt, post.Id,
+		).Scan(&post.Revision, &post.UpdatedAt, &current)
+		if err == sql.ErrNoRows {
+			return ErrNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := claimSlug(tx, post, current); err != nil {
+			return err
+		}
+		if err := saveRevision(tx, post, editorID); err != nil {
+			return err
+		}
+		return setArticleTags(tx, post.Id, post.Tags)
+	})
+}
+
+// revisionSelect selects the revisions of articles with the names of their editors in the order expected by scanRevision
+const revisionSelect = `SELECT r.article_id, r.revision, r.title, r.anons, r.full_text, COALESCE(r.user_id, 0), COALESCE(u.name, ''),
+	r.created_at FROM article_revisions r LEFT JOIN users u ON u.id = r.user_id`
+
+// scanRevision scans a row selected with revisionSelect into an ArticleRevision
+func scanRevision(row interface{ Scan(...interface{}) error }, rev *ArticleRevision) error {
+	err := row.Scan(&rev.ArticleID, &rev.Revision, &rev.Title, &rev.Anons, &rev.Full_Text, &rev.UserID, &rev.AuthorName, &rev.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *pgArticleStore) Revisions(id int) ([]ArticleRevision, error) {
+	rows, err := s.db.Query(revisionSelect+" WHERE r.article_id = $1 ORDER BY r.revision DESC", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []ArticleRevision{}
+	for rows.Next() {
+		var rev ArticleRevision
+		if err := scanRevision(rows, &rev); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *pgArticleStore) GetRevision(id, revision int) (ArticleRevision, error) {
+	var rev ArticleRevision
+	err := scanRevision(s.db.QueryRow(revisionSelect+" WHERE r.article_id = $1 AND r.revision = $2", id, revision), &rev)
+	return rev, err
+}
+
+func (s *pgArticleStore) Delete(id int) error {
+	return checkAffected(s.db.Exec("UPDATE articles SET deleted_at = now(), updated_at = now() WHERE id = $1 AND deleted_at IS NULL", id))
+}
+
+func (s *pgArticleStore) SetHidden(id int, hidden bool) error {
+	return checkAffected(s.db.Exec(
+		"UPDATE articles SET hidden_at = CASE WHEN $1 THEN COALESCE(hidden_at, now()) END, updated_at = now() WHERE id = $2 AND deleted_at IS NULL",
+		hidden, id,
+	))
+}
+
+func (s *pgArticleStore) Restore(id int) error {
+	return checkAffected(s.db.Exec("UPDATE articles SET hidden_at = NULL, deleted_at = NULL, updated_at = now() WHERE id = $1", id))
+}
+
+func (s *pgArticleStore) Stats() (ArticleStats, error) {
+	var stats ArticleStats
+	err := s.db.QueryRow(`SELECT count(*),
+		count(*) FILTER (WHERE hidden_at IS NOT NULL AND deleted_at IS NULL),
+		count(*) FILTER (WHERE deleted_at IS NOT NULL)
+		FROM articles`).Scan(&stats.Total, &stats.Hidden, &stats.Deleted)
+	return stats, err
+}
+
+func (s *pgArticleStore) LastChange() (time.Time, error) {
+	var last sql.NullTime
+	err := s.db.QueryRow("SELECT max(updated_at) FROM articles").Scan(&last)
+	return last.Time, err
+}
+
+func (s *pgArticleStore) PublishDue(now time.Time) (int, error) {
+	result, err := s.db.Exec(
+		"UPDATE articles SET status = 'published', updated_at = now() WHERE status = 'scheduled' AND published_at <= $1 AND deleted_at IS NULL", now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	published, err := result.RowsAffected()
+	return int(published), err
+}
+
+// searchQuery ranks the visible articles matching a web search query, $1, and highlights the matches
+// The total number of matches is selected with every row, so a page of results needs only one query
+const searchQuery = "SELECT " + articleColumns + `,
+	ts_rank(a.search, q) AS rank, ts_headline('english', a.anons || ' ' || a.full_text, q, $2), count(*) OVER ()` + articleFrom + `,
+	websearch_to_tsquery('english', $1) q
+	WHERE a.search @@ q AND ` + articlePublic + `
+	ORDER BY rank DESC, a.id DESC LIMIT $3 OFFSET $4`
+
+func (s *pgArticleStore) Search(query string, limit, offset int) ([]SearchResult, int, error) {
+	options := "StartSel=" + snippetStart + ", StopSel=" + snippetStop + `, MaxWords=35, MinWords=15, MaxFragments=2, FragmentDelimiter=" … "`
+	rows, err := s.db.Query(searchQuery, query, options, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := []SearchResult{}
+	total := 0
+	for rows.Next() {
+		var result SearchResult
+		var snippet string
+		if err := scanArticle(rows, &result.Pst, &result.Rank, &snippet, &total); err != nil {
+			return nil, 0, err
+		}
+		result.Snippet = highlight(snippet)
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	rows.Close()
+
+	refs := make([]*Pst, len(results))
+	for i := range results {
+		refs[i] = &results[i].Pst
+	}
+	return results, total, attachTags(s.db, refs)
+}
+
+// pgUserStore implements UserStore on top of the "users" table
+type pgUserStore struct {
+	db *sql.DB
+}
+
+// userSelect selects every column of the users table in the order expected by scanUser
+const userSelect = `SELECT id, name, email, role, COALESCE(password_hash, ''), email_verified_at IS NOT NULL,
+	COALESCE(avatar_url, ''), COALESCE(locale, ''), banned_at IS NOT NULL FROM users`
+
+// scanUser scans a row selected with userSelect into a User
+func scanUser(row interface{ Scan(...interface{}) error }, user *User) error {
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.Role, &user.PasswordHash, &user.EmailVerified,
+		&user.AvatarURL, &user.Locale, &user.Banned)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+// nullString converts an empty string into a SQL NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func (s *pgUserStore) Create(user *User) error {
+	err := s.db.QueryRow(
+		"INSERT INTO users (name, email, password_hash, avatar_url, locale) VALUES ($1, $2, $3, $4, $5) RETURNING id, role",
+		user.Name, user.Email, nullString(user.PasswordHash), nullString(user.AvatarURL), nullString(user.Locale),
+	).Scan(&user.ID, &user.Role)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // PostgreSQL unique violation
+		return ErrUserExists
+	}
+	return err
+}
+
+func (s *pgUserStore) Get(id int) (User, error) {
+	var user User
+	err := scanUser(s.db.QueryRow(userSelect+" WHERE id = $1", id), &user)
+	return user, err
+}
+
+func (s *pgUserStore) FindByEmail(email string) (User, error) {
+	var user User
+	err := scanUser(s.db.QueryRow(userSelect+" WHERE email = $1", email), &user)
+	return user, err
+}
+
+func (s *pgUserStore) SetPassword(id int, hash string) error {
+	return checkAffected(s.db.Exec("UPDATE users SET password_hash = $1 WHERE id = $2", hash, id))
+}
+
+func (s *pgUserStore) MarkEmailVerified(id int) error {
+	return checkAffected(s.db.Exec("UPDATE users SET email_verified_at = COALESCE(email_verified_at, now()) WHERE id = $1", id))
+}
+
+func (s *pgUserStore) UpdateProfile(id int, avatarURL, locale string) error {
+	return checkAffected(s.db.Exec(
+		"UPDATE users SET avatar_url = COALESCE($1, avatar_url), locale = COALESCE($2, locale) WHERE id = $3",
+		nullString(avatarURL), nullString(locale), id,
+	))
+}
+
+func (s *pgUserStore) SetRole(id int, role Role) error {
+	return checkAffected(s.db.Exec("UPDATE users SET role = $1 WHERE id = $2", role, id))
+}
+
+func (s *pgUserStore) List(limit, offset int) ([]User, error) {
+	rows, err := s.db.Query(userSelect+" ORDER BY id LIMIT $1 OFFSET $2", limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		var user User
+		if err := scanUser(rows, &user); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+func (s *pgUserStore) SetBanned(id int, banned bool) error {
+	return checkAffected(s.db.Exec(
+		"UPDATE users SET banned_at = CASE WHEN $1 THEN COALESCE(banned_at, now()) END WHERE id = $2", banned, id))
+}
+
+func (s *pgUserStore) Stats() (UserStats, error) {
+	stats := UserStats{ByRole: map[Role]int{}}
+	rows, err := s.db.Query("SELECT role, count(*), count(*) FILTER (WHERE banned_at IS NOT NULL) FROM users GROUP BY role")
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var role Role
+		var total, banned int
+		if err := rows.Scan(&role, &total, &banned); err != nil {
+			return stats, err
+		}
+		stats.ByRole[role] = total
+		stats.Total += total
+		stats.Banned += banned
+	}
+	return stats, rows.Err()
+}
+
+// pgTokenStore implements TokenStore on top of the "user_tokens" table
+type pgTokenStore struct {
+	db *sql.DB
+}
+
+func (s *pgTokenStore) Create(userID int, purpose, hash string, expires time.Time) error {
+	_, err := s.db.Exec("INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at) VALUES ($1, $2, $3, $4)",
+		userID, purpose, hash, expires)
+	return err
+}
+
+func (s *pgTokenStore) Consume(purpose, hash string) (int, error) {
+	var userID int
+	err := s.db.QueryRow(
+		`UPDATE user_tokens SET used_at = now()
+		WHERE purpose = $1 AND token_hash = $2 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id`, purpose, hash,
+	).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	}
+	return userID, err
+}
+
+// pgAPITokenStore implements APITokenStore on top of the "api_tokens" table
+type pgAPITokenStore struct {
+	db *sql.DB
+}
+
+// apiTokenColumns are the columns of the api_tokens table in the order expected by scanAPIToken
+const apiTokenColumns = "id, user_id, name, token_hash, created_at, last_used_at"
+
+// scanAPIToken scans a row selected with apiTokenColumns into an APIToken
+func scanAPIToken(row interface{ Scan(...interface{}) error }, token *APIToken) error {
+	var lastUsedAt sql.NullTime
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Hash, &token.CreatedAt, &lastUsedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	return err
+}
+
+func (s *pgAPITokenStore) Create(token *APIToken) error {
+	return s.db.QueryRow("INSERT INTO api_tokens (user_id, name, token_hash) VALUES ($1, $2, $3) RETURNING id, created_at",
+		token.UserID, token.Name, token.Hash).Scan(&token.ID, &token.CreatedAt)
+}
+
+func (s *pgAPITokenStore) Use(hash string) (APIToken, error) {
+	var token APIToken
+	err := scanAPIToken(s.db.QueryRow(
+		"UPDATE api_tokens SET last_used_at = now() WHERE token_hash = $1 RETURNING "+apiTokenColumns, hash,
+	), &token)
+	return token, err
+}
+
+func (s *pgAPITokenStore) ListByUser(userID int) ([]APIToken, error) {
+	rows, err := s.db.Query("SELECT "+apiTokenColumns+" FROM api_tokens WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []APIToken{}
+	for rows.Next() {
+		var token APIToken
+		if err := scanAPIToken(rows, &token); err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (s *pgAPITokenStore) Revoke(userID, id int) error {
+	return checkAffected(s.db.Exec("DELETE FROM api_tokens WHERE id = $1 AND user_id = $2", id, userID))
+}
+
+// pgIdentityStore implements IdentityStore on top of the "user_identities" table
+type pgIdentityStore struct {
+	db *sql.DB
+}
+
+// identitySelect selects every column of the user_identities table in the order expected by scanIdentity
+const identitySelect = "SELECT id, user_id, provider, provider_user_id, email, name, created_at FROM user_identities"
+
+// scanIdentity scans a row selected with identitySelect into an Identity
+func scanIdentity(row interface{ Scan(...interface{}) error }, identity *Identity) error {
+	err := row.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.ProviderUserID,
+		&identity.Email, &identity.Name, &identity.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *pgIdentityStore) Find(provider, providerUserID string) (Identity, error) {
+	var identity Identity
+	err := scanIdentity(s.db.QueryRow(identitySelect+" WHERE provider = $1 AND provider_user_id = $2",
+		provider, providerUserID), &identity)
+	return identity, err
+}
+
+func (s *pgIdentityStore) ListByUser(userID int) ([]Identity, error) {
+	rows, err := s.db.Query(identitySelect+" WHERE user_id = $1 ORDER BY created_at, id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	identities := []Identity{}
+	for rows.Next() {
+		var identity Identity
+		if err := scanIdentity(rows, &identity); err != nil {
+			return nil, err
+		}
+		identities = append(identities, identity)
+	}
+	return identities, rows.Err()
+}
+
+func (s *pgIdentityStore) Link(identity *Identity) error {
+	//Inserting the identity, an existing row is returned unchanged so its owner can be checked
+	var owner int
+	err := s.db.QueryRow(
+		`INSERT INTO user_identities (user_id, provider, provider_user_id, email, name) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (provider, provider_user_id) DO UPDATE SET provider = EXCLUDED.provider
+		RETURNING id, user_id, created_at`,
+		identity.UserID, identity.Provider, identity.ProviderUserID, identity.Email, identity.Name,
+	).Scan(&identity.ID, &owner, &identity.CreatedAt)
+	if err != nil {
+		return err
+	}
+	if owner != identity.UserID {
+		return ErrIdentityLinked
+	}
+	return nil
+}
+
+func (s *pgIdentityStore) Unlink(userID, id int) error {
+	return checkAffected(s.db.Exec("DELETE FROM user_identities WHERE id = $1 AND user_id = $2", id, userID))
+}
+
+// pgTagStore implements TagStore on top of the "tags" and "article_tags" tables
+type pgTagStore struct {
+	db *sql.DB
+}
+
+func (s *pgTagStore) FindBySlug(slug string) (Tag, error) {
+	var tag Tag
+	err := s.db.QueryRow("SELECT name, slug FROM tags WHERE slug = $1", slug).Scan(&tag.Name, &tag.Slug)
+	if err == sql.ErrNoRows {
+		return tag, ErrNotFound
+	}
+	return tag, err
+}
+
+func (s *pgTagStore) Cloud(limit int) ([]Tag, error) {
+	rows, err := s.db.Query(`SELECT t.name, t.slug, count(*) FROM tags t
+		JOIN article_tags at ON at.tag_id = t.id
+		JOIN articles a ON a.id = at.article_id AND `+articlePublic+`
+		GROUP BY t.id ORDER BY count(*) DESC, t.name LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.Name, &tag.Slug, &tag.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// pgCategoryStore implements CategoryStore on top of the "categories" table
+type pgCategoryStore struct {
+	db *sql.DB
+}
+
+func (s *pgCategoryStore) List() ([]Category, error) {
+	rows, err := s.db.Query("SELECT id, name, slug FROM categories ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []Category{}
+	for rows.Next() {
+		var category Category
+		if err := rows.Scan(&category.ID, &category.Name, &category.Slug); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+func (s *pgCategoryStore) FindBySlug(slug string) (Category, error) {
+	var category Category
+	err := s.db.QueryRow("SELECT id, name, slug FROM categories WHERE slug = $1", slug).Scan(&category.ID, &category.Name, &category.Slug)
+	if err == sql.ErrNoRows {
+		return category, ErrNotFound
+	}
+	return category, err
+}
+
+func (s *pgCategoryStore) Create(category *Category) error {
+	err := s.db.QueryRow("INSERT INTO categories (name, slug) VALUES ($1, $2) RETURNING id", category.Name, category.Slug).Scan(&category.ID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" { // PostgreSQL unique violation
+		return ErrCategoryExists
+	}
+	return err
+}
+
+// pgCommentStore implements CommentStore on top of the "comments" table
+type pgCommentStore struct {
+	db *sql.DB
+}
+
+// commentSelect selects comments together with the names of their authors in the order expected by scanComment
+const commentSelect = `SELECT c.id, c.article_id, COALESCE(c.parent_id, 0), c.user_id, u.name, c.body, c.created_at, c.edited_at,
+	c.hidden_at IS NOT NULL, c.deleted_at IS NOT NULL FROM comments c JOIN users u ON u.id = c.user_id`
+
+// scanComment scans a row selected with commentSelect into a Comment
+func scanComment(row interface{ Scan(...interface{}) error }, comment *Comment) error {
+	var editedAt sql.NullTime
+	err := row.Scan(&comment.ID, &comment.ArticleID, &comment.ParentID, &comment.UserID, &comment.AuthorName, &comment.Body,
+		&comment.CreatedAt, &editedAt, &comment.Hidden, &comment.Deleted)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if editedAt.Valid {
+		comment.EditedAt = &editedAt.Time
+	}
+	return nil
+}
+
+func (s *pgCommentStore) ListByArticle(articleID int) ([]Comment, error) {
+	rows, err := s.db.Query(commentSelect+" WHERE c.article_id = $1 ORDER BY c.id", articleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []Comment{}
+	for rows.Next() {
+		var comment Comment
+		if err := scanComment(rows, &comment); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+func (s *pgCommentStore) Get(id int) (Comment, error) {
+	var comment Comment
+	err := scanComment(s.db.QueryRow(commentSelect+" WHERE c.id = $1", id), &comment)
+	return comment, err
+}
+
+func (s *pgCommentStore) Create(comment *Comment) error {
+	return s.db.QueryRow(
+		"INSERT INTO comments (article_id, parent_id, user_id, body) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		comment.ArticleID, nullID(comment.ParentID), comment.UserID, comment.Body,
+	).Scan(&comment.ID, &comment.CreatedAt)
+}
+
+func (s *pgCommentStore) Update(id int, body string) error {
+	return checkAffected(s.db.Exec("UPDATE comments SET body = $1, edited_at = now() WHERE id = $2 AND deleted_at IS NULL", body, id))
+}
+
+func (s *pgCommentStore) Delete(id int) error {
+	return checkAffected(s.db.Exec("UPDATE comments SET deleted_at = now() WHERE id = $1 AND deleted_at IS NULL", id))
+}
+
+func (s *pgCommentStore) SetHidden(id int, hidden bool) error {
+	return checkAffected(s.db.Exec(
+		"UPDATE comments SET hidden_at = CASE WHEN $1 THEN COALESCE(hidden_at, now()) END WHERE id = $2 AND deleted_at IS NULL",
+		hidden, id,
+	))
+}
+
+// pgMediaStore implements MediaStore on top of the "media" table
+type pgMediaStore struct {
+	db *sql.DB
+}
+
+// mediaSelect selects uploaded files in the order expected by scanMedia
+const mediaSelect = `SELECT id, article_id, COALESCE(user_id, 0), filename, content_type, size, storage_key,
+	COALESCE(thumbnail_key, ''), created_at FROM media`
+
+// scanMedia scans a row selected with mediaSelect into a Media
+func scanMedia(row interface{ Scan(...interface{}) error }, media *Media) error {
+	err := row.Scan(&media.ID, &media.ArticleID, &media.UserID, &media.Filename, &media.ContentType, &media.Size,
+		&media.Key, &media.ThumbnailKey, &media.CreatedAt)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (s *pgMediaStore) ListByArticle(articleID int) ([]Media, error) {
+	rows, err := s.db.Query(mediaSelect+" WHERE article_id = $1 ORDER BY id", articleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []Media{}
+	for rows.Next() {
+		var media Media
+		if err := scanMedia(rows, &media); err != nil {
+			return nil, err
+		}
+		files = append(files, media)
+	}
+	return files, rows.Err()
+}
+
+func (s *pgMediaStore) Get(id int) (Media, error) {
+	var media Media
+	err := scanMedia(s.db.QueryRow(mediaSelect+" WHERE id = $1", id), &media)
+	return media, err
+}
+
+func (s *pgMediaStore) Create(media *Media) error {
+	return s.db.QueryRow(
+		`INSERT INTO media (article_id, user_id, filename, content_type, size, storage_key, thumbnail_key)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, '')) RETURNING id, created_at`,
+		media.ArticleID, nullID(media.UserID), media.Filename, media.ContentType, media.Size, media.Key, media.ThumbnailKey,
+	).Scan(&media.ID, &media.CreatedAt)
+}
+
+func (s *pgMediaStore) Delete(id int) error {
+	return checkAffected(s.db.Exec("DELETE FROM media WHERE id = $1", id))
+}
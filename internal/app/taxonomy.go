@@ -0,0 +1,346 @@
+package app
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxTags       = 10 //Most tags accepted on one article
+	maxTagLength  = 50 //Longest tag or category name accepted
+	tagCloudLimit = 30 //Number of tags shown in the tag cloud
+)
+
+// Tag is a keyword attached to articles, tags are identified by their slug
+type Tag struct {
+	Name  string `json:"name"`
+	Slug  string `json:"slug"`
+	Count int    `json:"count,omitempty"` //Number of visible articles with the tag, only set by TagStore.Cloud
+}
+
+// Category groups articles by topic, every article is filed under at most one category
+type Category struct {
+	ID   int    `json:"-"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// cloudTag is a tag of the tag cloud with its font size step from 1 to 5
+type cloudTag struct {
+	Tag
+	Size int
+}
+
+// postList is the data of the article listing pages
+type postList struct {
+	Heading    string     //Heading of filtered listings, empty on the main listing
+	Posts      []Pst      //Articles of the current page
+	Pager      pager      //Links to the neighbouring pages and the other page sizes
+	Sizes      []int      //Page sizes offered by the listing
+	Cloud      []cloudTag //Most used tags ordered by name
+	Categories []Category //Every category, linked from the listing
+	Feed       string     //Path of the feeds of the listing without the format extension
+}
+
+// slugify returns the URL slug of a name: lower case letters and digits separated by single dashes
+func slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(name) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// parseTags splits a comma separated list of tag names into tags, ordered by name
+// Names differing only in case are the same tag, names without letters or digits are dropped.
+// The slug is the base of the slug of a new tag, the store numbers it when another tag has it, e.g. for "C++" and "C#".
+// It returns false when there are too many tags or a name is too long
+func parseTags(input string) ([]Tag, bool) {
+	tags := []Tag{}
+	seen := map[string]bool{}
+	for _, name := range strings.Split(input, ",") {
+		name = strings.Join(strings.Fields(name), " ")
+		slug := slugify(name)
+		if slug == "" || seen[strings.ToLower(name)] {
+			continue
+		}
+		if len([]rune(name)) > maxTagLength {
+			return nil, false
+		}
+		seen[strings.ToLower(name)] = true
+		tags = append(tags, Tag{Name: name, Slug: slug})
+	}
+	sortTags(tags)
+	return tags, len(tags) <= maxTags
+}
+
+// sortTags orders tags by name, ignoring case
+func sortTags(tags []Tag) {
+	sort.Slice(tags, func(i, j int) bool { return strings.ToLower(tags[i].Name) < strings.ToLower(tags[j].Name) })
+}
+
+// tagNames joins the names of the tags for the tag input of the article forms
+func tagNames(tags []Tag) string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// resolveCategory looks the category up by its slug, an empty slug means no category
+func resolveCategory(store *Store, slug string) (*Category, error) {
+	if slug == "" {
+		return nil, nil
+	}
+	category, err := store.Categories.FindBySlug(slug)
+	if err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// applyTaxonomy sets the category and the tags of the article from the submitted category slug and tag names
+// It returns a message for the client when they are rejected
+func applyTaxonomy(store *Store, post *Pst, categorySlug, tagInput string) (string, error) {
+	tags, ok := parseTags(tagInput)
+	if !ok {
+		return "Please use up to 10 tags of up to 50 characters each", nil
+	}
+	category, err := resolveCategory(store, categorySlug)
+	if err == ErrNotFound {
+		return "Unknown category", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	post.Category, post.Tags = category, tags
+	return "", nil
+}
+
+// articleForm is the data of the create and edit pages
+type articleForm struct {
+	Pst
+	TagInput   string     //Tag names of the article joined for the tag input
+	Categories []Category //Categories offered in the category select
+	Statuses   []Status   //Statuses the article can move to, starting with its current status
+	PublishAt  string     //Publication time of scheduled articles for the datetime-local input
+	Media      []Media    //Files attached to the article, the edit form lists them
+}
+
+// newArticleForm loads the categories offered by the article forms and the files of the article,
+// New articles start out as drafts without files
+func newArticleForm(store *Store, post Pst) (articleForm, error) {
+	if post.Status == "" {
+		post.Status = StatusDraft
+	}
+	form := articleForm{Pst: post, TagInput: tagNames(post.Tags), Statuses: nextStatuses(post.Status)}
+	if post.Status == StatusScheduled && post.PublishedAt != nil {
+		form.PublishAt = post.PublishedAt.In(time.Local).Format(publishTimeLayout)
+	}
+	var err error
+	if form.Categories, err = store.Categories.List(); err != nil || post.Id == 0 {
+		return form, err
+	}
+	form.Media, err = store.Media.ListByArticle(post.Id)
+	return form, err
+}
+
+// tagCloud loads the most used tags and scales them to font size steps, ordered by name
+func tagCloud(store *Store) ([]cloudTag, error) {
+	tags, err := store.Tags.Cloud(tagCloudLimit)
+	if err != nil {
+		return nil, err
+	}
+	max := 1
+	for _, tag := range tags {
+		if tag.Count > max {
+			max = tag.Count
+		}
+	}
+	sortTags(tags)
+	cloud := make([]cloudTag, len(tags))
+	for i, tag := range tags {
+		cloud[i] = cloudTag{Tag: tag, Size: 1}
+		if max > 1 {
+			cloud[i].Size += 4 * (tag.Count - 1) / (max - 1)
+		}
+	}
+	return cloud, nil
+}
+
+// renderPostList renders an article listing page together with the tag cloud and the categories
+func renderPostList(w http.ResponseWriter, r *http.Request, store *Store, list postList) {
+	cloud, err := tagCloud(store)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading the tag cloud: %v", err)
+		return
+	}
+	categories, err := store.Categories.List()
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading categories: %v", err)
+		return
+	}
+	list.Cloud, list.Categories = cloud, categories
+	renderPage(w, r, "post", list)
+}
+
+// listFiltered is the shared part of the article listing pages, it renders a page of the filtered articles, newest first
+// Signed-in authors see their own unpublished articles as well
+// Pages past the last one are not found, the path is the one of the listing used by the pager links
+func listFiltered(w http.ResponseWriter, r *http.Request, store *Store, path string, filter ArticleFilter, heading string) {
+	//Validating the page and the page size
+	page, size, ok := listPage(r)
+	if !ok {
+		http.Error(w, "Invalid page or per_page parameter", http.StatusBadRequest)
+		return
+	}
+
+	//Listing the drafts, scheduled and archived articles of the signed-in author among the published ones
+	if user := CurrentUser(r); user != nil {
+		filter.Viewer = user.ID
+	}
+
+	//Counting the articles for the number of pages
+	total, err := store.Articles.Count(filter)
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error counting articles: %v", err)
+		return
+	}
+	list := postList{Heading: heading, Pager: newPager(path, page, total, size), Sizes: pageSizes, Feed: feedPath(path)}
+	if page > list.Pager.Pages {
+		http.Error(w, "Page not found", http.StatusNotFound)
+		return
+	}
+	if size != pageSizes[0] {
+		list.Pager.Query.Set("per_page", strconv.Itoa(size))
+	}
+
+	//Querying the store for the articles of the page
+	list.Posts, err = store.Articles.List(filter, size, list.Pager.Offset())
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error executing database query: %v", err)
+		return
+	}
+	renderPostList(w, r, store, list)
+}
+
+// loadTag loads the tag named by the slug in the URL
+// It writes the error response itself and reports whether the handler should continue
+func loadTag(w http.ResponseWriter, r *http.Request, store *Store) (Tag, bool) {
+	tag, err := store.Tags.FindBySlug(mux.Vars(r)["slug"])
+	if err == ErrNotFound {
+		http.Error(w, "Tag not found", http.StatusNotFound)
+		return tag, false
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading tag: %v", err)
+		return tag, false
+	}
+	return tag, true
+}
+
+// loadCategory loads the category named by the slug in the URL
+// It writes the error response itself and reports whether the handler should continue
+func loadCategory(w http.ResponseWriter, r *http.Request, store *Store) (Category, bool) {
+	category, err := store.Categories.FindBySlug(mux.Vars(r)["slug"])
+	if err == ErrNotFound {
+		http.Error(w, "Category not found", http.StatusNotFound)
+		return category, false
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading category: %v", err)
+		return category, false
+	}
+	return category, true
+}
+
+// loadAuthor loads the user whose ID is given in the URL
+// It writes the error response itself and reports whether the handler should continue
+func loadAuthor(w http.ResponseWriter, r *http.Request, store *Store) (User, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		http.Error(w, "Author not found", http.StatusNotFound)
+		return User{}, false
+	}
+	author, err := store.Users.Get(id)
+	if err == ErrNotFound {
+		http.Error(w, "Author not found", http.StatusNotFound)
+		return author, false
+	}
+	if err != nil {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error loading author: %v", err)
+		return author, false
+	}
+	return author, true
+}
+
+// TagPage is an HTTP handler function listing the articles with the tag named in the URL
+func TagPage(w http.ResponseWriter, r *http.Request, store *Store) {
+	tag, ok := loadTag(w, r, store)
+	if !ok {
+		return
+	}
+	listFiltered(w, r, store, "/tag/"+tag.Slug, ArticleFilter{Tag: tag.Slug}, "Articles tagged “"+tag.Name+"”")
+}
+
+// CategoryPage is an HTTP handler function listing the articles of the category named in the URL
+func CategoryPage(w http.ResponseWriter, r *http.Request, store *Store) {
+	category, ok := loadCategory(w, r, store)
+	if !ok {
+		return
+	}
+	listFiltered(w, r, store, "/category/"+category.Slug, ArticleFilter{Category: category.Slug}, category.Name)
+}
+
+// AuthorPage is an HTTP handler function listing the articles of the user whose ID is given in the URL
+func AuthorPage(w http.ResponseWriter, r *http.Request, store *Store) {
+	author, ok := loadAuthor(w, r, store)
+	if !ok {
+		return
+	}
+	listFiltered(w, r, store, "/author/"+strconv.Itoa(author.ID), ArticleFilter{Author: author.ID}, "Articles by "+author.Name)
+}
+
+// AdminCreateCategory is an HTTP handler function adding a category from the admin overview
+func AdminCreateCategory(w http.ResponseWriter, r *http.Request, store *Store) {
+	name := strings.Join(strings.Fields(r.FormValue("name")), " ")
+	category := Category{Name: name, Slug: slugify(name)}
+	if category.Slug == "" || len([]rune(name)) > maxTagLength {
+		http.Error(w, "Please provide a category name of up to 50 characters", http.StatusBadRequest)
+		return
+	}
+	if err := store.Categories.Create(&category); err != nil {
+		if err == ErrCategoryExists {
+			http.Error(w, "A category with this name already exists", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		log.Printf("Error creating category: %v", err)
+		return
+	}
+	http.Redirect(w, r, "/admin", http.StatusSeeOther)
+}
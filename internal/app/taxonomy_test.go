@@ -0,0 +1,148 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []Tag
+		ok    bool
+	}{
+		{"", []Tag{}, true},
+		{"Go, web ,  go", []Tag{{Name: "Go", Slug: "go"}, {Name: "web", Slug: "web"}}, true},
+		{"Web  Development, ++, C++", []Tag{{Name: "C++", Slug: "c"}, {Name: "Web Development", Slug: "web-development"}}, true},
+		{strings.Repeat("a", maxTagLength+1), nil, false},
+		{"a,b,c,d,e,f,g,h,i,j,k", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseTags(tt.input)
+		if ok != tt.ok || (ok && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("parseTags(%q) = %+v, %v, want %+v, %v", tt.input, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// tagArticle creates a published article of the author with the tags and the category
+func (f *fixture) tagArticle(t *testing.T, title, tagInput string, category *Category) Pst {
+	t.Helper()
+	tags, ok := parseTags(tagInput)
+	if !ok {
+		t.Fatalf("parsing tags %q", tagInput)
+	}
+	now := time.Now()
+	post := Pst{Title: title, Anons: "Anons", Full_Text: "Text", UserId: f.author.ID, Status: StatusPublished, PublishedAt: &now, Tags: tags, Category: category}
+	if err := f.store.Articles.Create(&post); err != nil {
+		t.Fatalf("creating article %q: %v", title, err)
+	}
+	return post
+}
+
+func TestTagSlugs(t *testing.T) {
+	f := newFixture(t)
+
+	//Tags with the same slug get numbered slugs, names differing only in case share the tag
+	first := f.tagArticle(t, "C++ article", "C++", nil)
+	second := f.tagArticle(t, "C# article", "C#, c++", nil)
+	if first.Tags[0].Slug != "c" {
+		t.Errorf("slug of C++ %q, want %q", first.Tags[0].Slug, "c")
+	}
+	want := []Tag{{Name: "C#", Slug: "c-2"}, {Name: "C++", Slug: "c"}}
+	if !reflect.DeepEqual(second.Tags, want) {
+		t.Errorf("tags of the second article %+v, want %+v", second.Tags, want)
+	}
+
+	for slug, name := range map[string]string{"c": "C++", "c-2": "C#"} {
+		tag, err := f.store.Tags.FindBySlug(slug)
+		if err != nil || tag.Name != name {
+			t.Errorf("tag %q is %+v (%v), want %q", slug, tag, err, name)
+		}
+	}
+}
+
+func TestTaxonomyPages(t *testing.T) {
+	f := newFixture(t)
+	category := Category{Name: "Programming", Slug: "programming"}
+	if err := f.store.Categories.Create(&category); err != nil {
+		t.Fatalf("creating category: %v", err)
+	}
+	f.tagArticle(t, "Native article", "C++", &category)
+	f.tagArticle(t, "Managed article", "C#", nil)
+
+	//The listings show the articles of the tag or the category only
+	tests := []struct {
+		name    string
+		handler func(http.ResponseWriter, *http.Request, *Store)
+		slug    string
+		want    int
+		title   string
+	}{
+		{"tag", TagPage, "c", http.StatusOK, "Native article"},
+		{"numbered tag", TagPage, "c-2", http.StatusOK, "Managed article"},
+		{"unknown tag", TagPage, "c-3", http.StatusNotFound, ""},
+		{"category", CategoryPage, "programming", http.StatusOK, "Native article"},
+		{"unknown category", CategoryPage, "cooking", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tt.handler(w, f.request("GET", "/"+tt.slug, nil, map[string]string{"slug": tt.slug}), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.want)
+			continue
+		}
+		if tt.title == "" {
+			continue
+		}
+		body := w.Body.String()
+		if !strings.Contains(body, tt.title) || strings.Contains(body, "Published article") {
+			t.Errorf("%s: listing misses %q or shows other articles", tt.name, tt.title)
+		}
+	}
+
+	//The API filters by the slugs as well
+	for query, want := range map[string]string{"?tag=c-2": "Managed article", "?category=programming": "Native article"} {
+		w := httptest.NewRecorder()
+		APIListArticles(w, f.request("GET", "/api/v1/articles"+query, nil, nil), f.store)
+		var list articleList
+		if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+			t.Fatalf("%q: decoding list: %v", query, err)
+		}
+		if got := articleTitles(list.Data); len(got) != 1 || got[0] != want {
+			t.Errorf("%q: listed %q, want %q", query, got, want)
+		}
+	}
+}
+
+func TestAdminCreateCategory(t *testing.T) {
+	f := newFixture(t)
+
+	tests := []struct {
+		name string
+		want int
+	}{
+		{"  Web   Development ", http.StatusSeeOther},
+		{"web development", http.StatusConflict},
+		{"--", http.StatusBadRequest},
+		{strings.Repeat("a", maxTagLength+1), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		AdminCreateCategory(w, f.form("/admin/categories", f.admin, url.Values{"name": {tt.name}}), f.store)
+		if w.Code != tt.want {
+			t.Errorf("%q: status %d, want %d", tt.name, w.Code, tt.want)
+		}
+	}
+
+	categories, err := f.store.Categories.List()
+	if err != nil || len(categories) != 1 || categories[0] != (Category{ID: categories[0].ID, Name: "Web Development", Slug: "web-development"}) {
+		t.Errorf("categories %+v (%v), want the one created", categories, err)
+	}
+}
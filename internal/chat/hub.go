@@ -0,0 +1,167 @@
+package chat
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	writeWait      = 10 * time.Second    //Time allowed to write a message to the peer
+	pongWait       = 60 * time.Second    //Time allowed to read the next pong message from the peer
+	pingPeriod     = (pongWait * 9) / 10 //Send pings to the peer with this period, must be less than pongWait
+	maxMessageSize = 4096                //Maximum message size allowed from the peer
+	sendBufferSize = 256                 //Number of outgoing messages buffered per client
+)
+
+// Hub keeps track of the connected clients and broadcasts messages to all of them
+type Hub struct {
+	store      *Store
+	clients    map[*Client]bool
+	register   chan *Client
+	unregister chan *Client
+	broadcast  chan Message
+}
+
+// NewHub creates a hub that persists messages through the given store
+// The returned hub does nothing until Run is started in its own goroutine
+func NewHub(store *Store) *Hub {
+	return &Hub{
+		store:      store,
+		clients:    make(map[*Client]bool),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		broadcast:  make(chan Message),
+	}
+}
+
+// Run processes client registrations and fans out broadcast messages
+// It blocks forever and is meant to be started with "go hub.Run()"
+func (h *Hub) Run() {
+	for {
+		select {
+		case client := <-h.register:
+			h.clients[client] = true
+		case client := <-h.unregister:
+			if _, ok := h.clients[client]; ok {
+				delete(h.clients, client)
+				close(client.send)
+			}
+		case msg := <-h.broadcast:
+			for client := range h.clients {
+				select {
+				case client.send <- msg:
+				default:
+					//Dropping clients that are too slow to keep up with the conversation
+					delete(h.clients, client)
+					close(client.send)
+				}
+			}
+		}
+	}
+}
+
+// Client is a middleman between a WebSocket connection and the hub
+type Client struct {
+	hub    *Hub
+	conn   *websocket.Conn
+	send   chan Message
+	name   string
+	userID int
+}
+
+// Serve attaches the connection to the hub on behalf of the given user, userID is 0 for users without a users row
+// It sends the message history, then pumps messages until the connection is closed
+func (h *Hub) Serve(conn *websocket.Conn, name string, userID int) {
+	client := &Client{
+		hub:    h,
+		conn:   conn,
+		send:   make(chan Message, sendBufferSize),
+		name:   name,
+		userID: userID,
+	}
+
+	//Loading the message history before the client starts receiving live messages
+	history, err := h.store.Recent(historySize)
+	if err != nil {
+		log.Printf("Error loading chat history: %v", err)
+	}
+	for _, msg := range history {
+		client.send <- msg
+	}
+
+	h.register <- client
+	go client.writePump()
+	client.readPump()
+}
+
+// readPump reads messages from the connection, stores them and hands them to the hub
+func (c *Client) readPump() {
+	defer func() {
+		c.hub.unregister <- c
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(maxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		var in struct {
+			Body string `json:"body"`
+		}
+		if err := c.conn.ReadJSON(&in); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				log.Printf("Error reading chat message: %v", err)
+			}
+			return
+		}
+
+		//Ignoring empty messages
+		body := strings.TrimSpace(in.Body)
+		if body == "" {
+			continue
+		}
+
+		//Persisting the message with the identity of the connected user
+		msg := Message{UserName: c.name, UserID: c.userID, Body: body}
+		if err := c.hub.store.Save(&msg); err != nil {
+			log.Printf("Error saving chat message: %v", err)
+			continue
+		}
+		c.hub.broadcast <- msg
+	}
+}
+
+// writePump writes messages from the hub to the connection and keeps it alive with pings
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if !ok {
+				//The hub closed the channel
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteJSON(msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
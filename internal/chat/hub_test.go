@@ -0,0 +1,199 @@
+package chat
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeDB is a database/sql connector keeping the messages table in memory
+// It understands exactly the statements of the Store, so the tests run without PostgreSQL
+type fakeDB struct {
+	mu   sync.Mutex
+	rows [][]driver.Value //id, user_name, user_id or nil, body, created_at, ordered by id
+}
+
+func newFakeDB() (*fakeDB, *sql.DB) {
+	f := &fakeDB{}
+	return f, sql.OpenDB(f)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New("unexpected statement: " + s.query)
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(s.query, "INSERT INTO messages"):
+		//Storing users without a users row as NULL like NULLIF($2, 0)
+		id, created := int64(len(s.db.rows)+1), time.Now()
+		var userID driver.Value
+		if args[1].(int64) != 0 {
+			userID = args[1]
+		}
+		s.db.rows = append(s.db.rows, []driver.Value{id, args[0], userID, args[2], created})
+		return &fakeRows{columns: []string{"id", "created_at"}, values: [][]driver.Value{{id, created}}}, nil
+	case strings.HasPrefix(s.query, "SELECT id, user_name, COALESCE(user_id, 0), body, created_at"):
+		latest := s.db.rows
+		if limit := int(args[0].(int64)); len(latest) > limit {
+			latest = latest[len(latest)-limit:]
+		}
+		rows := &fakeRows{columns: []string{"id", "user_name", "user_id", "body", "created_at"}}
+		for _, r := range latest {
+			row := append([]driver.Value{}, r...)
+			if row[2] == nil {
+				row[2] = int64(0)
+			}
+			rows.values = append(rows.values, row)
+		}
+		return rows, nil
+	}
+	return nil, errors.New("unexpected query: " + s.query)
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func TestStore(t *testing.T) {
+	_, sqlDB := newFakeDB()
+	store := NewStore(sqlDB)
+
+	for i := 1; i <= historySize+5; i++ {
+		msg := Message{UserName: "Ada", UserID: i % 2, Body: "Message " + strconv.Itoa(i)}
+		if err := store.Save(&msg); err != nil || msg.ID != i || msg.CreatedAt.IsZero() {
+			t.Fatalf("saving message %d: %+v (%v)", i, msg, err)
+		}
+	}
+
+	//The latest messages come oldest first, authors without a users row have user ID 0
+	recent, err := store.Recent(historySize)
+	if err != nil || len(recent) != historySize {
+		t.Fatalf("%d recent messages (%v), want %d", len(recent), err, historySize)
+	}
+	if first, last := recent[0], recent[len(recent)-1]; first.Body != "Message 6" || first.UserID != 0 || last.Body != "Message 55" || last.UserID != 1 {
+		t.Errorf("recent messages run from %+v to %+v", first, last)
+	}
+}
+
+// dial connects a client of the named user to the chat server and reads the message history it is sent
+func dial(t *testing.T, server *httptest.Server, name string, userID, history int) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "?name=" + name + "&id=" + strconv.Itoa(userID)
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("connecting %s: %v", name, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	for i := 0; i < history; i++ {
+		receive(t, conn)
+	}
+	return conn
+}
+
+// receive reads the next message sent to the client
+func receive(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+	var msg Message
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("receiving message: %v", err)
+	}
+	return msg
+}
+
+func TestHub(t *testing.T) {
+	_, sqlDB := newFakeDB()
+	store := NewStore(sqlDB)
+	if err := store.Save(&Message{UserName: "Ada", UserID: 7, Body: "Earlier"}); err != nil {
+		t.Fatalf("saving message: %v", err)
+	}
+	hub := NewHub(store)
+	go hub.Run()
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		id, _ := strconv.Atoi(r.URL.Query().Get("id"))
+		hub.Serve(conn, r.URL.Query().Get("name"), id)
+	}))
+	defer server.Close()
+
+	//Clients get the history when they connect
+	ada := dial(t, server, "Ada", 7, 0)
+	if msg := receive(t, ada); msg.Body != "Earlier" || msg.UserName != "Ada" {
+		t.Errorf("history %+v, want the earlier message", msg)
+	}
+	guest := dial(t, server, "Guest", 0, 1)
+
+	//Messages are stored and sent to every client with the identity of their author, empty ones are dropped
+	for _, body := range []string{"   ", " Hello "} {
+		if err := ada.WriteJSON(map[string]string{"body": body}); err != nil {
+			t.Fatalf("sending message: %v", err)
+		}
+	}
+	for name, conn := range map[string]*websocket.Conn{"Ada": ada, "Guest": guest} {
+		if msg := receive(t, conn); msg.ID != 2 || msg.Body != "Hello" || msg.UserName != "Ada" || msg.UserID != 7 {
+			t.Errorf("%s received %+v, want the message of Ada", name, msg)
+		}
+	}
+	if err := guest.WriteJSON(map[string]string{"body": "Hi"}); err != nil {
+		t.Fatalf("sending message: %v", err)
+	}
+	if msg := receive(t, ada); msg.Body != "Hi" || msg.UserName != "Guest" || msg.UserID != 0 {
+		t.Errorf("Ada received %+v, want the message of the guest", msg)
+	}
+
+	recent, err := store.Recent(historySize)
+	if err != nil || len(recent) != 3 {
+		t.Errorf("%d stored messages (%v), want 3", len(recent), err)
+	}
+}
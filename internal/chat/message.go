@@ -0,0 +1,62 @@
+// Package chat implements the real-time chat subsystem: an in-process hub that
+// fans messages out to connected WebSocket clients and a Postgres-backed history
+package chat
+
+import (
+	"database/sql"
+	"time"
+)
+
+// historySize is the number of past messages sent to a client when it connects
+const historySize = 50
+
+// Message represents a single chat message together with the identity of its author
+// Messages are sent to every connected client, so they never carry the email of the author
+type Message struct {
+	ID        int       `json:"id"`         //Unique identifier of the message
+	UserName  string    `json:"user_name"`  //Display name of the author
+	UserID    int       `json:"user_id"`    //users.id of the author, 0 when the author has no users row
+	Body      string    `json:"body"`       //Text content of the message
+	CreatedAt time.Time `json:"created_at"` //Time the message was stored
+}
+
+// Store persists chat messages in the "messages" table
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore returns a Store that reads and writes messages using the given database connection
+func NewStore(db *sql.DB) *Store {
+	return &Store{db: db}
+}
+
+// Save inserts the message into the database and fills in its ID and creation time
+func (s *Store) Save(msg *Message) error {
+	return s.db.QueryRow(
+		"INSERT INTO messages (user_name, user_id, body) VALUES ($1, NULLIF($2, 0), $3) RETURNING id, created_at",
+		msg.UserName, msg.UserID, msg.Body,
+	).Scan(&msg.ID, &msg.CreatedAt)
+}
+
+// Recent returns up to limit of the latest messages ordered from oldest to newest
+func (s *Store) Recent(limit int) ([]Message, error) {
+	rows, err := s.db.Query(
+		`SELECT id, user_name, COALESCE(user_id, 0), body, created_at FROM (
+			SELECT id, user_name, user_id, body, created_at FROM messages ORDER BY id DESC LIMIT $1
+		) AS latest ORDER BY id ASC`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	//Scanning every row into a Message
+	messages := []Message{}
+	for rows.Next() {
+		var msg Message
+		if err := rows.Scan(&msg.ID, &msg.UserName, &msg.UserID, &msg.Body, &msg.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	return messages, rows.Err()
+}
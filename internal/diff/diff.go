@@ -0,0 +1,118 @@
+// Package diff compares two texts line by line
+// The lines are matched by their longest common subsequence, so the result is the smallest set of
+// Inserted and deleted lines that turns the old text into the new one
+package diff
+
+import "strings"
+
+// Op is the kind of change of a line
+type Op string
+
+const (
+	Equal  Op = "equal"  //The line is in both texts
+	Insert Op = "insert" //The line was added to the new text
+	Delete Op = "delete" //The line was removed from the old text
+)
+
+// Line is a line of the diff
+type Line struct {
+	Op   Op
+	Text string
+	Old  int //Number of the line in the old text starting at 1, 0 for inserted lines
+	New  int //Number of the line in the new text starting at 1, 0 for deleted lines
+}
+
+// Lines returns the line diff turning the old text into the new one
+// Line endings are normalized, so texts only differing in \r\n and \n are equal
+func Lines(old, new string) []Line {
+	a, b := split(old), split(new)
+
+	//Skipping the common prefix and suffix, edits usually touch a small part of the text
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(a)+len(b))
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, Line{Op: Equal, Text: a[i], Old: i + 1, New: i + 1})
+	}
+	lines = appendMiddle(lines, a[prefix:len(a)-suffix], b[prefix:len(b)-suffix], prefix)
+	for i := suffix; i > 0; i-- {
+		lines = append(lines, Line{Op: Equal, Text: a[len(a)-i], Old: len(a) - i + 1, New: len(b) - i + 1})
+	}
+	return lines
+}
+
+// Changed reports whether the diff contains inserted or deleted lines
+func Changed(lines []Line) bool {
+	for _, line := range lines {
+		if line.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// split splits a text into lines, an empty text has no lines
+func split(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// maxCells limits the size of the table of appendMiddle, larger changes are shown as replacing every line
+// Diffs are shown to guests, so the table is kept at 1 MB, e.g. about 500 changed lines on either side
+const maxCells = 1 << 18
+
+// appendMiddle appends the diff of the lines between the common prefix and suffix, offset is the length of the prefix
+func appendMiddle(lines []Line, a, b []string, offset int) []Line {
+	if (len(a)+1)*(len(b)+1) > maxCells {
+		for i, text := range a {
+			lines = append(lines, Line{Op: Delete, Text: text, Old: offset + i + 1})
+		}
+		for j, text := range b {
+			lines = append(lines, Line{Op: Insert, Text: text, New: offset + j + 1})
+		}
+		return lines
+	}
+
+	//lcs[i*width+j] is the length of the longest common subsequence of a[i:] and b[j:], kept in one flat table
+	width := len(b) + 1
+	lcs := make([]int32, (len(a)+1)*width)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i*width+j] = lcs[(i+1)*width+j+1] + 1
+			} else if lcs[(i+1)*width+j] >= lcs[i*width+j+1] {
+				lcs[i*width+j] = lcs[(i+1)*width+j]
+			} else {
+				lcs[i*width+j] = lcs[i*width+j+1]
+			}
+		}
+	}
+
+	//Walking the table, deleted lines are listed before the lines inserted in their place
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, Line{Op: Equal, Text: a[i], Old: offset + i + 1, New: offset + j + 1})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && lcs[(i+1)*width+j] >= lcs[i*width+j+1]):
+			lines = append(lines, Line{Op: Delete, Text: a[i], Old: offset + i + 1})
+			i++
+		default:
+			lines = append(lines, Line{Op: Insert, Text: b[j], New: offset + j + 1})
+			j++
+		}
+	}
+	return lines
+}
@@ -0,0 +1,95 @@
+package diff
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []Line
+	}{
+		{"both empty", "", "", []Line{}},
+		{"equal", "a\nb\n", "a\nb", []Line{
+			{Op: Equal, Text: "a", Old: 1, New: 1},
+			{Op: Equal, Text: "b", Old: 2, New: 2},
+		}},
+		{"line endings", "a\r\nb\r\n", "a\nb\n", []Line{
+			{Op: Equal, Text: "a", Old: 1, New: 1},
+			{Op: Equal, Text: "b", Old: 2, New: 2},
+		}},
+		{"insert into empty", "", "a\nb", []Line{
+			{Op: Insert, Text: "a", New: 1},
+			{Op: Insert, Text: "b", New: 2},
+		}},
+		{"delete everything", "a\nb", "", []Line{
+			{Op: Delete, Text: "a", Old: 1},
+			{Op: Delete, Text: "b", Old: 2},
+		}},
+		{"changed line", "a\nb\nc", "a\nx\nc", []Line{
+			{Op: Equal, Text: "a", Old: 1, New: 1},
+			{Op: Delete, Text: "b", Old: 2},
+			{Op: Insert, Text: "x", New: 2},
+			{Op: Equal, Text: "c", Old: 3, New: 3},
+		}},
+		{"moved line", "a\nb\nc\nd", "b\nc\na\nd", []Line{
+			{Op: Delete, Text: "a", Old: 1},
+			{Op: Equal, Text: "b", Old: 2, New: 1},
+			{Op: Equal, Text: "c", Old: 3, New: 2},
+			{Op: Insert, Text: "a", New: 3},
+			{Op: Equal, Text: "d", Old: 4, New: 4},
+		}},
+	}
+	for _, tt := range tests {
+		if got := Lines(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Lines(%q, %q) = %+v, want %+v", tt.name, tt.old, tt.new, got, tt.want)
+		}
+	}
+}
+
+// TestLinesApply checks that the diff turns the old text into the new one, also for changes too large for the table
+func TestLinesApply(t *testing.T) {
+	numbered := func(n, step int) string {
+		var b strings.Builder
+		for i := 0; i < n; i += step {
+			b.WriteString(strconv.Itoa(i) + "\n")
+		}
+		return b.String()
+	}
+	tests := []struct{ old, new string }{
+		{"a\nb\nc\nd\ne", "x\nb\ny\nd\nz\nw"},
+		{numbered(100, 1), numbered(100, 3)},
+		{numbered(2000, 1), numbered(2000, 2)},
+	}
+	for _, tt := range tests {
+		lines := Lines(tt.old, tt.new)
+		var old, new []string
+		for _, line := range lines {
+			if line.Op != Insert {
+				old = append(old, line.Text)
+			}
+			if line.Op != Delete {
+				new = append(new, line.Text)
+			}
+		}
+		if got, want := strings.Join(old, "\n"), strings.Join(split(tt.old), "\n"); got != want {
+			t.Errorf("old side of the diff of %d lines does not match the old text", len(split(tt.old)))
+		}
+		if got, want := strings.Join(new, "\n"), strings.Join(split(tt.new), "\n"); got != want {
+			t.Errorf("new side of the diff of %d lines does not match the new text", len(split(tt.new)))
+		}
+	}
+}
+
+func TestChanged(t *testing.T) {
+	if Changed(Lines("a\nb", "a\nb\n")) {
+		t.Error("Changed reports a change for equal texts")
+	}
+	if !Changed(Lines("a\nb", "a\nc")) {
+		t.Error("Changed reports no change for different texts")
+	}
+}
@@ -0,0 +1,114 @@
+// Package markdown renders the Markdown of articles to sanitized HTML
+// Goldmark converts the Markdown, bluemonday then removes everything that is not on a strict allow-list,
+// So raw HTML, scripts, event handlers and javascript: links written by authors never reach the page
+package markdown
+
+import (
+	"bytes"
+	"container/list"
+	"html/template"
+	"regexp"
+	"sync"
+
+	"github.com/microcosm-cc/bluemonday" // Package bluemonday sanitizes HTML against an allow-list of elements and attributes
+	"github.com/yuin/goldmark"           // Package goldmark converts CommonMark Markdown to HTML
+	"github.com/yuin/goldmark/extension" // Package extension adds GitHub Flavored Markdown syntax like tables and strikethrough
+)
+
+// converter converts GitHub Flavored Markdown, raw HTML is dropped by goldmark unless the unsafe option is set
+var converter = goldmark.New(goldmark.WithExtensions(extension.GFM))
+
+// policy is the allow-list of elements and attributes kept in the rendered HTML
+var policy = newPolicy()
+
+// newPolicy returns the sanitizer policy for the HTML produced from Markdown
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.AllowElements("p", "br", "hr", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote",
+		"ul", "ol", "li", "em", "strong", "del", "code", "pre",
+		"table", "thead", "tbody", "tr", "th", "td")
+
+	//Links and images may only point to web and mail addresses, links get rel="nofollow noreferrer"
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("src", "alt", "title").OnElements("img")
+	p.AllowAttrs("title").OnElements("a")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.AllowRelativeURLs(true)
+	p.RequireParseableURLs(true)
+	p.RequireNoFollowOnLinks(true)
+	p.RequireNoReferrerOnLinks(true)
+
+	//Keeping the language of fenced code blocks, list numbering, table alignment and task list checkboxes
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+-]+$`)).OnElements("code")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("style").Matching(regexp.MustCompile(`^text-align:(left|right|center)$`)).OnElements("th", "td")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	return p
+}
+
+// Render converts the Markdown source to sanitized HTML that can be put into a template as is
+func Render(source string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := converter.Convert([]byte(source), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes())), nil
+}
+
+// cacheKey identifies one revision of an article
+type cacheKey struct {
+	id       int
+	revision int
+}
+
+// cacheEntry is an element of the recently used list of the cache
+type cacheEntry struct {
+	key  cacheKey
+	html template.HTML
+}
+
+// Cache keeps the rendered HTML of the most recently shown article revisions
+// Editing an article creates a new revision, so stale HTML is never returned and simply ages out
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[cacheKey]*list.Element
+	recent  *list.List //Most recently used entries first
+}
+
+// NewCache returns a cache holding the HTML of up to size article revisions
+func NewCache(size int) *Cache {
+	return &Cache{size: size, entries: map[cacheKey]*list.Element{}, recent: list.New()}
+}
+
+// Render returns the HTML of the given revision of an article, rendering the source on a cache miss
+func (c *Cache) Render(id, revision int, source string) (template.HTML, error) {
+	key := cacheKey{id: id, revision: revision}
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.recent.MoveToFront(e)
+		html := e.Value.(*cacheEntry).html
+		c.mu.Unlock()
+		return html, nil
+	}
+	c.mu.Unlock()
+
+	//Rendering outside of the lock, concurrent misses of the same revision produce the same HTML
+	html, err := Render(source)
+	if err != nil {
+		return "", err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok {
+		c.entries[key] = c.recent.PushFront(&cacheEntry{key: key, html: html})
+		if c.recent.Len() > c.size {
+			oldest := c.recent.Back()
+			c.recent.Remove(oldest)
+			delete(c.entries, oldest.Value.(*cacheEntry).key)
+		}
+	}
+	return html, nil
+}
@@ -0,0 +1,67 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		contains []string
+		excludes []string
+	}{
+		{"emphasis", "*hello* **world**", []string{"<em>hello</em>", "<strong>world</strong>"}, nil},
+		{"fenced code", "```go\nfmt.Println()\n```", []string{`<code class="language-go">`}, nil},
+		{"table", "| a | b |\n|:-|-:|\n| 1 | 2 |", []string{"<table>", `<th style="text-align:left">a</th>`}, nil},
+		{"task list", "- [x] done", []string{`<input checked="" disabled="" type="checkbox"`}, nil},
+		{"strikethrough", "~~gone~~", []string{"<del>gone</del>"}, nil},
+		{"script", "<script>alert(1)</script>", nil, []string{"<script", "alert(1)"}},
+		{"event handler", `<img src="/media/1" onerror="alert(1)">`, nil, []string{"onerror"}},
+		{"javascript link", "[click](javascript:alert(1))", nil, []string{"javascript:"}},
+		{"foreign class", `<code class="evil">x</code>`, nil, []string{"evil"}},
+		{"style", `<p style="color:red">x</p>`, nil, []string{"style"}},
+	}
+	for _, tt := range tests {
+		html, err := Render(tt.source)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(string(html), s) {
+				t.Errorf("%s: %q does not contain %q", tt.name, html, s)
+			}
+		}
+		for _, s := range tt.excludes {
+			if strings.Contains(string(html), s) {
+				t.Errorf("%s: %q contains %q", tt.name, html, s)
+			}
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	cache := NewCache(2)
+
+	//A revision is rendered once, later calls return the cached HTML even when the source differs
+	first, err := cache.Render(1, 1, "*one*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached, _ := cache.Render(1, 1, "*changed*"); cached != first {
+		t.Errorf("cached HTML %q, want %q", cached, first)
+	}
+
+	//A new revision is rendered from its own source
+	if html, _ := cache.Render(1, 2, "*two*"); !strings.Contains(string(html), "<em>two</em>") {
+		t.Errorf("new revision rendered as %q", html)
+	}
+
+	//The least recently used revision is dropped once the cache is full
+	cache.Render(2, 1, "*other*")
+	if html, _ := cache.Render(1, 1, "*three*"); !strings.Contains(string(html), "<em>three</em>") {
+		t.Errorf("evicted revision returned %q, want it rendered again", html)
+	}
+}
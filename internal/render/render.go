@@ -0,0 +1,74 @@
+// Package render parses the HTML templates once and executes them by name
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+)
+
+// pattern selects the template files inside the file system given to New
+const pattern = "templates/*.html"
+
+// Renderer executes named templates parsed from a file system
+// In dev mode the templates are parsed again before every execution, so edits show up without a restart
+type Renderer struct {
+	fsys     fs.FS
+	dev      bool
+	funcs    template.FuncMap
+	required []string
+	tmpl     *template.Template
+}
+
+// New parses the templates found in fsys with the given functions and checks that every required template is defined
+// It fails fast, so a missing page is reported at startup instead of on the first request
+func New(fsys fs.FS, dev bool, funcs template.FuncMap, required ...string) (*Renderer, error) {
+	r := &Renderer{fsys: fsys, dev: dev, funcs: funcs, required: required}
+	tmpl, err := r.parse()
+	if err != nil {
+		return nil, err
+	}
+	r.tmpl = tmpl
+	return r, nil
+}
+
+// parse parses every template file and verifies the required templates
+func (r *Renderer) parse() (*template.Template, error) {
+	tmpl, err := template.New("").Funcs(r.funcs).ParseFS(r.fsys, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("parsing templates: %w", err)
+	}
+	for _, name := range r.required {
+		if tmpl.Lookup(name) == nil {
+			return nil, fmt.Errorf("template %q is not defined", name)
+		}
+	}
+	return tmpl, nil
+}
+
+// Render executes the named template with data and writes the result to w
+// The output is buffered, so nothing is written when the template fails
+func (r *Renderer) Render(w io.Writer, name string, data interface{}) error {
+	tmpl, err := r.templates()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, name, data); err != nil {
+		return err
+	}
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// templates returns the parsed templates, reloading them from disk in dev mode
+func (r *Renderer) templates() (*template.Template, error) {
+	if !r.dev {
+		return r.tmpl, nil
+	}
+
+	return r.parse()
+}
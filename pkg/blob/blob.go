@@ -0,0 +1,83 @@
+// Package blob stores uploaded files under string keys
+// The local file system is used by default, any S3-compatible object storage can be used instead
+package blob
+
+import (
+	"VoAr/pkg/fakes3"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ErrNotFound is returned when no file is stored under the key
+var ErrNotFound = errors.New("blob not found")
+
+// ErrInvalidKey is returned for keys that are not made of lower case letters, digits, dots, dashes and slashes
+var ErrInvalidKey = errors.New("invalid blob key")
+
+// Storage stores files under keys like "articles/12/4f2a.png"
+type Storage interface {
+	// Put stores size bytes read from data under the key, replacing the file stored under it
+	Put(key string, data io.Reader, size int64, contentType string) error
+	// Get opens the file stored under the key or returns ErrNotFound, the caller closes it
+	Get(key string) (io.ReadCloser, error)
+	// Delete removes the file stored under the key, removing a missing file is not an error
+	Delete(key string) error
+}
+
+// validKey matches the keys accepted by the storages, path segments cannot start with a dot
+var validKey = regexp.MustCompile(`^[a-z0-9_-][a-z0-9._-]*(/[a-z0-9_-][a-z0-9._-]*)*$`)
+
+// checkKey returns ErrInvalidKey unless the key can be used as a file path and in a URL as is
+func checkKey(key string) error {
+	if !validKey.MatchString(key) {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// FakePath is the path the fake S3 storage is mounted at
+const FakePath = "/fake-s3"
+
+// FromEnv creates the storage selected by the BLOB_STORAGE environment variable
+// BLOB_STORAGE=s3 uses S3_ENDPOINT, S3_REGION, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY,
+// FAKE_S3=true uses the fake storage of the fakes3 package, which is returned to be mounted at FakePath
+// Any other value stores the files in the BLOB_DIR directory, "uploads" by default
+func FromEnv() (Storage, *fakes3.Server, error) {
+	//The fake storage is served by this application, so it is reached through BASE_URL
+	if os.Getenv("FAKE_S3") == "true" {
+		base := strings.TrimRight(os.Getenv("BASE_URL"), "/")
+		if base == "" {
+			base = "http://localhost:8080"
+		}
+		fake := fakes3.New(FakePath, "us-east-1", "voar-dev", "voar-dev-secret")
+		return &S3{Endpoint: base + FakePath, Region: fake.Region, Bucket: "voar", AccessKey: fake.AccessKey, SecretKey: fake.SecretKey}, fake, nil
+	}
+
+	switch os.Getenv("BLOB_STORAGE") {
+	case "s3":
+		s := &S3{
+			Endpoint:  strings.TrimRight(os.Getenv("S3_ENDPOINT"), "/"),
+			Region:    os.Getenv("S3_REGION"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		}
+		if s.Endpoint == "" || s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+			return nil, nil, fmt.Errorf("BLOB_STORAGE=s3 requires S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY")
+		}
+		if s.Region == "" {
+			s.Region = "us-east-1"
+		}
+		return s, nil, nil
+	default:
+		dir := os.Getenv("BLOB_DIR")
+		if dir == "" {
+			dir = "uploads"
+		}
+		return &FS{Dir: dir}, nil, nil
+	}
+}
@@ -0,0 +1,91 @@
+package blob
+
+import (
+	"VoAr/pkg/sigv4"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// S3 stores the files as objects of a bucket of an S3-compatible object storage, like AWS S3 or MinIO
+// The objects are addressed path-style, "<endpoint>/<bucket>/<key>", which every S3-compatible storage supports
+// Requests are signed with AWS Signature Version 4
+type S3 struct {
+	Endpoint  string //URL of the storage without a trailing slash, e.g. "https://s3.eu-central-1.amazonaws.com"
+	Region    string //Region the bucket is in, e.g. "eu-central-1"
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client //Client sending the requests, http.DefaultClient when nil
+}
+
+// do sends a signed request for the object stored under the key
+func (s *S3) do(method, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, s.Endpoint+"/"+s.Bucket+"/"+key, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+		req.Header.Set("Content-Type", contentType)
+	}
+	sigv4.Sign(req, s.AccessKey, s.SecretKey, s.Region, time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// responseError describes an unexpected response of the storage, the body holds an XML error document
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3: %s %s: %s: %s", resp.Request.Method, resp.Request.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+}
+
+func (s *S3) Put(key string, data io.Reader, size int64, contentType string) error {
+	resp, err := s.do(http.MethodPut, key, data, size, contentType)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+	return nil
+}
+
+func (s *S3) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+}
+
+func (s *S3) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return responseError(resp)
+	}
+	return nil
+}
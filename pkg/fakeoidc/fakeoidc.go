@@ -0,0 +1,246 @@
+// Package fakeoidc is a minimal OpenID Connect issuer for local development and manual testing
+// Its sign-in page lets anyone sign in as any name and email, so it must never be enabled in production
+// The ID tokens are unsigned ("alg": "none"), which the goth openidConnect provider accepts
+package fakeoidc
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenLifetime is how long the issued access and ID tokens are valid
+const tokenLifetime = time.Hour
+
+// Claims are the identity claims of a fake user
+type Claims struct {
+	Subject string `json:"sub"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Picture string `json:"picture,omitempty"`
+	Locale  string `json:"locale,omitempty"`
+}
+
+// Server is an http.Handler implementing the discovery, authorization, token and userinfo endpoints
+// It has to be mounted at the path of its issuer URL, e.g. with http.StripPrefix
+type Server struct {
+	Issuer       string //Public URL the server is mounted at, e.g. "http://localhost:8080/fake-oidc"
+	ClientID     string //The only client allowed to exchange codes
+	ClientSecret string
+	RedirectURI  string //The registered callback URL of the client, the only one codes are sent to
+
+	mu     sync.Mutex
+	codes  map[string]Claims //Authorization codes waiting to be exchanged
+	tokens map[string]Claims //Access tokens accepted by the userinfo endpoint
+}
+
+// New returns a fake issuer that serves the given client and sends codes only to its redirect URI
+func New(issuer, clientID, clientSecret, redirectURI string) *Server {
+	return &Server{
+		Issuer:       strings.TrimRight(issuer, "/"),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURI:  redirectURI,
+		codes:        map[string]Claims{},
+		tokens:       map[string]Claims{},
+	}
+}
+
+// AuthURL returns the URL of the authorization endpoint
+func (s *Server) AuthURL() string { return s.Issuer + "/authorize" }
+
+// TokenURL returns the URL of the token endpoint
+func (s *Server) TokenURL() string { return s.Issuer + "/token" }
+
+// UserInfoURL returns the URL of the userinfo endpoint
+func (s *Server) UserInfoURL() string { return s.Issuer + "/userinfo" }
+
+// ServeHTTP dispatches the request to the endpoint named by the path
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch strings.TrimPrefix(r.URL.Path, "/") {
+	case ".well-known/openid-configuration":
+		s.discovery(w, r)
+	case "authorize":
+		if r.Method == http.MethodPost {
+			s.approve(w, r)
+			return
+		}
+		s.signInForm(w, r)
+	case "token":
+		s.token(w, r)
+	case "userinfo":
+		s.userInfo(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// discovery serves the OpenID Provider metadata
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.Issuer,
+		"authorization_endpoint":                s.AuthURL(),
+		"token_endpoint":                        s.TokenURL(),
+		"userinfo_endpoint":                     s.UserInfoURL(),
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"none"},
+		"scopes_supported":                      []string{"openid", "email", "profile"},
+	})
+}
+
+// signInPage asks for the identity to sign in as
+var signInPage = template.Must(template.New("fakeoidc").Parse(`<!doctype html>
+<html>
+<head><title>Fake OpenID Connect sign-in</title></head>
+<body style="font-family: sans-serif; max-width: 30em; margin: 4em auto">
+  <h1>Fake OpenID Connect</h1>
+  <p>Development only: sign in as any user.</p>
+  <form method="post">
+    {{ range $name, $value := . }}<input type="hidden" name="{{ $name }}" value="{{ index $value 0 }}">
+    {{ end }}
+    <p><label>Name<br><input name="name" value="Dev User" required></label></p>
+    <p><label>Email<br><input type="email" name="email" value="dev@example.com" required></label></p>
+    <p><label>Avatar URL<br><input type="url" name="picture"></label></p>
+    <p><label>Locale<br><input name="locale" value="en"></label></p>
+    <p><button type="submit">Sign in</button></p>
+  </form>
+</body>
+</html>`))
+
+// signInForm renders the sign-in page, carrying the authorization request in hidden fields
+func (s *Server) signInForm(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("client_id") != s.ClientID {
+		http.Error(w, "unknown client_id", http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("redirect_uri") != s.RedirectURI {
+		http.Error(w, "redirect_uri is not registered", http.StatusBadRequest)
+		return
+	}
+	params := url.Values{}
+	for _, name := range []string{"client_id", "redirect_uri", "state"} {
+		params.Set(name, r.FormValue(name))
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	signInPage.Execute(w, params)
+}
+
+// approve issues an authorization code for the submitted identity and redirects back to the client
+// Only the registered redirect URI is accepted, so the issuer cannot be used to send codes or users to other sites
+func (s *Server) approve(w http.ResponseWriter, r *http.Request) {
+	redirect, err := url.Parse(r.FormValue("redirect_uri"))
+	if err != nil || r.FormValue("redirect_uri") != s.RedirectURI || r.FormValue("client_id") != s.ClientID || r.FormValue("email") == "" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(r.FormValue("email")))
+	claims := Claims{
+		Subject: "fake|" + email,
+		Name:    r.FormValue("name"),
+		Email:   email,
+		Picture: r.FormValue("picture"),
+		Locale:  r.FormValue("locale"),
+	}
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = claims
+	s.mu.Unlock()
+
+	query := redirect.Query()
+	query.Set("code", code)
+	query.Set("state", r.FormValue("state"))
+	redirect.RawQuery = query.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token exchanges an authorization code for an access token and an ID token
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	//Authenticating the client with HTTP Basic authentication or form parameters
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.FormValue("client_id"), r.FormValue("client_secret")
+	}
+	if clientID != s.ClientID || secret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	//Consuming the code, each code can be exchanged only once
+	code := r.FormValue("code")
+	s.mu.Lock()
+	claims, found := s.codes[code]
+	delete(s.codes, code)
+	accessToken := randomString()
+	if found {
+		s.tokens[accessToken] = claims
+	}
+	s.mu.Unlock()
+	if r.FormValue("grant_type") != "authorization_code" || !found {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(tokenLifetime.Seconds()),
+		"id_token":     s.idToken(claims),
+	})
+}
+
+// userInfo returns the claims of the user the bearer token was issued to
+func (s *Server) userInfo(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	s.mu.Lock()
+	claims, ok := s.tokens[token]
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	writeJSON(w, http.StatusOK, claims)
+}
+
+// idToken builds an unsigned JWT carrying the claims of the user
+func (s *Server) idToken(claims Claims) string {
+	now := time.Now()
+	payload := map[string]interface{}{
+		"iss":     s.Issuer,
+		"aud":     s.ClientID,
+		"sub":     claims.Subject,
+		"name":    claims.Name,
+		"email":   claims.Email,
+		"picture": claims.Picture,
+		"locale":  claims.Locale,
+		"iat":     now.Unix(),
+		"exp":     now.Add(tokenLifetime).Unix(),
+	}
+	header, _ := json.Marshal(map[string]string{"alg": "none", "typ": "JWT"})
+	body, _ := json.Marshal(payload)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(body) + "."
+}
+
+// writeJSON writes v as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// randomString returns a random URL-safe string used for codes and tokens
+func randomString() string {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic("fakeoidc: reading random bytes: " + err.Error())
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
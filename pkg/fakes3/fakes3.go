@@ -0,0 +1,176 @@
+// Package fakes3 is a minimal S3-compatible object storage keeping the objects in memory
+// It is meant for local development and manual testing of the S3 storage of the blob package
+// Only path-style PUT, GET, HEAD and DELETE of single objects are implemented, buckets are created on the first upload
+package fakes3
+
+import (
+	"VoAr/pkg/sigv4"
+	"bytes"
+	"crypto/subtle"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxObjectSize limits the size of the uploaded objects, the objects are held in memory
+const maxObjectSize = 64 << 20
+
+// clockSkew is how far the X-Amz-Date of a request may be from the time of the server
+const clockSkew = 15 * time.Minute
+
+// object is a stored object with the metadata returned when it is downloaded
+type object struct {
+	data        []byte
+	contentType string
+	modified    time.Time
+}
+
+// Server is an http.Handler storing objects under "<Prefix>/<bucket>/<key>"
+// Every request has to be signed with AWS Signature Version 4 using the credentials of the server
+type Server struct {
+	Prefix    string //Path the server is mounted at without a trailing slash, e.g. "/fake-s3"
+	Region    string
+	AccessKey string
+	SecretKey string
+
+	mu      sync.Mutex
+	objects map[string]object //Objects by "<bucket>/<key>"
+}
+
+// New returns an empty fake storage accepting requests signed with the given credentials
+func New(prefix, region, accessKey, secretKey string) *Server {
+	return &Server{
+		Prefix:    strings.TrimRight(prefix, "/"),
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		objects:   map[string]object{},
+	}
+}
+
+// ServeHTTP authenticates the request and dispatches it on its method
+// The server is mounted without stripping its prefix, as the signature covers the whole path
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, s.Prefix+"/")
+	bucket, key, ok := strings.Cut(path, "/")
+	if path == r.URL.Path || !ok || bucket == "" || key == "" {
+		writeError(w, http.StatusBadRequest, "InvalidRequest", "Only path-style requests for single objects are supported")
+		return
+	}
+	if code, message := s.authenticate(r); code != "" {
+		writeError(w, http.StatusForbidden, code, message)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		s.put(w, r, bucket+"/"+key)
+	case http.MethodGet, http.MethodHead:
+		s.get(w, r, bucket+"/"+key)
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.objects, bucket+"/"+key)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "The method is not allowed")
+	}
+}
+
+// authenticate checks the Authorization header of the request and returns the S3 error code when it is not valid
+func (s *Server) authenticate(r *http.Request) (code, message string) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+		return "AccessDenied", "The request is not signed with Signature Version 4"
+	}
+	fields := map[string]string{}
+	for _, field := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		fields[name] = value
+	}
+
+	//The credential is "<access key>/<date>/<region>/s3/aws4_request"
+	credential := strings.Split(fields["Credential"], "/")
+	if len(credential) != 5 || credential[0] != s.AccessKey {
+		return "InvalidAccessKeyId", "The access key does not exist"
+	}
+	date, err := time.Parse(sigv4.DateFormat, r.Header.Get("X-Amz-Date"))
+	if err != nil || credential[1] != date.Format("20060102") || credential[2] != s.Region || credential[3] != "s3" {
+		return "AuthorizationHeaderMalformed", "The credential scope does not match the request"
+	}
+	if d := time.Since(date); d > clockSkew || d < -clockSkew {
+		return "RequestTimeTooSkewed", "The difference between the request time and the server time is too large"
+	}
+
+	signed := strings.Split(fields["SignedHeaders"], ";")
+	if !contains(signed, "host") || !contains(signed, "x-amz-date") || !contains(signed, "x-amz-content-sha256") {
+		return "AccessDenied", "The host, x-amz-date and x-amz-content-sha256 headers have to be signed"
+	}
+	expected := sigv4.Signature(r, s.SecretKey, s.Region, signed)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(fields["Signature"])) != 1 {
+		return "SignatureDoesNotMatch", "The request signature does not match the signature computed by the server"
+	}
+	return "", ""
+}
+
+// put stores the body of the request, only unsigned payloads are accepted
+func (s *Server) put(w http.ResponseWriter, r *http.Request, name string) {
+	if r.Header.Get("X-Amz-Content-Sha256") != sigv4.UnsignedPayload {
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "Only unsigned payloads are supported")
+		return
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxObjectSize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "EntityTooLarge", "The object is too large or the upload was interrupted")
+		return
+	}
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "binary/octet-stream"
+	}
+
+	s.mu.Lock()
+	s.objects[name] = object{data: data, contentType: contentType, modified: time.Now()}
+	s.mu.Unlock()
+	w.Header().Set("ETag", `"`+strconv.Itoa(len(data))+`"`)
+	w.WriteHeader(http.StatusOK)
+}
+
+// get writes the stored object, a HEAD request only gets its headers
+func (s *Server) get(w http.ResponseWriter, r *http.Request, name string) {
+	s.mu.Lock()
+	obj, ok := s.objects[name]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchKey", "The specified key does not exist")
+		return
+	}
+	w.Header().Set("Content-Type", obj.contentType)
+	http.ServeContent(w, r, "", obj.modified, bytes.NewReader(obj.data))
+}
+
+// writeError writes an S3 error document
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	io.WriteString(w, xml.Header)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName xml.Name `xml:"Error"`
+		Code    string
+		Message string
+	}{Code: code, Message: message})
+}
+
+// contains reports whether the list holds the value
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package fakes3
+
+import (
+	"VoAr/pkg/sigv4"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testRegion = "us-east-1"
+	testKey    = "access"
+	testSecret = "secret"
+)
+
+// do sends a request signed at the given time to the fake storage
+func do(t *testing.T, server *httptest.Server, method, path, body string, secret string, at time.Time) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body != "" {
+		req.Header.Set("Content-Type", "text/plain")
+	}
+	sigv4.Sign(req, testKey, secret, testRegion, at)
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestRoundTrip(t *testing.T) {
+	server := httptest.NewServer(New("/fake-s3/", testRegion, testKey, testSecret))
+	defer server.Close()
+	now := time.Now()
+
+	if resp := do(t, server, "PUT", "/fake-s3/voar/media/1", "hello", testSecret, now); resp.StatusCode != http.StatusOK {
+		t.Fatalf("PUT: status %d", resp.StatusCode)
+	}
+
+	resp := do(t, server, "GET", "/fake-s3/voar/media/1", "", testSecret, now)
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "hello" || resp.Header.Get("Content-Type") != "text/plain" {
+		t.Errorf("GET: status %d, body %q, type %q", resp.StatusCode, body, resp.Header.Get("Content-Type"))
+	}
+
+	if resp := do(t, server, "DELETE", "/fake-s3/voar/media/1", "", testSecret, now); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE: status %d", resp.StatusCode)
+	}
+	if resp := do(t, server, "GET", "/fake-s3/voar/media/1", "", testSecret, now); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET after DELETE: status %d", resp.StatusCode)
+	}
+}
+
+func TestRejectsBadRequests(t *testing.T) {
+	server := httptest.NewServer(New("/fake-s3", testRegion, testKey, testSecret))
+	defer server.Close()
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		path   string
+		secret string
+		at     time.Time
+		want   int
+	}{
+		{"wrong secret", "/fake-s3/voar/media/1", "wrong", now, http.StatusForbidden},
+		{"old signature", "/fake-s3/voar/media/1", testSecret, now.Add(-time.Hour), http.StatusForbidden},
+		{"outside the prefix", "/other/voar/media/1", testSecret, now, http.StatusBadRequest},
+		{"bucket only", "/fake-s3/voar", testSecret, now, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if resp := do(t, server, "PUT", tt.path, "hello", tt.secret, tt.at); resp.StatusCode != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+
+	//Unsigned requests are refused
+	resp, err := server.Client().Get(server.URL + "/fake-s3/voar/media/1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("unsigned: status %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
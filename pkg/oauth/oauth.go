@@ -0,0 +1,132 @@
+// Package oauth configures the third-party sign-in providers used through Goth
+// Every provider is enabled by setting its client credentials in the environment, so the same binary
+// can offer Google, GitHub, GitLab, any OpenID Connect issuer and a fake issuer for local development
+package oauth
+
+import (
+	"VoAr/pkg/fakeoidc"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gorilla/sessions"                       // Package sessions provides cookie and filesystem sessions and infrastructure for custom session backends
+	"github.com/markbates/goth"                         // Package goth provides a simple, clean, and idiomatic way to write authentication packages
+	"github.com/markbates/goth/gothic"                  // Package gothic provides the ability to use multiple providers for authentication
+	"github.com/markbates/goth/providers/github"        // Package github implements the OAuth2 protocol for authenticating users with GitHub
+	"github.com/markbates/goth/providers/gitlab"        // Package gitlab implements the OAuth2 protocol for authenticating users with GitLab
+	"github.com/markbates/goth/providers/google"        // Package google implements the OAuth2 protocol for authenticating users with Google
+	"github.com/markbates/goth/providers/openidConnect" // Package openidConnect implements authentication with any OpenID Connect issuer
+)
+
+// FakePath is the path the fake OpenID Connect issuer is mounted at
+const FakePath = "/fake-oidc"
+
+// Provider is an enabled sign-in provider, listed on the sign-in page
+type Provider struct {
+	Name  string //Name used in the "/auth/{provider}" URLs
+	Label string //Human readable name shown on the sign-in button
+}
+
+// Registry holds the providers enabled from the environment
+type Registry struct {
+	Providers []Provider
+	Fake      *fakeoidc.Server //Fake issuer to mount at FakePath, nil unless FAKE_OIDC=true
+}
+
+// Setup makes the given store the session store of Goth and registers every provider configured
+// In the environment:
+//
+//	GOOGLE_CLIENT_ID, GOOGLE_CLIENT_SECRET                  Google
+//	GITHUB_CLIENT_ID, GITHUB_CLIENT_SECRET                  GitHub
+//	GITLAB_CLIENT_ID, GITLAB_CLIENT_SECRET, GITLAB_URL      GitLab.com or a self-hosted GitLab
+//	OIDC_CLIENT_ID, OIDC_CLIENT_SECRET, OIDC_DISCOVERY_URL  any OpenID Connect issuer, named by OIDC_NAME and OIDC_LABEL
+//	FAKE_OIDC=true                                          the fake issuer of the fakeoidc package
+//
+// Callback URLs are derived from BASE_URL, <PROVIDER>_CALLBACK_URL overrides them
+func Setup(store sessions.Store) (*Registry, error) {
+	//Setting the session store as the store used by Goth
+	gothic.Store = store
+
+	//Registering the user type so that it can be stored in session values
+	gob.Register(goth.User{})
+
+	base := strings.TrimRight(os.Getenv("BASE_URL"), "/")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	registry := &Registry{}
+	var providers []goth.Provider
+	add := func(p goth.Provider, label string) {
+		providers = append(providers, p)
+		registry.Providers = append(registry.Providers, Provider{Name: p.Name(), Label: label})
+	}
+
+	if id, secret, ok := credentials("GOOGLE"); ok {
+		add(google.New(id, secret, callbackURL(base, "GOOGLE", "google"), "email", "profile"), "Google")
+	}
+
+	if id, secret, ok := credentials("GITHUB"); ok {
+		add(github.New(id, secret, callbackURL(base, "GITHUB", "github"), "read:user", "user:email"), "GitHub")
+	}
+
+	if id, secret, ok := credentials("GITLAB"); ok {
+		callback := callbackURL(base, "GITLAB", "gitlab")
+		if server := strings.TrimRight(os.Getenv("GITLAB_URL"), "/"); server != "" {
+			add(gitlab.NewCustomisedURL(id, secret, callback,
+				server+"/oauth/authorize", server+"/oauth/token", server+"/api/v4/user", "read_user"), "GitLab")
+		} else {
+			add(gitlab.New(id, secret, callback, "read_user"), "GitLab")
+		}
+	}
+
+	if id, secret, ok := credentials("OIDC"); ok {
+		name := envOr("OIDC_NAME", "oidc")
+		discovery := os.Getenv("OIDC_DISCOVERY_URL")
+		if discovery == "" {
+			return nil, fmt.Errorf("OIDC_CLIENT_ID requires OIDC_DISCOVERY_URL")
+		}
+		p, err := openidConnect.NewNamed(name, id, secret, callbackURL(base, "OIDC", name), discovery, "email", "profile")
+		if err != nil {
+			return nil, fmt.Errorf("configuring OpenID Connect provider %q: %w", name, err)
+		}
+		add(p, envOr("OIDC_LABEL", "OpenID Connect"))
+	}
+
+	//The fake issuer is served by this application, so its endpoints are configured without discovery
+	if os.Getenv("FAKE_OIDC") == "true" {
+		callback := callbackURL(base, "FAKE_OIDC", "fake")
+		registry.Fake = fakeoidc.New(base+FakePath, "voar-dev", "voar-dev-secret", callback)
+		p, err := openidConnect.NewCustomisedURL(registry.Fake.ClientID, registry.Fake.ClientSecret, callback,
+			registry.Fake.AuthURL(), registry.Fake.TokenURL(), registry.Fake.Issuer, registry.Fake.UserInfoURL(), "", "email", "profile")
+		if err != nil {
+			return nil, err
+		}
+		p.SetName("fake")
+		add(p, "Fake OpenID Connect (development)")
+	}
+
+	goth.UseProviders(providers...)
+	return registry, nil
+}
+
+// credentials returns the client ID and secret of the provider with the given environment prefix
+// And reports whether the provider is configured
+func credentials(prefix string) (id, secret string, ok bool) {
+	id = os.Getenv(prefix + "_CLIENT_ID")
+	secret = os.Getenv(prefix + "_CLIENT_SECRET")
+	return id, secret, id != "" && secret != ""
+}
+
+// callbackURL returns the callback URL of the named provider, <PREFIX>_CALLBACK_URL takes precedence
+func callbackURL(base, prefix, name string) string {
+	return envOr(prefix+"_CALLBACK_URL", base+"/auth/"+name+"/callback")
+}
+
+// envOr returns the environment variable or the fallback when it is not set
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,255 @@
+// Package pgsession provides a gorilla/sessions Store that keeps session data in PostgreSQL
+// The cookie only carries a signed session ID, so sessions can be revoked on the server
+package pgsession
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/gob"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+const (
+	// DefaultIdleTimeout ends a session that has not been used for this long
+	DefaultIdleTimeout = 24 * time.Hour
+
+	// DefaultAbsoluteTimeout ends a session this long after it was created, however active it is
+	DefaultAbsoluteTimeout = 30 * 24 * time.Hour
+
+	// touchInterval limits how often last_seen_at is written for a session that is in use
+	touchInterval = time.Minute
+)
+
+// UserIDKey is the session value holding the users.id of the signed-in user
+// Sessions that carry it can be revoked all at once with RevokeUser
+const UserIDKey = "user_id"
+
+// ErrRevoked is returned by Save when the session was revoked or expired after it was loaded
+// Its cookie is deleted, the user has to sign in again
+var ErrRevoked = errors.New("pgsession: session was revoked")
+
+// Store is a sessions.Store backed by the "sessions" table
+type Store struct {
+	db              *sql.DB
+	codecs          []securecookie.Codec
+	Options         *sessions.Options //Default cookie options of new sessions
+	IdleTimeout     time.Duration     //Time of inactivity after which a session ends
+	AbsoluteTimeout time.Duration     //Maximum lifetime of a session
+}
+
+// New returns a Store that signs session IDs with the given key pairs, like sessions.NewCookieStore
+func New(db *sql.DB, keyPairs ...[]byte) *Store {
+	s := &Store{
+		db:     db,
+		codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		},
+		IdleTimeout:     DefaultIdleTimeout,
+		AbsoluteTimeout: DefaultAbsoluteTimeout,
+	}
+	s.SetMaxAge(s.AbsoluteTimeout)
+	return s
+}
+
+// SetMaxAge changes the absolute timeout together with the lifetime of the cookies
+func (s *Store) SetMaxAge(d time.Duration) {
+	s.AbsoluteTimeout = d
+	s.Options.MaxAge = int(d.Seconds())
+	for _, codec := range s.codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(s.Options.MaxAge)
+		}
+	}
+}
+
+// Get returns the session for the request, cached for the lifetime of the request
+func (s *Store) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named in the cookie from the database
+// A missing, invalid, revoked or expired session results in a new empty session
+func (s *Store) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	options := *s.Options
+	session.Options = &options
+	session.IsNew = true
+
+	//Decoding the session ID from the cookie
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+	var id string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &id, s.codecs...); err != nil {
+		return session, nil
+	}
+
+	//Loading the session data, a session that no longer exists is replaced by a new one
+	found, err := s.load(id, session)
+	if err != nil {
+		return session, err
+	}
+	if found {
+		session.ID = id
+		session.IsNew = false
+	}
+	return session, nil
+}
+
+// Save writes the session to the database and sets the cookie with its signed ID
+// A negative MaxAge deletes the session and its cookie
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			if err := s.Revoke(session.ID); err != nil {
+				return err
+			}
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	//Encoding the session values
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(session.Values); err != nil {
+		return err
+	}
+
+	//Remembering the owner of the session, so that it can be revoked with the other sessions of the user
+	var userID sql.NullInt64
+	if id, ok := session.Values[UserIDKey].(int); ok {
+		userID = sql.NullInt64{Int64: int64(id), Valid: true}
+	}
+
+	//Storing a new session under a new ID, an existing session keeps its original expiry time
+	//An existing session is only updated while its row is there, a revoked session is never brought back
+	if session.ID == "" {
+		session.ID = newID()
+		_, err := s.db.Exec(
+			"INSERT INTO sessions (id, user_id, data, expires_at) VALUES ($1, $2, $3, $4)",
+			session.ID, userID, data.Bytes(), time.Now().Add(s.AbsoluteTimeout),
+		)
+		if err != nil {
+			return err
+		}
+	} else {
+		res, err := s.db.Exec(
+			"UPDATE sessions SET user_id = $2, data = $3, last_seen_at = now() WHERE id = $1",
+			session.ID, userID, data.Bytes(),
+		)
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			session.ID = ""
+			expired := *session.Options
+			expired.MaxAge = -1
+			http.SetCookie(w, sessions.NewCookie(session.Name(), "", &expired))
+			return ErrRevoked
+		}
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// Regenerate gives the session a new ID and deletes the row of the old one, the values of the session are kept
+// The new ID is stored by the next Save. Regenerating on sign-in keeps an ID planted in the browser
+// Before the sign-in from becoming a signed-in session
+func (s *Store) Regenerate(session *sessions.Session) error {
+	if session.ID != "" {
+		if err := s.Revoke(session.ID); err != nil {
+			return err
+		}
+	}
+	session.ID = ""
+	session.IsNew = true
+	return nil
+}
+
+// Revoke deletes a single session
+func (s *Store) Revoke(id string) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE id = $1", id)
+	return err
+}
+
+// RevokeUser deletes every session of the user, signing them out on all devices
+func (s *Store) RevokeUser(userID int) error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE user_id = $1", userID)
+	return err
+}
+
+// DeleteExpired removes sessions that passed their absolute or idle timeout
+func (s *Store) DeleteExpired() error {
+	_, err := s.db.Exec("DELETE FROM sessions WHERE expires_at < now() OR last_seen_at < $1", time.Now().Add(-s.IdleTimeout))
+	return err
+}
+
+// Cleanup calls DeleteExpired every interval, it blocks forever and is meant to be started with "go"
+func (s *Store) Cleanup(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := s.DeleteExpired(); err != nil {
+			log.Printf("Error deleting expired sessions: %v", err)
+		}
+	}
+}
+
+// load reads the session data into session.Values and reports whether a valid session was found
+func (s *Store) load(id string, session *sessions.Session) (bool, error) {
+	var data []byte
+	var lastSeen, expires time.Time
+	err := s.db.QueryRow("SELECT data, last_seen_at, expires_at FROM sessions WHERE id = $1", id).
+		Scan(&data, &lastSeen, &expires)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	//Ending sessions that timed out
+	now := time.Now()
+	if now.After(expires) || now.Sub(lastSeen) > s.IdleTimeout {
+		return false, s.Revoke(id)
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&session.Values); err != nil {
+		return false, err
+	}
+
+	//Recording the activity, at most once per touchInterval
+	if now.Sub(lastSeen) > touchInterval {
+		if _, err := s.db.Exec("UPDATE sessions SET last_seen_at = now() WHERE id = $1", id); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// newID returns a random session ID
+func newID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("pgsession: reading random bytes: " + err.Error())
+	}
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(b), "=")
+}
@@ -0,0 +1,265 @@
+package pgsession
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// row is a row of the fake sessions table
+type row struct {
+	userID   interface{}
+	data     []byte
+	lastSeen time.Time
+	expires  time.Time
+}
+
+// fakeDB is a database/sql connector keeping the sessions table in memory
+// It understands exactly the statements of the Store, so the tests run without PostgreSQL
+type fakeDB struct {
+	mu   sync.Mutex
+	rows map[string]*row
+}
+
+func newFakeDB() (*fakeDB, *sql.DB) {
+	f := &fakeDB{rows: map[string]*row{}}
+	return f, sql.OpenDB(f)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c fakeConn) Prepare(query string) (driver.Stmt, error) { return fakeStmt{c.db, query}, nil }
+func (c fakeConn) Close() error                              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s fakeStmt) Close() error  { return nil }
+func (s fakeStmt) NumInput() int { return -1 }
+
+func (s fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	switch {
+	case strings.HasPrefix(s.query, "INSERT INTO sessions"):
+		s.db.rows[args[0].(string)] = &row{userID: args[1], data: args[2].([]byte), lastSeen: time.Now(), expires: args[3].(time.Time)}
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "UPDATE sessions SET user_id"):
+		r, ok := s.db.rows[args[0].(string)]
+		if !ok {
+			return driver.RowsAffected(0), nil
+		}
+		r.userID, r.data, r.lastSeen = args[1], args[2].([]byte), time.Now()
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "UPDATE sessions SET last_seen_at"):
+		if r, ok := s.db.rows[args[0].(string)]; ok {
+			r.lastSeen = time.Now()
+		}
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "DELETE FROM sessions WHERE id"):
+		delete(s.db.rows, args[0].(string))
+		return driver.RowsAffected(1), nil
+	case strings.HasPrefix(s.query, "DELETE FROM sessions WHERE user_id"):
+		for id, r := range s.db.rows {
+			if r.userID == args[0] {
+				delete(s.db.rows, id)
+			}
+		}
+		return driver.RowsAffected(1), nil
+	}
+	return nil, errors.New("unexpected statement: " + s.query)
+}
+
+func (s fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	if !strings.HasPrefix(s.query, "SELECT data, last_seen_at, expires_at FROM sessions") {
+		return nil, errors.New("unexpected query: " + s.query)
+	}
+	rows := &fakeRows{}
+	if r, ok := s.db.rows[args[0].(string)]; ok {
+		rows.values = [][]driver.Value{{r.data, r.lastSeen, r.expires}}
+	}
+	return rows, nil
+}
+
+type fakeRows struct{ values [][]driver.Value }
+
+func (r *fakeRows) Columns() []string { return []string{"data", "last_seen_at", "expires_at"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// count returns the number of stored sessions
+func (f *fakeDB) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.rows)
+}
+
+// requestWith returns a request carrying the session cookie set by an earlier response
+func requestWith(t *testing.T, w *httptest.ResponseRecorder) *http.Request {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "session" && cookie.MaxAge >= 0 {
+			r.AddCookie(cookie)
+		}
+	}
+	return r
+}
+
+// saved saves the values in a new session and returns the response setting its cookie
+func saved(t *testing.T, store *Store, values map[interface{}]interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	session, err := store.New(httptest.NewRequest("GET", "/", nil), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range values {
+		session.Values[k] = v
+	}
+	w := httptest.NewRecorder()
+	if err := store.Save(nil, w, session); err != nil {
+		t.Fatalf("saving new session: %v", err)
+	}
+	return w
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	db, sqlDB := newFakeDB()
+	store := New(sqlDB, []byte("0123456789abcdef0123456789abcdef"))
+
+	w := saved(t, store, map[interface{}]interface{}{"name": "Ada", UserIDKey: 7})
+	if db.count() != 1 {
+		t.Fatalf("%d sessions stored, want 1", db.count())
+	}
+
+	session, err := store.New(requestWith(t, w), "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.IsNew || session.Values["name"] != "Ada" || session.Values[UserIDKey] != 7 {
+		t.Errorf("loaded session is new %v with values %v", session.IsNew, session.Values)
+	}
+
+	//The cookie only carries the signed ID, a changed cookie starts a new session
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: "tampered"})
+	if session, _ := store.New(r, "session"); !session.IsNew || len(session.Values) != 0 {
+		t.Errorf("tampered cookie loaded a session with values %v", session.Values)
+	}
+}
+
+func TestRegenerate(t *testing.T) {
+	db, sqlDB := newFakeDB()
+	store := New(sqlDB, []byte("0123456789abcdef0123456789abcdef"))
+
+	w := saved(t, store, map[interface{}]interface{}{"state": "abc"})
+	session, _ := store.New(requestWith(t, w), "session")
+	oldID := session.ID
+
+	//Signing in regenerates the session, the values are kept under a new ID
+	if err := store.Regenerate(session); err != nil {
+		t.Fatal(err)
+	}
+	session.Values[UserIDKey] = 7
+	w2 := httptest.NewRecorder()
+	if err := store.Save(nil, w2, session); err != nil {
+		t.Fatal(err)
+	}
+	if session.ID == oldID || db.count() != 1 {
+		t.Errorf("regenerated session has ID changed %v and %d stored sessions, want a new ID and 1 session", session.ID != oldID, db.count())
+	}
+	if loaded, _ := store.New(requestWith(t, w2), "session"); loaded.Values["state"] != "abc" || loaded.Values[UserIDKey] != 7 {
+		t.Errorf("regenerated session has values %v", loaded.Values)
+	}
+
+	//The ID known before the sign-in no longer loads a session
+	if loaded, _ := store.New(requestWith(t, w), "session"); !loaded.IsNew {
+		t.Error("the old session ID still loads a session")
+	}
+}
+
+func TestSaveRevokedSession(t *testing.T) {
+	db, sqlDB := newFakeDB()
+	store := New(sqlDB, []byte("0123456789abcdef0123456789abcdef"))
+
+	w := saved(t, store, map[interface{}]interface{}{UserIDKey: 7})
+	session, _ := store.New(requestWith(t, w), "session")
+
+	//Revoking every session of the user while the request is running, e.g. on "log out everywhere"
+	saved(t, store, map[interface{}]interface{}{UserIDKey: 8})
+	if err := store.RevokeUser(7); err != nil {
+		t.Fatal(err)
+	}
+	if db.count() != 1 {
+		t.Fatalf("%d sessions left, want the session of the other user", db.count())
+	}
+
+	//Saving the loaded session does not bring it back and deletes its cookie
+	w2 := httptest.NewRecorder()
+	if err := store.Save(nil, w2, session); err != ErrRevoked {
+		t.Errorf("Save of a revoked session returned %v, want ErrRevoked", err)
+	}
+	if db.count() != 1 {
+		t.Errorf("%d sessions stored after saving a revoked session, want 1", db.count())
+	}
+	cookies := w2.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].MaxAge >= 0 {
+		t.Errorf("cookies %v, want the session cookie deleted", cookies)
+	}
+}
+
+func TestTimeouts(t *testing.T) {
+	db, sqlDB := newFakeDB()
+	store := New(sqlDB, []byte("0123456789abcdef0123456789abcdef"))
+
+	w := saved(t, store, map[interface{}]interface{}{"name": "Ada"})
+	for _, r := range db.rows {
+		r.lastSeen = time.Now().Add(-store.IdleTimeout - time.Minute)
+	}
+
+	//An idle session is deleted when it is used again, the request gets a new session
+	if session, _ := store.New(requestWith(t, w), "session"); !session.IsNew {
+		t.Error("idle session was loaded")
+	}
+	if db.count() != 0 {
+		t.Errorf("%d sessions stored, want the idle session deleted", db.count())
+	}
+
+	//Deleting a session with a negative MaxAge removes its row and its cookie
+	w = saved(t, store, nil)
+	session, _ := store.New(requestWith(t, w), "session")
+	session.Options.MaxAge = -1
+	if err := store.Save(nil, httptest.NewRecorder(), session); err != nil {
+		t.Fatal(err)
+	}
+	if db.count() != 0 {
+		t.Errorf("%d sessions stored, want the session deleted", db.count())
+	}
+}
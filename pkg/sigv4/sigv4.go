@@ -0,0 +1,119 @@
+// Package sigv4 signs HTTP requests to S3-compatible storages with AWS Signature Version 4
+// Only header based signing with an unsigned payload is supported, which is what the blob package needs
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// UnsignedPayload is the x-amz-content-sha256 value of requests whose body is not part of the signature
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// DateFormat is the format of the X-Amz-Date header
+const DateFormat = "20060102T150405Z"
+
+// Sign signs the request for the S3 service with AWS Signature Version 4 at the given time
+// The body is not signed, so it can be streamed
+func Sign(req *http.Request, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.UTC().Format(DateFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", UnsignedPayload)
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	scope := amzDate[:8] + "/" + region + "/s3/aws4_request"
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, strings.Join(signed, ";"), Signature(req, secretKey, region, signed)))
+}
+
+// Signature computes the Signature Version 4 of the request over the signed headers,
+// Using the date of its X-Amz-Date header and the payload hash of its X-Amz-Content-Sha256 header
+// It is used both to sign requests and to check the signature of received requests
+func Signature(req *http.Request, secretKey, region string, signed []string) string {
+	amzDate := req.Header.Get("X-Amz-Date")
+	if len(amzDate) < 8 {
+		return ""
+	}
+
+	//Building the canonical request from the method, the path, the query and the signed headers
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	signed = append([]string{}, signed...)
+	sort.Strings(signed)
+	var headers strings.Builder
+	for _, name := range signed {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonical := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req),
+		headers.String(),
+		strings.Join(signed, ";"),
+		req.Header.Get("X-Amz-Content-Sha256"),
+	}, "\n")
+
+	//Signing the hash of the canonical request with the key derived for the day, region and service
+	scope := amzDate[:8] + "/" + region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+	key := []byte("AWS4" + secretKey)
+	for _, part := range []string{amzDate[:8], region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	return hex.EncodeToString(hmacSHA256(key, toSign))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data with the key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery returns the query parameters sorted by name and encoded as required by Signature Version 4
+func canonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var pairs []string
+	for _, name := range names {
+		values := append([]string{}, query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes every byte except the unreserved characters, slashes are kept unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '.', c == '_', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package sigv4
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSignatureAWSExample checks the signature of the GET Object example of the Amazon S3 documentation
+func TestSignatureAWSExample(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://examplebucket.s3.amazonaws.com/test.txt", nil)
+	req.Header.Set("Range", "bytes=0-9")
+	req.Header.Set("X-Amz-Content-Sha256", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855")
+	req.Header.Set("X-Amz-Date", "20130524T000000Z")
+
+	got := Signature(req, "wJalrXUtnFEMI/K7MDENG/bPxRfiCYEXAMPLEKEY", "us-east-1", []string{"host", "range", "x-amz-content-sha256", "x-amz-date"})
+	if want := "f0e8bdb87c964420e857bd35b5d6ed310bd44f0170aba48dd91039c6036bdb41"; got != want {
+		t.Errorf("Signature = %s, want %s", got, want)
+	}
+}
+
+func TestSign(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.FixedZone("CEST", 2*3600))
+	req, _ := http.NewRequest("PUT", "http://localhost:8080/fake-s3/bucket/media/1", nil)
+	Sign(req, "key", "secret", "eu-west-1", now)
+
+	if got := req.Header.Get("X-Amz-Date"); got != "20240501T103000Z" {
+		t.Errorf("X-Amz-Date = %q, want the time in UTC", got)
+	}
+	if got := req.Header.Get("X-Amz-Content-Sha256"); got != UnsignedPayload {
+		t.Errorf("X-Amz-Content-Sha256 = %q, want %q", got, UnsignedPayload)
+	}
+	auth := req.Header.Get("Authorization")
+	prefix := "AWS4-HMAC-SHA256 Credential=key/20240501/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="
+	if !strings.HasPrefix(auth, prefix) {
+		t.Fatalf("Authorization = %q, want prefix %q", auth, prefix)
+	}
+
+	//The signature in the header is the one a server computes from the received request
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if got := strings.TrimPrefix(auth, prefix); got != Signature(req, "secret", "eu-west-1", signed) {
+		t.Errorf("signature %s does not verify", got)
+	}
+
+	//Changing the method, the path or the secret changes the signature
+	for _, change := range []func(*http.Request) string{
+		func(r *http.Request) string { r.Method = "DELETE"; return "secret" },
+		func(r *http.Request) string { r.URL.Path = "/fake-s3/bucket/media/2"; return "secret" },
+		func(r *http.Request) string { return "other" },
+	} {
+		changed := req.Clone(req.Context())
+		secret := change(changed)
+		if Signature(changed, secret, "eu-west-1", signed) == strings.TrimPrefix(auth, prefix) {
+			t.Errorf("changed request to %s %s still has the signature", changed.Method, changed.URL.Path)
+		}
+	}
+}
+
+func TestCanonicalQuery(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://localhost/bucket/key?prefix=a%2Fb&list-type=2&max-keys=10&z=x+y&a=2&a=1", nil)
+	want := "a=1&a=2&list-type=2&max-keys=10&prefix=a%2Fb&z=x%20y"
+	if got := canonicalQuery(req); got != want {
+		t.Errorf("canonicalQuery = %q, want %q", got, want)
+	}
+}
+
+func TestURIEncode(t *testing.T) {
+	tests := []struct {
+		s           string
+		encodeSlash bool
+		want        string
+	}{
+		{"/bucket/media/photo 1.jpg", false, "/bucket/media/photo%201.jpg"},
+		{"a/b", true, "a%2Fb"},
+		{"AZaz09-._~", true, "AZaz09-._~"},
+		{"ü+*", false, "%C3%BC%2B%2A"},
+	}
+	for _, tt := range tests {
+		if got := uriEncode(tt.s, tt.encodeSlash); got != tt.want {
+			t.Errorf("uriEncode(%q, %v) = %q, want %q", tt.s, tt.encodeSlash, got, tt.want)
+		}
+	}
+}
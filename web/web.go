@@ -0,0 +1,9 @@
+// Package web embeds the HTML templates and the static CSS files into the binary
+package web
+
+import "embed"
+
+// Files holds the "templates" and "css" directories
+//
+//go:embed templates css
+var Files embed.FS